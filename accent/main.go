@@ -0,0 +1,187 @@
+// Package accent sets the tab and badge colors of the profiles, and their
+// badge font, from a stable hash of what they connect to, so the profiles of
+// two AWS accounts, clusters or SSH hosts look different.
+package accent
+
+import (
+	stderrors "errors"
+	"hash/fnv"
+	"math"
+	"strings"
+
+	"github.com/mhristof/germ/classify"
+	"github.com/mhristof/germ/iterm"
+	"github.com/pkg/errors"
+)
+
+// BadgeAlpha is the opacity of the badge color, as iTerm2 uses by default.
+const BadgeAlpha = 0.5
+
+// Pin replaces the hashed color and font of a key.
+type Pin struct {
+	Color     string
+	BadgeFont string `mapstructure:"badge_font"`
+}
+
+// Config is the accent section of germ.yaml.
+type Config struct {
+	Disabled bool
+	// BadgeFonts are picked by the same hash as the color, the badge font is
+	// not changed if it is empty.
+	BadgeFonts []string `mapstructure:"badge_fonts"`
+	// Pins are by account ID, cluster name or SSH host, case insensitive.
+	Pins map[string]Pin
+}
+
+// Key returns what the accent of the profile is derived from: its AWS
+// account, its kubernetes cluster or its SSH host, in that order. Profiles
+// without any get an empty key and are left untouched.
+func Key(p iterm.Profile) string {
+	facts := classify.FactsOf(p)
+
+	switch {
+	case len(facts.Accounts) > 0:
+		return facts.Accounts[0]
+	case len(facts.Clusters) > 0:
+		// the short name of EKS clusters, see classify.FactsOf
+		return facts.Clusters[len(facts.Clusters)-1]
+	case len(facts.Hosts) > 0:
+		return facts.Hosts[0]
+	}
+
+	return ""
+}
+
+func hash(key string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+
+	return h.Sum32()
+}
+
+// Color returns the color of key, a hue picked by its hash with a fixed
+// saturation and brightness so all the tabs are equally readable.
+func Color(key string) iterm.Color {
+	hue := float64(hash(key)%360) / 60
+	saturation, value := 0.65, 0.85
+
+	chroma := value * saturation
+	x := chroma * (1 - math.Abs(math.Mod(hue, 2)-1))
+
+	var r, g, b float64
+
+	switch int(hue) {
+	case 0:
+		r, g = chroma, x
+	case 1:
+		r, g = x, chroma
+	case 2:
+		g, b = chroma, x
+	case 3:
+		g, b = x, chroma
+	case 4:
+		r, b = x, chroma
+	default:
+		r, b = chroma, x
+	}
+
+	m := value - chroma
+
+	return iterm.Color{
+		ColorSpace:     "sRGB",
+		RedComponent:   r + m,
+		GreenComponent: g + m,
+		BlueComponent:  b + m,
+		AlphaComponent: 1,
+	}
+}
+
+// pin returns the pin of key, if any.
+func (c Config) pin(key string) (Pin, bool) {
+	for name, pin := range c.Pins {
+		if strings.EqualFold(name, key) {
+			return pin, true
+		}
+	}
+
+	return Pin{}, false
+}
+
+// Validate checks the colors of the pins.
+func (c Config) Validate() error {
+	var errs []error
+
+	for name, pin := range c.Pins {
+		if pin.Color == "" {
+			continue
+		}
+
+		if _, err := iterm.ParseColor(pin.Color); err != nil {
+			errs = append(errs, errors.Wrapf(err, "pin %s", name))
+		}
+	}
+
+	return stderrors.Join(errs...)
+}
+
+// Apply sets the tab color, the badge color and the badge font of the
+// profile from its key.
+func (c Config) Apply(p *iterm.Profile) {
+	key := Key(*p)
+	if key == "" {
+		return
+	}
+
+	color := Color(key)
+	font := ""
+
+	if len(c.BadgeFonts) > 0 {
+		font = c.BadgeFonts[hash(key)%uint32(len(c.BadgeFonts))]
+	}
+
+	if pin, ok := c.pin(key); ok {
+		if pin.Color != "" {
+			// the pins are validated
+			color, _ = iterm.ParseColor(pin.Color)
+		}
+
+		if pin.BadgeFont != "" {
+			font = pin.BadgeFont
+		}
+	}
+
+	badge := color
+	badge.AlphaComponent = BadgeAlpha
+
+	p.TabColor = &color
+	p.UseTabColor = true
+	p.BadgeColor = &badge
+
+	if font != "" {
+		p.BadgeFont = font
+	}
+}
+
+// Apply sets the accents of the profiles, skipping the profile named skip.
+// Nothing is changed if accents are disabled or the configuration is
+// invalid.
+func Apply(c Config, profiles []iterm.Profile, skip string) error {
+	if c.Disabled {
+		return nil
+	}
+
+	err := c.Validate()
+	if err != nil {
+		return errors.Wrap(err, "invalid accent")
+	}
+
+	for i := range profiles {
+		if profiles[i].Name == skip {
+			continue
+		}
+
+		c.Apply(&profiles[i])
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package accent
+
+import (
+	"testing"
+
+	"github.com/mhristof/germ/iterm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKey(t *testing.T) {
+	cases := []struct {
+		name     string
+		profile  iterm.Profile
+		expected string
+	}{
+		{name: "aws", profile: iterm.Profile{Identity: "aws:123456789012:admin"}, expected: "123456789012"},
+		{name: "ssm", profile: iterm.Profile{Tags: []string{"account=123456789012"}}, expected: "123456789012"},
+		{name: "eks", profile: iterm.Profile{Identity: "k8s:arn:aws:eks:eu-west-1:1:cluster/payments"}, expected: "payments"},
+		{name: "k8s", profile: iterm.Profile{Identity: "k8s:minikube"}, expected: "minikube"},
+		{name: "ssh", profile: iterm.Profile{Name: "bastion", Tags: []string{"ssh"}}, expected: "bastion"},
+		{name: "other", profile: iterm.Profile{Name: "vim"}},
+	}
+
+	for _, test := range cases {
+		assert.Equal(t, test.expected, Key(test.profile), test.name)
+	}
+}
+
+func TestColor(t *testing.T) {
+	c := Color("123456789012")
+	assert.Equal(t, c, Color("123456789012"))
+	assert.NotEqual(t, c, Color("210987654321"))
+
+	for _, key := range []string{"a", "b", "c", "payments", "bastion", "123456789012"} {
+		c := Color(key)
+		for _, component := range []float64{c.RedComponent, c.GreenComponent, c.BlueComponent} {
+			assert.GreaterOrEqual(t, component, 0.0, key)
+			assert.LessOrEqual(t, component, 1.0, key)
+		}
+
+		assert.Equal(t, "sRGB", c.ColorSpace)
+		assert.Equal(t, 1.0, c.AlphaComponent)
+	}
+}
+
+func TestApply(t *testing.T) {
+	config := Config{
+		BadgeFonts: []string{"Menlo-Bold"},
+		Pins: map[string]Pin{
+			"payments": {Color: "#ff0000", BadgeFont: "Monaco"},
+		},
+	}
+
+	profiles := []iterm.Profile{
+		{Name: "account", Identity: "aws:123456789012:admin"},
+		{Name: "k8s-payments", Identity: "k8s:Payments"},
+		{Name: "vim"},
+		{Name: "parent", Identity: "aws:123456789012:admin"},
+	}
+
+	assert.NoError(t, Apply(config, profiles, "parent"))
+
+	hashed := Color("123456789012")
+	assert.Equal(t, &hashed, profiles[0].TabColor)
+	assert.True(t, profiles[0].UseTabColor)
+	assert.Equal(t, BadgeAlpha, profiles[0].BadgeColor.AlphaComponent)
+	assert.Equal(t, hashed.RedComponent, profiles[0].BadgeColor.RedComponent)
+	assert.Equal(t, "Menlo-Bold", profiles[0].BadgeFont)
+
+	assert.Equal(t, &iterm.Color{ColorSpace: "sRGB", RedComponent: 1, AlphaComponent: 1}, profiles[1].TabColor)
+	assert.Equal(t, "Monaco", profiles[1].BadgeFont)
+
+	assert.Nil(t, profiles[2].TabColor)
+	assert.False(t, profiles[2].UseTabColor)
+	assert.Nil(t, profiles[3].TabColor)
+
+	disabled := []iterm.Profile{{Identity: "aws:123456789012:admin"}}
+	assert.NoError(t, Apply(Config{Disabled: true}, disabled, ""))
+	assert.Nil(t, disabled[0].TabColor)
+
+	invalid := Config{Pins: map[string]Pin{"payments": {Color: "red"}}}
+	assert.Error(t, Apply(invalid, disabled, ""))
+	assert.Nil(t, disabled[0].TabColor)
+}
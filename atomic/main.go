@@ -0,0 +1,68 @@
+// Package atomic writes files without ever leaving a partial file behind.
+package atomic
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// WriteFile writes data to a temporary file next to path and renames it over
+// path, so readers see either the old or the new content.
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return errors.Wrapf(err, "cannot create temporary file for %s", path)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		return errors.Wrapf(err, "cannot write temporary file for %s", path)
+	}
+
+	err = os.Chmod(tmp.Name(), perm)
+	if err != nil {
+		return errors.Wrapf(err, "cannot chmod %s", tmp.Name())
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return errors.Wrapf(err, "cannot rename %s to %s", tmp.Name(), path)
+	}
+
+	return nil
+}
+
+// Lock takes an exclusive lock for path, shared with the other germ
+// processes, and returns the function releasing it. The lock is a separate
+// file so WriteFile can replace path while it is held.
+func Lock(path string) (func(), error) {
+	f, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot open the lock of %s", path)
+	}
+
+	err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+	if err != nil {
+		f.Close()
+		return nil, errors.Wrapf(err, "cannot lock %s", path)
+	}
+
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
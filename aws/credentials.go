@@ -0,0 +1,165 @@
+package aws
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mhristof/germ/profile"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/zieckey/goini"
+)
+
+// StaticCredentialsTag tags the profiles using a long lived access key from
+// the credentials file.
+const StaticCredentialsTag = "static-credentials"
+
+// KeyCreated is the key of a credentials section recording when its access
+// key was created, as a date or in RFC 3339, for example
+//
+//	[ci]
+//	aws_access_key_id = AKIA...
+//	aws_secret_access_key = ...
+//	aws_access_key_created = 2024-01-31
+const KeyCreated = "aws_access_key_created"
+
+// secrets are the keys of the credentials file that are never copied to a
+// profile.
+var secrets = map[string]struct{}{
+	"aws_access_key_id":     {},
+	"aws_secret_access_key": {},
+	"aws_session_token":     {},
+	"aws_security_token":    {},
+}
+
+// parse returns the sections of an INI file, or nil if it does not exist.
+func parse(path string) (goini.SectionMap, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	ini := goini.New()
+	err := ini.ParseFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot parse %s", path)
+	}
+
+	return ini.GetAll(), nil
+}
+
+// Load returns the AWS profiles of the config and the credentials files
+// by name, merged the way the AWS CLI does: a profile can be in either file
+// and the credentials file wins for the keys set in both. The sso-session
+// sections of the config are returned as the sessions. A credentials file
+// that cannot be parsed is reported in the returned error while the profiles
+// of the config are still returned.
+func Load(config, credentials string) (Config, error) {
+	ret := Config{
+		Profiles: map[string]map[string]string{},
+		Sessions: map[string]Session{},
+	}
+
+	configSections, err := parse(config)
+	if err != nil {
+		return ret, err
+	}
+
+	credentialsSections, credentialsErr := parse(credentials)
+
+	if configSections == nil && credentialsSections == nil {
+		if credentialsErr == nil {
+			log.Warn().Str("config", config).Str("credentials", credentials).Msg("AWS config not found")
+		}
+
+		return ret, credentialsErr
+	}
+
+	merge := func(name string, section map[string]string) {
+		if _, ok := ret.Profiles[name]; !ok {
+			ret.Profiles[name] = map[string]string{}
+		}
+
+		for key, value := range section {
+			ret.Profiles[name][key] = value
+		}
+	}
+
+	for name, section := range configSections {
+		if name == "" {
+			continue
+		}
+
+		if strings.HasPrefix(name, sessionPrefix) {
+			session := newSession(strings.TrimPrefix(name, sessionPrefix), section)
+			ret.Sessions[session.Name] = session
+
+			continue
+		}
+
+		merge(strings.TrimPrefix(name, "profile "), section)
+	}
+
+	for name, section := range credentialsSections {
+		if name == "" {
+			continue
+		}
+
+		merge(name, section)
+	}
+
+	return ret, credentialsErr
+}
+
+// withoutSecrets returns the section without the keys of the credentials.
+func withoutSecrets(config map[string]string) map[string]string {
+	ret := map[string]string{}
+	for key, value := range config {
+		if _, ok := secrets[key]; ok {
+			continue
+		}
+
+		ret[key] = value
+	}
+
+	return ret
+}
+
+// static returns true for the sections with an access key.
+func static(config map[string]string) bool {
+	_, ok := config["aws_access_key_id"]
+
+	return ok
+}
+
+// withStaticCredentials tags the profile of a section with an access key.
+// The age of the key, if the section records it, see KeyCreated, is only
+// kept in the metadata for the badges: a tag would change the profile every
+// day.
+func withStaticCredentials(b *profile.Builder, config map[string]string) error {
+	if !static(config) {
+		return nil
+	}
+
+	b.WithTags(StaticCredentialsTag)
+
+	value, ok := config[KeyCreated]
+	if !ok {
+		return nil
+	}
+
+	created, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		created, err = time.Parse(time.DateOnly, value)
+	}
+
+	if err != nil {
+		return errors.Errorf("invalid %s %q", KeyCreated, value)
+	}
+
+	b.WithMetadata("key_created", created.Format(time.DateOnly))
+	b.WithMetadata("key_age", fmt.Sprintf("%dd", int(time.Since(created).Hours()/24)))
+
+	return nil
+}
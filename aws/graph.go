@@ -0,0 +1,303 @@
+package aws
+
+import (
+	stderrors "errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Graph is the role-chaining graph of the AWS profiles. A profile with a
+// source_profile assumes its role_arn with the credentials of its source,
+// and the profiles of an sso-session log in through the session, named
+// "sso-session <name>" in the graph.
+type Graph struct {
+	nodes    []string
+	parents  map[string]string
+	children map[string][]string
+	roles    map[string]string
+	// cycles are the nodes of every cycle, starting from the smallest name.
+	cycles [][]string
+}
+
+// SessionNode returns the name of the sso-session in the graph.
+func SessionNode(session string) string {
+	return sessionPrefix + session
+}
+
+// NewGraph returns the role-chaining graph of the profiles. Dangling source
+// profiles, unknown sso-sessions and cycles are reported in the returned
+// error, the graph is still returned without the dangling edges.
+func NewGraph(cfg Config) (*Graph, error) {
+	g := &Graph{
+		parents:  map[string]string{},
+		children: map[string][]string{},
+		roles:    map[string]string{},
+	}
+
+	var errs []error
+
+	for name := range cfg.Sessions {
+		g.nodes = append(g.nodes, SessionNode(name))
+	}
+
+	for name, section := range cfg.Profiles {
+		g.nodes = append(g.nodes, name)
+		g.roles[name] = section["role_arn"]
+
+		source, chained := section["source_profile"]
+		_, static := section["aws_access_key_id"]
+
+		switch {
+		// a profile assuming its role with its own access key
+		case chained && source == name && static:
+		case chained:
+			if _, ok := cfg.Profiles[source]; !ok {
+				errs = append(errs, fmt.Errorf("profile %s has a dangling source_profile %s", name, source))
+				continue
+			}
+
+			g.parents[name] = source
+		case section["sso_session"] != "":
+			session := section["sso_session"]
+			if _, ok := cfg.Sessions[session]; !ok {
+				errs = append(errs, fmt.Errorf("profile %s references unknown sso-session %s", name, session))
+				continue
+			}
+
+			g.parents[name] = SessionNode(session)
+		}
+	}
+
+	sort.Strings(g.nodes)
+
+	for _, name := range g.nodes {
+		if parent, ok := g.parents[name]; ok {
+			g.children[parent] = append(g.children[parent], name)
+		}
+	}
+
+	g.cycles = g.findCycles()
+	for _, cycle := range g.cycles {
+		errs = append(errs, fmt.Errorf("source_profile cycle %s -> %s", strings.Join(cycle, " -> "), cycle[0]))
+	}
+
+	return g, stderrors.Join(errs...)
+}
+
+// findCycles returns the cycles of the graph. Every node has at most one
+// parent, so a cycle is found by following the parents.
+func (g *Graph) findCycles() [][]string {
+	var ret [][]string
+	done := map[string]struct{}{}
+
+	for _, start := range g.nodes {
+		seen := map[string]int{}
+		var path []string
+
+		for name := start; ; {
+			if _, ok := done[name]; ok {
+				break
+			}
+
+			if i, ok := seen[name]; ok {
+				ret = append(ret, rotate(path[i:]))
+				break
+			}
+
+			seen[name] = len(path)
+			path = append(path, name)
+
+			parent, ok := g.parents[name]
+			if !ok {
+				break
+			}
+
+			name = parent
+		}
+
+		for _, name := range path {
+			done[name] = struct{}{}
+		}
+	}
+
+	return ret
+}
+
+// rotate returns the cycle starting from its smallest name.
+func rotate(cycle []string) []string {
+	smallest := 0
+	for i, name := range cycle {
+		if name < cycle[smallest] {
+			smallest = i
+		}
+	}
+
+	return append(append([]string{}, cycle[smallest:]...), cycle[:smallest]...)
+}
+
+// Nodes returns the names of the profiles and the sessions, sorted.
+func (g *Graph) Nodes() []string {
+	return g.nodes
+}
+
+// Parent returns the source profile or the session of the profile.
+func (g *Graph) Parent(name string) (string, bool) {
+	parent, ok := g.parents[name]
+	return parent, ok
+}
+
+// Children returns the profiles chained from the node, sorted.
+func (g *Graph) Children(name string) []string {
+	return g.children[name]
+}
+
+// Roots returns the nodes without a parent, sorted.
+func (g *Graph) Roots() []string {
+	var ret []string
+	for _, name := range g.nodes {
+		if _, ok := g.parents[name]; !ok {
+			ret = append(ret, name)
+		}
+	}
+
+	return ret
+}
+
+// Chain returns the nodes from the root of the chain to the profile. It
+// returns false for unknown profiles and the profiles of a cycle.
+func (g *Graph) Chain(name string) ([]string, bool) {
+	if i := sort.SearchStrings(g.nodes, name); i == len(g.nodes) || g.nodes[i] != name {
+		return nil, false
+	}
+
+	seen := map[string]struct{}{}
+	ret := []string{name}
+
+	for {
+		seen[name] = struct{}{}
+
+		parent, ok := g.parents[name]
+		if !ok {
+			break
+		}
+
+		if _, ok := seen[parent]; ok {
+			return nil, false
+		}
+
+		ret = append([]string{parent}, ret...)
+		name = parent
+	}
+
+	return ret, true
+}
+
+// Root returns the first node of the chain of the profile, see Chain.
+func (g *Graph) Root(name string) (string, bool) {
+	chain, ok := g.Chain(name)
+	if !ok {
+		return "", false
+	}
+
+	return chain[0], true
+}
+
+// Write renders the graph as a text tree, in DOT or in Mermaid.
+func (g *Graph) Write(w io.Writer, format string) error {
+	switch format {
+	case "text":
+		return g.writeText(w)
+	case "dot":
+		return g.writeDot(w)
+	case "mermaid":
+		return g.writeMermaid(w)
+	}
+
+	return fmt.Errorf("unknown graph format %q, expected text, dot or mermaid", format)
+}
+
+func (g *Graph) label(name string) string {
+	if role := g.roles[name]; role != "" {
+		return fmt.Sprintf("%s (%s)", name, role)
+	}
+
+	return name
+}
+
+func (g *Graph) writeText(w io.Writer) error {
+	var lines []string
+
+	var walk func(name, indent string)
+	walk = func(name, indent string) {
+		children := g.Children(name)
+		for i, child := range children {
+			branch, next := "├── ", "│   "
+			if i == len(children)-1 {
+				branch, next = "└── ", "    "
+			}
+
+			lines = append(lines, indent+branch+g.label(child))
+			walk(child, indent+next)
+		}
+	}
+
+	for _, root := range g.Roots() {
+		lines = append(lines, g.label(root))
+		walk(root, "")
+	}
+
+	for _, cycle := range g.cycles {
+		lines = append(lines, fmt.Sprintf("cycle: %s -> %s", strings.Join(cycle, " -> "), cycle[0]))
+	}
+
+	_, err := fmt.Fprintln(w, strings.Join(lines, "\n"))
+
+	return err
+}
+
+func (g *Graph) writeDot(w io.Writer) error {
+	lines := []string{"digraph aws {"}
+
+	for _, name := range g.nodes {
+		attrs := fmt.Sprintf("label=%q", g.label(name))
+		if strings.HasPrefix(name, sessionPrefix) {
+			attrs += " shape=box"
+		}
+
+		lines = append(lines, fmt.Sprintf("  %q [%s];", name, attrs))
+	}
+
+	for _, name := range g.nodes {
+		if parent, ok := g.parents[name]; ok {
+			lines = append(lines, fmt.Sprintf("  %q -> %q;", parent, name))
+		}
+	}
+
+	lines = append(lines, "}")
+	_, err := fmt.Fprintln(w, strings.Join(lines, "\n"))
+
+	return err
+}
+
+func (g *Graph) writeMermaid(w io.Writer) error {
+	lines := []string{"graph TD"}
+	ids := map[string]string{}
+
+	for i, name := range g.nodes {
+		ids[name] = fmt.Sprintf("n%d", i)
+		label := strings.ReplaceAll(g.label(name), `"`, "#quot;")
+		lines = append(lines, fmt.Sprintf(`  %s["%s"]`, ids[name], label))
+	}
+
+	for _, name := range g.nodes {
+		if parent, ok := g.parents[name]; ok {
+			lines = append(lines, fmt.Sprintf("  %s --> %s", ids[parent], ids[name]))
+		}
+	}
+
+	_, err := fmt.Fprintln(w, strings.Join(lines, "\n"))
+
+	return err
+}
@@ -0,0 +1,161 @@
+package aws
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/mhristof/germ/iterm"
+	"github.com/stretchr/testify/assert"
+)
+
+func testConfig() Config {
+	return Config{
+		Profiles: map[string]map[string]string{
+			"user":    {"aws_access_key_id": "AKIA"},
+			"hub":     {"source_profile": "user", "role_arn": "arn:aws:iam::1:role/hub"},
+			"account": {"source_profile": "hub", "role_arn": "arn:aws:iam::2:role/admin"},
+			"self":    {"source_profile": "self", "aws_access_key_id": "AKIA", "role_arn": "arn:aws:iam::3:role/self"},
+			"dev":     {"sso_session": "corp"},
+			"loop1":   {"source_profile": "loop2"},
+			"loop2":   {"source_profile": "loop1"},
+			"orphan":  {"source_profile": "missing"},
+			"lost":    {"sso_session": "missing"},
+		},
+		Sessions: map[string]Session{
+			"corp": {Name: "corp"},
+		},
+	}
+}
+
+func TestNewGraph(t *testing.T) {
+	g, err := NewGraph(testConfig())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "profile orphan has a dangling source_profile missing")
+	assert.Contains(t, err.Error(), "profile lost references unknown sso-session missing")
+	assert.Contains(t, err.Error(), "source_profile cycle loop1 -> loop2 -> loop1")
+
+	assert.Equal(t, []string{"lost", "orphan", "self", "sso-session corp", "user"}, g.Roots())
+	assert.Equal(t, []string{"hub"}, g.Children("user"))
+
+	cases := []struct {
+		name     string
+		profile  string
+		expected []string
+	}{
+		{name: "chain", profile: "account", expected: []string{"user", "hub", "account"}},
+		{name: "root", profile: "user", expected: []string{"user"}},
+		{name: "own access key", profile: "self", expected: []string{"self"}},
+		{name: "session", profile: "dev", expected: []string{"sso-session corp", "dev"}},
+		{name: "dangling", profile: "orphan", expected: []string{"orphan"}},
+		{name: "cycle", profile: "loop1"},
+		{name: "unknown", profile: "missing"},
+	}
+
+	for _, test := range cases {
+		chain, ok := g.Chain(test.profile)
+		assert.Equal(t, test.expected != nil, ok, test.name)
+		assert.Equal(t, test.expected, chain, test.name)
+	}
+}
+
+func TestGraphWrite(t *testing.T) {
+	cfg := testConfig()
+	for _, name := range []string{"loop1", "loop2", "orphan", "lost", "self"} {
+		delete(cfg.Profiles, name)
+	}
+
+	g, err := NewGraph(cfg)
+	assert.NoError(t, err)
+
+	cases := []struct {
+		format   string
+		expected string
+	}{
+		{
+			format: "text",
+			expected: heredoc.Doc(`
+				sso-session corp
+				└── dev
+				user
+				└── hub (arn:aws:iam::1:role/hub)
+				    └── account (arn:aws:iam::2:role/admin)
+			`),
+		},
+		{
+			format: "dot",
+			expected: heredoc.Doc(`
+				digraph aws {
+				  "account" [label="account (arn:aws:iam::2:role/admin)"];
+				  "dev" [label="dev"];
+				  "hub" [label="hub (arn:aws:iam::1:role/hub)"];
+				  "sso-session corp" [label="sso-session corp" shape=box];
+				  "user" [label="user"];
+				  "hub" -> "account";
+				  "sso-session corp" -> "dev";
+				  "user" -> "hub";
+				}
+			`),
+		},
+		{
+			format: "mermaid",
+			expected: heredoc.Doc(`
+				graph TD
+				  n0["account (arn:aws:iam::2:role/admin)"]
+				  n1["dev"]
+				  n2["hub (arn:aws:iam::1:role/hub)"]
+				  n3["sso-session corp"]
+				  n4["user"]
+				  n2 --> n0
+				  n3 --> n1
+				  n4 --> n2
+			`),
+		},
+	}
+
+	for _, test := range cases {
+		var buf bytes.Buffer
+		assert.NoError(t, g.Write(&buf, test.format), test.format)
+		assert.Equal(t, test.expected, buf.String(), test.format)
+	}
+
+	assert.Error(t, g.Write(&bytes.Buffer{}, "svg"))
+}
+
+func TestProfilesRootShortcut(t *testing.T) {
+	bin := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(bin, "aws-azure-login"), []byte("#!/bin/sh\n"), 0o755))
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	config := filepath.Join(t.TempDir(), "config")
+	err := os.WriteFile(config, []byte(heredoc.Doc(`
+		[profile user]
+		azure_tenant_id = foo
+
+		[profile hub]
+		source_profile = user
+		role_arn = arn:aws:iam::1:role/hub
+
+		[profile account]
+		source_profile = hub
+		role_arn = arn:aws:iam::2:role/admin
+	`)), 0o644)
+	assert.NoError(t, err)
+
+	profiles, err := Profiles(nil, "", config, "")
+	assert.NoError(t, err)
+
+	byName := map[string]iterm.Profile{}
+	for _, p := range profiles {
+		byName[p.Name] = p
+	}
+
+	for _, name := range []string{"hub", "account"} {
+		assert.Equal(t, iterm.KeyboardMap{
+			Action: iterm.KeyboardSplitHorizontallyProfile,
+			Text:   "login-user",
+		}, byName[name].KeyboardMap[iterm.KeyboardSortcutAltA], name)
+	}
+}
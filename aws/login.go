@@ -0,0 +1,255 @@
+package aws
+
+import (
+	stderrors "errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// NoLogin, as the provider of a profile in germ.yaml, disables its login
+// profile.
+const NoLogin = "none"
+
+// LoginProvider logs into the AWS profiles it detects.
+type LoginProvider struct {
+	Name string
+	// Binary is the executable the login needs, looked up in PATH.
+	Binary string
+	// Detect returns true if the provider logs into the profile section.
+	// Providers are tried in their registry order, nil only matches the
+	// profiles mapped to the provider in germ.yaml.
+	Detect func(config map[string]string) bool
+	// Command returns the login command of the profile, run with
+	// AWS_PROFILE set. Providers without an interactive login, like a
+	// credential process, leave it nil and their profiles get no login
+	// profile, like NoLogin.
+	Command func(profile string, config map[string]string) string
+	// Shortcut returns the text Alt+A sends to log in again from a shell
+	// of the profile, the login command if nil.
+	Shortcut func(profile string, config map[string]string) string
+}
+
+// LoginCommand returns the command of the login profile.
+func (p LoginProvider) LoginCommand(profile string, config map[string]string) (string, error) {
+	bin, err := exec.LookPath(p.Binary)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot find executable %s", p.Binary)
+	}
+
+	return fmt.Sprintf(
+		"bash -c 'AWS_PROFILE=%s PATH=%s NODE_EXTRA_CA_CERTS=%s %s || sleep 60'",
+		profile, filepath.Dir(bin), os.Getenv("NODE_EXTRA_CA_CERTS"), p.Command(profile, config),
+	), nil
+}
+
+// LoginShortcut returns the text Alt+A sends to log in again.
+func (p LoginProvider) LoginShortcut(profile string, config map[string]string) string {
+	if p.Shortcut != nil {
+		return p.Shortcut(profile, config)
+	}
+
+	return fmt.Sprintf("AWS_PROFILE=%s %s\n", profile, p.Command(profile, config))
+}
+
+// Login picks the provider of the profiles the providers cannot detect, an
+// entry of the `logins` section of germ.yaml.
+type Login struct {
+	// Match is a glob matched against the profile name.
+	Match    string
+	Provider string
+}
+
+// LoginRegistry holds the login providers in their detection order.
+type LoginRegistry struct {
+	providers []LoginProvider
+}
+
+func NewLoginRegistry(providers ...LoginProvider) *LoginRegistry {
+	r := &LoginRegistry{}
+
+	for _, p := range providers {
+		r.Register(p)
+	}
+
+	return r
+}
+
+// Register adds a provider at the end of the detection order.
+func (r *LoginRegistry) Register(p LoginProvider) {
+	if _, found := r.Get(p.Name); found || p.Name == NoLogin {
+		log.Panic().Str("name", p.Name).Msg("login provider already registered")
+	}
+
+	r.providers = append(r.providers, p)
+}
+
+func (r *LoginRegistry) Get(name string) (LoginProvider, bool) {
+	for _, p := range r.providers {
+		if p.Name == name {
+			return p, true
+		}
+	}
+
+	return LoginProvider{}, false
+}
+
+func (r *LoginRegistry) Names() []string {
+	ret := make([]string, len(r.providers))
+	for i, p := range r.providers {
+		ret[i] = p.Name
+	}
+
+	return ret
+}
+
+// Validate checks the globs and the provider names of logins.
+func (r *LoginRegistry) Validate(logins []Login) error {
+	var errs []error
+
+	for _, l := range logins {
+		if _, err := path.Match(l.Match, ""); err != nil {
+			errs = append(errs, errors.Wrapf(err, "invalid glob %q", l.Match))
+		}
+
+		if _, found := r.Get(l.Provider); !found && l.Provider != NoLogin {
+			errs = append(errs, fmt.Errorf("unknown login provider %q for %s, expected one of %s", l.Provider, l.Match, strings.Join(r.Names(), ", ")))
+		}
+	}
+
+	return stderrors.Join(errs...)
+}
+
+// Find returns the login provider of the profile: the provider of the first
+// of logins matching the profile name, or else the first provider detecting
+// the section. Profiles mapped to NoLogin, or to a provider without a login
+// command, get a provider named NoLogin. logins must be valid.
+func (r *LoginRegistry) Find(profile string, config map[string]string, logins []Login) (LoginProvider, bool) {
+	p, found := r.find(profile, config, logins)
+	if found && p.Command == nil {
+		return LoginProvider{Name: NoLogin}, true
+	}
+
+	return p, found
+}
+
+func (r *LoginRegistry) find(profile string, config map[string]string, logins []Login) (LoginProvider, bool) {
+	for _, l := range logins {
+		if ok, _ := path.Match(l.Match, profile); !ok {
+			continue
+		}
+
+		if l.Provider == NoLogin {
+			return LoginProvider{Name: NoLogin}, true
+		}
+
+		return r.Get(l.Provider)
+	}
+
+	for _, p := range r.providers {
+		if p.Detect != nil && p.Detect(config) {
+			return p, true
+		}
+	}
+
+	return LoginProvider{}, false
+}
+
+// process returns a detection rule matching the profiles whose
+// credential_process runs the tool.
+func process(tool string) func(config map[string]string) bool {
+	return func(config map[string]string) bool {
+		return strings.Contains(config["credential_process"], tool)
+	}
+}
+
+// has returns a detection rule matching the profiles with the key.
+func has(key string) func(config map[string]string) bool {
+	return func(config map[string]string) bool {
+		_, ok := config[key]
+		return ok
+	}
+}
+
+// DefaultLoginRegistry returns the builtin login providers.
+func DefaultLoginRegistry() *LoginRegistry {
+	return NewLoginRegistry(
+		LoginProvider{
+			Name:   "aws-azure-login",
+			Binary: "aws-azure-login",
+			Detect: has("azure_tenant_id"),
+			Command: func(string, map[string]string) string {
+				return "aws-azure-login --no-prompt"
+			},
+			Shortcut: func(profile string, _ map[string]string) string {
+				return fmt.Sprintf("aws-azure-login --profile %s --no-prompt\n", profile)
+			},
+		},
+		LoginProvider{
+			Name:   "saml2aws",
+			Binary: "saml2aws",
+			// saml2aws records the principal with the credentials
+			Detect: has("x_principal_arn"),
+			Command: func(profile string, _ map[string]string) string {
+				return fmt.Sprintf("saml2aws login --profile %s --skip-prompt", profile)
+			},
+			Shortcut: func(profile string, _ map[string]string) string {
+				return fmt.Sprintf("saml2aws login --profile %s --skip-prompt\n", profile)
+			},
+		},
+		LoginProvider{
+			Name:   "aws-vault",
+			Binary: "aws-vault",
+			Detect: process("aws-vault"),
+			// the session aws-vault caches is used by the credential_process
+			Command: func(profile string, _ map[string]string) string {
+				return fmt.Sprintf("aws-vault exec %s -- aws sts get-caller-identity", profile)
+			},
+			Shortcut: func(profile string, _ map[string]string) string {
+				return fmt.Sprintf("eval \"$(aws-vault export --format=export-env %s)\"\n", profile)
+			},
+		},
+		LoginProvider{
+			Name:   "granted",
+			Binary: "assume",
+			Detect: func(config map[string]string) bool {
+				return has("granted_sso_start_url")(config) || process("granted")(config)
+			},
+			// assume can only export the credentials into the shell that
+			// sources it, the login profile caches them with --exec
+			Command: func(profile string, _ map[string]string) string {
+				return fmt.Sprintf("assume %s --exec -- aws sts get-caller-identity", profile)
+			},
+			Shortcut: func(profile string, _ map[string]string) string {
+				return fmt.Sprintf("source assume %s\n", profile)
+			},
+		},
+		LoginProvider{
+			Name:   "gimme-aws-creds",
+			Binary: "gimme-aws-creds",
+			Detect: process("gimme-aws-creds"),
+			Command: func(profile string, _ map[string]string) string {
+				return fmt.Sprintf("gimme-aws-creds --profile %s", profile)
+			},
+			Shortcut: func(profile string, _ map[string]string) string {
+				return fmt.Sprintf("gimme-aws-creds --profile %s\n", profile)
+			},
+		},
+		// the AWS SDKs fetch the credentials of these by themselves, there
+		// is nothing to log into
+		LoginProvider{
+			Name:   "credential_process",
+			Detect: has("credential_process"),
+		},
+		LoginProvider{
+			Name:   "web_identity",
+			Detect: has("web_identity_token_file"),
+		},
+	)
+}
@@ -0,0 +1,131 @@
+package aws
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/mhristof/germ/iterm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginRegistryFind(t *testing.T) {
+	r := DefaultLoginRegistry()
+
+	cases := []struct {
+		name     string
+		profile  string
+		config   map[string]string
+		logins   []Login
+		expected string
+	}{
+		{name: "azure", config: map[string]string{"azure_tenant_id": "foo"}, expected: "aws-azure-login"},
+		{name: "saml2aws", config: map[string]string{"x_principal_arn": "arn"}, expected: "saml2aws"},
+		{name: "aws-vault", config: map[string]string{"credential_process": "aws-vault export --format=json dev"}, expected: "aws-vault"},
+		{name: "granted keys", config: map[string]string{"granted_sso_start_url": "https://corp"}, expected: "granted"},
+		{name: "granted process", config: map[string]string{"credential_process": "granted credential-process --profile dev"}, expected: "granted"},
+		{name: "gimme-aws-creds", config: map[string]string{"credential_process": "gimme-aws-creds --output-format json"}, expected: "gimme-aws-creds"},
+		{name: "credential process", config: map[string]string{"credential_process": "/usr/local/bin/creds"}, expected: NoLogin},
+		{name: "web identity", config: map[string]string{"web_identity_token_file": "/token", "role_arn": "arn"}, expected: NoLogin},
+		{name: "mapped without login", profile: "ci", logins: []Login{{Match: "ci", Provider: "web_identity"}}, expected: NoLogin},
+		{name: "mapping", profile: "okta-dev", config: map[string]string{"x_principal_arn": "arn"}, logins: []Login{{Match: "okta-*", Provider: "gimme-aws-creds"}}, expected: "gimme-aws-creds"},
+		{name: "disabled", profile: "legacy", config: map[string]string{"azure_tenant_id": "foo"}, logins: []Login{{Match: "legacy", Provider: NoLogin}, {Match: "*", Provider: "saml2aws"}}, expected: NoLogin},
+		{name: "unknown", config: map[string]string{"region": "eu-west-1"}},
+	}
+
+	for _, test := range cases {
+		p, found := r.Find(test.profile, test.config, test.logins)
+		assert.Equal(t, test.expected != "", found, test.name)
+		assert.Equal(t, test.expected, p.Name, test.name)
+	}
+}
+
+func TestLoginRegistryValidate(t *testing.T) {
+	r := DefaultLoginRegistry()
+
+	assert.NoError(t, r.Validate([]Login{{Match: "okta-*", Provider: "gimme-aws-creds"}, {Match: "legacy", Provider: NoLogin}}))
+
+	err := r.Validate([]Login{{Match: "[", Provider: "saml2aws"}, {Match: "dev", Provider: "okta"}})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid glob "["`)
+	assert.Contains(t, err.Error(), `unknown login provider "okta" for dev`)
+
+	assert.Panics(t, func() { r.Register(LoginProvider{Name: "saml2aws"}) })
+}
+
+func TestDefaultLoginRegistryShortcuts(t *testing.T) {
+	cases := map[string]string{
+		"aws-azure-login": "aws-azure-login --profile dev --no-prompt\n",
+		"saml2aws":        "saml2aws login --profile dev --skip-prompt\n",
+		"aws-vault":       "eval \"$(aws-vault export --format=export-env dev)\"\n",
+		"granted":         "source assume dev\n",
+		"gimme-aws-creds": "gimme-aws-creds --profile dev\n",
+	}
+
+	r := DefaultLoginRegistry()
+	for _, name := range r.Names() {
+		p, _ := r.Get(name)
+		if p.Command == nil {
+			continue
+		}
+
+		assert.NotNil(t, p.Shortcut, name)
+		assert.Equal(t, cases[name], p.LoginShortcut("dev", nil), name)
+	}
+
+	granted, _ := r.Get("granted")
+	assert.Equal(t, "assume dev --exec -- aws sts get-caller-identity", granted.Command("dev", nil))
+}
+
+func TestProfilesLoginProviders(t *testing.T) {
+	bin := t.TempDir()
+	for _, tool := range []string{"saml2aws", "aws"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(bin, tool), []byte("#!/bin/sh\n"), 0o755))
+	}
+
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	config := filepath.Join(t.TempDir(), "config")
+	err := os.WriteFile(config, []byte(heredoc.Doc(`
+		[profile saml]
+		x_principal_arn = arn:aws:iam::123456789012:saml-provider/corp
+
+		[profile ci]
+		web_identity_token_file = /var/run/token
+		role_arn = arn:aws:iam::123456789012:role/ci
+
+		[profile okta]
+		region = eu-west-1
+
+		[profile legacy]
+		azure_tenant_id = foo
+	`)), 0o644)
+	assert.NoError(t, err)
+
+	s := Source{
+		Config: config,
+		Logins: []Login{{Match: "okta", Provider: "gimme-aws-creds"}, {Match: "legacy", Provider: NoLogin}},
+	}
+
+	profiles, err := s.profiles(nil, "")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot find executable gimme-aws-creds")
+
+	byName := map[string]iterm.Profile{}
+	for _, p := range profiles {
+		byName[p.Name] = p
+	}
+
+	assert.Contains(t, byName["login-saml"].Command, "saml2aws login --profile saml --skip-prompt")
+	assert.Equal(t, "saml2aws", byName["login-saml"].Metadata["login"])
+	assert.Equal(t, "saml2aws login --profile saml --skip-prompt\n", byName["saml"].KeyboardMap[iterm.KeyboardSortcutAltA].Text)
+	assert.Contains(t, byName, "ci")
+	assert.NotContains(t, byName, "login-ci")
+	assert.NotContains(t, byName["ci"].KeyboardMap, iterm.KeyboardSortcutAltA)
+
+	assert.Contains(t, byName, "okta")
+	assert.NotContains(t, byName, "login-okta")
+	assert.Contains(t, byName, "legacy")
+	assert.NotContains(t, byName, "login-legacy")
+}
@@ -1,117 +1,308 @@
 package aws
 
 import (
+	"context"
+	stderrors "errors"
 	"fmt"
-	"os"
-	"os/exec"
-	"path/filepath"
 	"strings"
 
 	"github.com/mhristof/germ/iterm"
 	"github.com/mhristof/germ/profile"
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
-	"github.com/zieckey/goini"
 )
 
-func Profiles(prefix, config string) []iterm.Profile {
-	ini := goini.New()
-	err := ini.ParseFile(config)
-	if err != nil {
-		log.Warn().Err(err).Str("config", config).Msg("parse INI failed")
-		return nil
+// Source generates the profiles found in an AWS config and credentials
+// file.
+type Source struct {
+	Config      string
+	Credentials string
+	// Providers log into the profiles, DefaultLoginRegistry if nil.
+	Providers *LoginRegistry
+	// Logins pick the provider of the profiles the providers cannot
+	// detect.
+	Logins []Login
+}
+
+func (s *Source) Name() string {
+	return "aws"
+}
+
+func (s *Source) Inputs() []string {
+	if s.Credentials == "" {
+		return []string{s.Config}
 	}
 
+	return []string{s.Config, s.Credentials}
+}
+
+func (s *Source) Generate(ctx context.Context) ([]iterm.Profile, error) {
+	return s.profiles(iterm.GenerationFrom(ctx), "")
+}
+
+// Profiles creates a profile, and a login profile where needed, for every
+// section of the AWS config and credentials files, see Load, and a login
+// profile for every sso-session. Sections that fail are reported in the
+// returned error while the rest of the profiles are still returned. gen may
+// be nil.
+func Profiles(gen *iterm.Generation, prefix, config, credentials string) ([]iterm.Profile, error) {
+	s := Source{Config: config, Credentials: credentials}
+
+	return s.profiles(gen, prefix)
+}
+
+func (s *Source) profiles(gen *iterm.Generation, prefix string) ([]iterm.Profile, error) {
 	var profiles []iterm.Profile
-	for name, section := range ini.GetAll() {
-		if name == "" {
+	var errs []error
+
+	// the profiles of the config are still generated when the credentials
+	// cannot be read
+	cfg, err := Load(s.Config, s.Credentials)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	providers := s.Providers
+	if providers == nil {
+		providers = DefaultLoginRegistry()
+	}
+
+	logins := s.Logins
+
+	err = providers.Validate(logins)
+	if err != nil {
+		errs = append(errs, errors.Wrap(err, "invalid logins"))
+		logins = nil
+	}
+
+	graph, err := NewGraph(cfg)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	// the index of the main profile and the name of the login profile of
+	// every section, for the shortcuts of the role chains
+	mains := map[string]int{}
+	loginNames := map[string]string{}
+
+	for _, session := range cfg.Sessions {
+		if !cfg.used(session.Name) {
 			continue
 		}
-		tName := strings.TrimPrefix(name, "profile ")
-		
+
+		loginProfile, err := createSessionLoginProfile(gen, session)
+		if err != nil {
+			errs = append(errs, err)
+		}
+
+		if loginProfile != nil {
+			profiles = append(profiles, *loginProfile)
+		}
+	}
+
+	for name, section := range cfg.Profiles {
+		provider, found := providers.Find(name, section, logins)
+
 		// Create main profile
-		mainProfile := createAWSProfile(prefix, tName, section)
+		mainProfile, err := createAWSProfile(gen, prefix, name, section)
+		if err != nil {
+			errs = append(errs, err)
+		}
+
+		if found && provider.Name != NoLogin && !chained(section) {
+			mainProfile.Bind(iterm.KeyboardSortcutAltA, iterm.KeyboardMap{
+				Action: iterm.KeyboardSendText,
+				Text:   provider.LoginShortcut(name, section),
+			})
+		}
+
+		mains[name] = len(profiles)
 		profiles = append(profiles, *mainProfile)
-		
+
+		// a long lived access key needs no login, unless a provider, like
+		// saml2aws, writes it
+		if (found && provider.Name == NoLogin) || (!found && static(section)) {
+			continue
+		}
+
 		// Create login profile if needed
-		if loginProfile := createLoginProfile(tName, section); loginProfile != nil {
+		loginProfile, err := createLoginProfile(gen, name, section, provider)
+		if err != nil {
+			errs = append(errs, err)
+		}
+
+		if loginProfile != nil {
+			loginNames[name] = loginProfile.Name
 			profiles = append(profiles, *loginProfile)
 		}
 	}
 
-	return profiles
+	for name, i := range mains {
+		if _, ok := cfg.Profiles[name]["source_profile"]; !ok {
+			continue
+		}
+
+		if km, ok := rootShortcut(graph, cfg, name, loginNames); ok {
+			profiles[i].Bind(iterm.KeyboardSortcutAltA, km)
+		}
+	}
+
+	return profiles, stderrors.Join(errs...)
+}
+
+// rootShortcut returns the Alt+A shortcut of a chained profile, logging into
+// the root of its chain instead of its source profile.
+func rootShortcut(graph *Graph, cfg Config, name string, loginNames map[string]string) (iterm.KeyboardMap, bool) {
+	root, ok := graph.Root(name)
+	if !ok || root == name {
+		return iterm.KeyboardMap{}, false
+	}
+
+	if session, ok := strings.CutPrefix(root, sessionPrefix); ok {
+		return iterm.KeyboardMap{Action: iterm.KeyboardSplitHorizontallyProfile, Text: SessionLoginName(session)}, true
+	}
+
+	if login, ok := loginNames[root]; ok {
+		return iterm.KeyboardMap{Action: iterm.KeyboardSplitHorizontallyProfile, Text: login}, true
+	}
+
+	if _, ok := cfg.Profiles[root]["sso_account_id"]; ok {
+		return iterm.KeyboardMap{
+			Action: iterm.KeyboardSendText,
+			Text:   fmt.Sprintf("AWS_PROFILE=%s aws sso login\n", root),
+		}, true
+	}
+
+	return iterm.KeyboardMap{}, false
 }
 
-func createAWSProfile(prefix, name string, config map[string]string) *iterm.Profile {
-	builder := profile.NewAWSProfileBuilder(name).
-		WithAWSProfile(name).
+func createAWSProfile(gen *iterm.Generation, prefix, name string, config map[string]string) (*iterm.Profile, error) {
+	builder := profile.NewAWSProfileBuilder(name)
+	builder.WithGeneration(gen)
+	builder.WithIdentity(identity(config))
+
+	for key, value := range metadata(config) {
+		builder.WithMetadata(key, value)
+	}
+
+	builder.WithAWSProfile(name).
 		WithPrefix(prefix)
-	
+
+	withSession(builder.Builder, config)
+	err := withStaticCredentials(builder.Builder, config)
+
 	// Add any additional config from the section
-	for key, value := range config {
+	for key, value := range withoutSecrets(config) {
 		builder.WithConfig(key, value)
 	}
-	
-	return builder.Build()
+
+	prof, buildErr := builder.Build()
+
+	return prof, stderrors.Join(errors.Wrapf(err, "profile %s", name), buildErr)
 }
 
-func createLoginProfile(name string, config map[string]string) *iterm.Profile {
+// chained returns true for the profiles logging in through another one,
+// their source profile, SSO or their sso-session.
+func chained(config map[string]string) bool {
 	_, sourceProfile := config["source_profile"]
 	_, sso := config["sso_account_id"]
+	_, session := config["sso_session"]
+
+	return sourceProfile || sso || session
+}
+
+// createLoginProfile creates the login profile of the section with the
+// provider, or a placeholder one if the provider has no name.
+func createLoginProfile(gen *iterm.Generation, name string, config map[string]string, provider LoginProvider) (*iterm.Profile, error) {
+	// Only create login profile if it's not a source profile or SSO profile,
+	// the profiles of an sso-session share its login profile
+	if chained(config) {
+		return nil, nil
+	}
+
+	// If no specific login command, create a basic login profile
+	// This maintains compatibility with the original behavior
+	loginCmd := fmt.Sprintf("echo 'No login command configured for %s'", name)
+	shortcut := fmt.Sprintf("AWS_PROFILE=%s aws sso login\n", name)
+
+	if provider.Name != "" {
+		var err error
+
+		loginCmd, err = provider.LoginCommand(name, config)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot create login profile for %s", name)
+		}
+
+		shortcut = provider.LoginShortcut(name, config)
+	}
+
+	builder := profile.NewAWSProfileBuilder(fmt.Sprintf("login-%s", name))
+	builder.WithGeneration(gen)
+	builder.WithIdentity(LoginIdentity(name, config))
+
+	for key, value := range metadata(config) {
+		builder.WithMetadata(key, value)
+	}
+	builder.WithAWSLoginCommand(name, loginCmd)
+	builder.WithAltAShortcut(shortcut)
+	builder.WithMetadata("login", provider.Name)
 
-	// Only create login profile if it's not a source profile or SSO profile
-	if sourceProfile || sso {
-		return nil
-	}
-	
-	loginCmd := buildLoginCommand(name, config)
-	if loginCmd == "" {
-		// If no specific login command, create a basic login profile
-		// This maintains compatibility with the original behavior
-		loginCmd = fmt.Sprintf("echo 'No login command configured for %s'", name)
-	}
-	
-	builder := profile.NewAWSProfileBuilder(fmt.Sprintf("login-%s", name)).
-		WithAWSLoginCommand(name, loginCmd)
-	
 	// Add any additional config from the section
-	for key, value := range config {
+	for key, value := range withoutSecrets(config) {
 		builder.WithConfig(key, value)
 	}
-	
+
 	log.Debug().
 		Str("profile", name).
 		Str("loginProfile", fmt.Sprintf("login-%s", name)).
 		Msg("create login profile")
-	
+
 	return builder.Build()
 }
 
-func buildLoginCommand(name string, config map[string]string) string {
-	var tool, toolCmd string
-	_, azure := config["azure_tenant_id"]
-	_, ssoAccountId := config["sso_account_id"]
+// LoginIdentity returns the identity of the login profile of the section,
+// see iterm.Profiles.FindIdentity.
+func LoginIdentity(name string, config map[string]string) string {
+	if id := identity(config); id != "" {
+		return "login:" + id
+	}
+
+	return "name:login-" + name
+}
 
-	if azure {
-		tool = "aws-azure-login"
-		toolCmd = fmt.Sprintf("%s --no-prompt", tool)
-	} else if ssoAccountId {
-		tool = "aws"
-		toolCmd = "aws sso login"
-	} else {
-		return ""
+// identity returns the account and role the section connects to, or an
+// empty string if the section has neither a role_arn nor an SSO role.
+func identity(config map[string]string) string {
+	if arn, ok := config["role_arn"]; ok {
+		parts := strings.SplitN(arn, ":", 6)
+		if len(parts) == 6 {
+			return fmt.Sprintf("aws:%s:%s", parts[4], strings.TrimPrefix(parts[5], "role/"))
+		}
 	}
 
-	bin, err := exec.LookPath(tool)
-	if err != nil {
-		log.Fatal().Err(err).
-			Str("tool", tool).
-			Msg("cannot find executable")
+	account, okAccount := config["sso_account_id"]
+	role, okRole := config["sso_role_name"]
+	if okAccount && okRole {
+		return fmt.Sprintf("aws:%s:%s", account, role)
+	}
+
+	return ""
+}
+
+// metadata returns the account, role and region of the section for the
+// badge templates.
+func metadata(config map[string]string) map[string]string {
+	ret := map[string]string{
+		"region": config["region"],
+	}
+
+	if id := identity(config); id != "" {
+		parts := strings.SplitN(id, ":", 3)
+		ret["account"] = parts[1]
+		ret["role"] = parts[2]
 	}
 
-	return fmt.Sprintf(
-		"bash -c 'AWS_PROFILE=%s PATH=%s NODE_EXTRA_CA_CERTS=%s %s || sleep 60'",
-		name, filepath.Dir(bin), os.Getenv("NODE_EXTRA_CA_CERTS"), toolCmd,
-	)
+	return ret
 }
 
 // Regions retrieve all AWS regions. This list is generated from
@@ -1,9 +1,14 @@
 package aws
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/MakeNowJust/heredoc"
 	"github.com/mhristof/germ/iterm"
 	"github.com/stretchr/testify/assert"
 )
@@ -51,11 +56,14 @@ func TestAdd(t *testing.T) {
 			name := fmt.Sprintf("%d", i)
 			
 			// Create main profile
-			mainProfile := createAWSProfile("", name, cfg)
+			mainProfile, err := createAWSProfile(nil, "", name, cfg)
+			assert.NoError(t, err)
 			profiles = append(profiles, *mainProfile)
 			
 			// Create login profile if needed
-			if loginProfile := createLoginProfile(name, cfg); loginProfile != nil {
+			loginProfile, err := createLoginProfile(nil, name, cfg, LoginProvider{})
+			assert.NoError(t, err)
+			if loginProfile != nil {
 				profiles = append(profiles, *loginProfile)
 			}
 		}
@@ -75,3 +83,270 @@ func TestAdd(t *testing.T) {
 
 	}
 }
+
+func TestProfilesMissingLoginTool(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	config := filepath.Join(t.TempDir(), "config")
+	err := os.WriteFile(config, []byte(heredoc.Doc(`
+		[profile azure]
+		azure_tenant_id = foo
+
+		[profile child]
+		source_profile = azure
+	`)), 0o644)
+	assert.NoError(t, err)
+
+	profiles, err := Profiles(nil, "", config, "")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "aws-azure-login")
+
+	var names []string
+	for _, p := range profiles {
+		names = append(names, p.Name)
+	}
+
+	assert.ElementsMatch(t, []string{"azure", "child"}, names)
+}
+
+func TestProfilesMissingConfig(t *testing.T) {
+	dir := t.TempDir()
+	profiles, err := Profiles(nil, "", filepath.Join(dir, "config"), filepath.Join(dir, "credentials"))
+	assert.NoError(t, err)
+	assert.Empty(t, profiles)
+}
+
+func TestIdentity(t *testing.T) {
+	cases := []struct {
+		name     string
+		config   map[string]string
+		expected string
+	}{
+		{
+			name:     "role arn",
+			config:   map[string]string{"role_arn": "arn:aws:iam::123456789012:role/admin", "source_profile": "root"},
+			expected: "aws:123456789012:admin",
+		},
+		{
+			name:     "sso",
+			config:   map[string]string{"sso_account_id": "123456789012", "sso_role_name": "ReadOnly"},
+			expected: "aws:123456789012:ReadOnly",
+		},
+		{
+			name:   "static credentials",
+			config: map[string]string{"region": "eu-west-1"},
+		},
+	}
+
+	for _, test := range cases {
+		assert.Equal(t, test.expected, identity(test.config), test.name)
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	prof, err := createAWSProfile(nil, "", "payments", map[string]string{
+		"role_arn":       "arn:aws:iam::123456789012:role/admin",
+		"source_profile": "root",
+		"region":         "eu-west-1",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"name":    "payments",
+		"unique":  prof.Metadata["unique"],
+		"profile": "payments",
+		"account": "123456789012",
+		"role":    "admin",
+		"region":  "eu-west-1",
+	}, prof.Metadata)
+	assert.NotEmpty(t, prof.Metadata["unique"])
+}
+
+func TestProfilesCredentials(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "config")
+	credentials := filepath.Join(dir, "credentials")
+
+	err := os.WriteFile(config, []byte(heredoc.Doc(`
+		[profile dev]
+		region = eu-west-1
+		source_profile = ci
+		role_arn = arn:aws:iam::123456789012:role/admin
+
+		[profile ci]
+		region = us-east-1
+	`)), 0o644)
+	assert.NoError(t, err)
+
+	created := time.Now().Add(-49 * time.Hour).Format(time.RFC3339)
+	err = os.WriteFile(credentials, []byte(heredoc.Docf(`
+		[ci]
+		aws_access_key_id = AKIAEXAMPLE
+		aws_secret_access_key = SECRETEXAMPLE
+		region = eu-west-2
+		aws_access_key_created = %s
+
+		[deploy]
+		aws_access_key_id = AKIAEXAMPLE2
+		aws_secret_access_key = SECRETEXAMPLE2
+		aws_session_token = TOKENEXAMPLE
+
+		[broken]
+		aws_access_key_id = AKIAEXAMPLE3
+		aws_access_key_created = yesterday
+	`, created)), 0o644)
+	assert.NoError(t, err)
+
+	profiles, err := Profiles(nil, "", config, credentials)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid aws_access_key_created")
+
+	byName := map[string]iterm.Profile{}
+	for _, p := range profiles {
+		byName[p.Name] = p
+	}
+
+	assert.Contains(t, byName, "dev")
+	assert.Contains(t, byName, "deploy")
+	assert.Contains(t, byName, "broken")
+	assert.NotContains(t, byName["dev"].Tags, StaticCredentialsTag)
+
+	ci := byName["ci"]
+	assert.Contains(t, ci.Tags, StaticCredentialsTag)
+	assert.NotContains(t, ci.Tags, "key-age=2d", "tags change the profile every day")
+	assert.Equal(t, "2d", ci.Metadata["key_age"])
+	assert.NotContains(t, byName, "login-ci", "static keys need no login")
+	assert.NotContains(t, byName, "login-deploy")
+	assert.Equal(t, "eu-west-2", ci.Metadata["region"], "the credentials win over the config")
+	assert.Contains(t, byName["deploy"].Tags, StaticCredentialsTag)
+
+	data, err := json.Marshal(profiles)
+	assert.NoError(t, err)
+
+	for _, secret := range []string{"AKIAEXAMPLE", "SECRETEXAMPLE", "TOKENEXAMPLE"} {
+		assert.NotContains(t, string(data), secret)
+	}
+}
+
+func TestProfilesCredentialsOnly(t *testing.T) {
+	credentials := filepath.Join(t.TempDir(), "credentials")
+	err := os.WriteFile(credentials, []byte(heredoc.Doc(`
+		[default]
+		aws_access_key_id = AKIAEXAMPLE
+		aws_secret_access_key = SECRETEXAMPLE
+	`)), 0o644)
+	assert.NoError(t, err)
+
+	profiles, err := Profiles(nil, "", filepath.Join(t.TempDir(), "config"), credentials)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, profiles)
+	assert.Equal(t, "default", profiles[0].Name)
+}
+
+func TestProfilesBrokenCredentials(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "config")
+	err := os.WriteFile(config, []byte(heredoc.Doc(`
+		[profile dev]
+		region = eu-west-1
+	`)), 0o644)
+	assert.NoError(t, err)
+
+	// a directory cannot be parsed
+	profiles, err := Profiles(nil, "", config, t.TempDir())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot parse")
+	assert.NotEmpty(t, profiles)
+	assert.Equal(t, "dev", profiles[0].Name)
+}
+
+func TestProfilesSSOSession(t *testing.T) {
+	bin := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(bin, "aws"), []byte("#!/bin/sh\n"), 0o755))
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	config := filepath.Join(t.TempDir(), "config")
+	err := os.WriteFile(config, []byte(heredoc.Doc(`
+		[sso-session corp]
+		sso_start_url = https://corp.awsapps.com/start
+		sso_region = eu-west-1
+		sso_registration_scopes = sso:account:access
+
+		[sso-session unused]
+		sso_start_url = https://unused.awsapps.com/start
+		sso_region = eu-west-1
+
+		[profile dev]
+		sso_session = corp
+		sso_account_id = 123456789012
+		sso_role_name = admin
+		region = us-east-1
+
+		[profile prod]
+		sso_session = corp
+		sso_account_id = 210987654321
+		sso_role_name = admin
+
+		[profile other]
+		sso_session = missing
+		sso_account_id = 111111111111
+		sso_role_name = admin
+	`)), 0o644)
+	assert.NoError(t, err)
+
+	profiles, err := Profiles(nil, "", config, "")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "profile other references unknown sso-session missing")
+
+	var names []string
+	byName := map[string]iterm.Profile{}
+	for _, p := range profiles {
+		names = append(names, p.Name)
+		byName[p.Name] = p
+	}
+
+	assert.ElementsMatch(t, []string{"login-sso-corp", "dev", "prod", "other"}, names)
+
+	login := byName["login-sso-corp"]
+	assert.Contains(t, login.Command, "aws sso login --sso-session corp")
+	assert.Contains(t, login.Tags, "sso-session=corp")
+
+	dev := byName["dev"]
+	assert.Contains(t, dev.Tags, "sso-session=corp")
+	assert.Equal(t, "corp", dev.Metadata["sso_session"])
+	assert.Equal(t, iterm.KeyboardMap{
+		Action: iterm.KeyboardSplitHorizontallyProfile,
+		Text:   "login-sso-corp",
+	}, dev.KeyboardMap[iterm.KeyboardSortcutAltA])
+}
+
+func TestSession(t *testing.T) {
+	config := Config{
+		Profiles: map[string]map[string]string{
+			"dev":    {"sso_session": "corp"},
+			"legacy": {"sso_start_url": "https://legacy", "sso_region": "us-east-1"},
+			"other":  {"sso_session": "missing"},
+			"static": {"region": "eu-west-1"},
+		},
+		Sessions: map[string]Session{
+			"corp": {Name: "corp", StartURL: "https://corp", Region: "eu-west-1"},
+		},
+	}
+
+	cases := []struct {
+		name     string
+		profile  string
+		expected Session
+		ok       bool
+	}{
+		{name: "sso-session", profile: "dev", expected: config.Sessions["corp"], ok: true},
+		{name: "legacy keys", profile: "legacy", expected: Session{StartURL: "https://legacy", Region: "us-east-1"}, ok: true},
+		{name: "unknown session", profile: "other"},
+		{name: "not SSO", profile: "static"},
+		{name: "missing profile", profile: "missing"},
+	}
+
+	for _, test := range cases {
+		session, ok := config.Session(test.profile)
+		assert.Equal(t, test.ok, ok, test.name)
+		assert.Equal(t, test.expected, session, test.name)
+	}
+}
@@ -0,0 +1,115 @@
+package aws
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/mhristof/germ/iterm"
+	"github.com/mhristof/germ/profile"
+	"github.com/pkg/errors"
+)
+
+// sessionPrefix starts the names of the sso-session sections of the config.
+const sessionPrefix = "sso-session "
+
+// Session is an sso-session section of the AWS config, shared by the
+// profiles that reference it with sso_session. Profiles with the legacy
+// sso_start_url and sso_region keys get a session without a name.
+type Session struct {
+	Name     string
+	StartURL string
+	Region   string
+	Scopes   string
+}
+
+func newSession(name string, section map[string]string) Session {
+	return Session{
+		Name:     name,
+		StartURL: section["sso_start_url"],
+		Region:   section["sso_region"],
+		Scopes:   section["sso_registration_scopes"],
+	}
+}
+
+// Config is the AWS config and credentials files, see Load.
+type Config struct {
+	// Profiles are the keys of each profile by name.
+	Profiles map[string]map[string]string
+	// Sessions are the sso-session sections by name.
+	Sessions map[string]Session
+}
+
+// Session returns the SSO session of the profile, either the sso-session it
+// references or one made of its legacy keys. It returns false if the profile
+// does not use SSO or references an unknown session.
+func (c Config) Session(profile string) (Session, bool) {
+	section, ok := c.Profiles[profile]
+	if !ok {
+		return Session{}, false
+	}
+
+	if name, ok := section["sso_session"]; ok {
+		session, ok := c.Sessions[name]
+		return session, ok
+	}
+
+	if _, ok := section["sso_start_url"]; ok {
+		return newSession("", section), true
+	}
+
+	return Session{}, false
+}
+
+// used returns true if a profile references the sso-session.
+func (c Config) used(session string) bool {
+	for _, section := range c.Profiles {
+		if section["sso_session"] == session {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SessionLoginName returns the name of the login profile of the session.
+func SessionLoginName(session string) string {
+	return fmt.Sprintf("login-sso-%s", session)
+}
+
+// withSession tags the profile of a section referencing an sso-session and
+// binds Alt+A to the login profile of the session.
+func withSession(b *profile.Builder, config map[string]string) {
+	name, ok := config["sso_session"]
+	if !ok {
+		return
+	}
+
+	b.WithTags(fmt.Sprintf("sso-session=%s", name))
+	b.WithMetadata("sso_session", name)
+	b.WithKeyboardShortcut(iterm.KeyboardSortcutAltA, iterm.KeyboardSplitHorizontallyProfile, SessionLoginName(name))
+}
+
+// createSessionLoginProfile creates the profile logging into the session,
+// shared by all the profiles of the session.
+func createSessionLoginProfile(gen *iterm.Generation, session Session) (*iterm.Profile, error) {
+	bin, err := exec.LookPath("aws")
+	if err != nil {
+		return nil, errors.Wrapf(errors.Wrap(err, "cannot find executable aws"), "cannot create login profile for sso-session %s", session.Name)
+	}
+
+	builder := profile.NewBuilder(SessionLoginName(session.Name))
+	builder.WithGeneration(gen)
+	builder.WithCommand(fmt.Sprintf(
+		"bash -c 'PATH=%s NODE_EXTRA_CA_CERTS=%s aws sso login --sso-session %s || sleep 60'",
+		filepath.Dir(bin), os.Getenv("NODE_EXTRA_CA_CERTS"), session.Name,
+	))
+	builder.WithAltAShortcut(fmt.Sprintf("aws sso login --sso-session %s\n", session.Name))
+	builder.WithTags(fmt.Sprintf("sso-session=%s", session.Name))
+	builder.WithMetadata("sso_session", session.Name)
+	builder.WithMetadata("region", session.Region)
+	builder.WithMetadata("sso_start_url", session.StartURL)
+
+	return builder.Build()
+}
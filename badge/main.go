@@ -0,0 +1,105 @@
+// Package badge renders the badge text of the profiles from the templates in
+// the `badges` section of germ.yaml.
+package badge
+
+import (
+	"bytes"
+	stderrors "errors"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/mhristof/germ/iterm"
+	"github.com/pkg/errors"
+)
+
+// Default is the template of the sources without their own.
+const Default = "default"
+
+// Templates are the badge templates by source name. The templates get the
+// profile metadata, such as .account, .role or .cluster, and the current
+// badge text as .badge.
+type Templates map[string]string
+
+// parse returns the templates by source, invalid templates are reported and
+// left out.
+func (t Templates) parse() (map[string]*template.Template, error) {
+	var names []string
+	for name := range t {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	ret := map[string]*template.Template{}
+	var errs []error
+
+	for _, name := range names {
+		tmpl, err := template.New(name).Option("missingkey=zero").Parse(t[name])
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "invalid badge template for %s", name))
+			continue
+		}
+
+		ret[name] = tmpl
+	}
+
+	return ret, stderrors.Join(errs...)
+}
+
+// Render returns the badge text of the profile from tmpl.
+func Render(tmpl *template.Template, p iterm.Profile) (string, error) {
+	data := map[string]string{}
+	for key, value := range p.Metadata {
+		data[key] = value
+	}
+
+	data["badge"] = p.BadgeText
+
+	var buf bytes.Buffer
+
+	err := tmpl.Execute(&buf, data)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot render the badge of %s", p.Name)
+	}
+
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// Apply sets the badge text of the profiles from the template of their
+// source, the source metadata key, or the default one. Profiles without a
+// template and the profile named skip keep their badge.
+func Apply(templates Templates, profiles []iterm.Profile, skip string) error {
+	if len(templates) == 0 {
+		return nil
+	}
+
+	parsed, err := templates.parse()
+	errs := []error{err}
+
+	for i := range profiles {
+		if profiles[i].Name == skip {
+			continue
+		}
+
+		name := profiles[i].Metadata["source"]
+		if _, ok := templates[name]; !ok {
+			name = Default
+		}
+
+		tmpl, ok := parsed[name]
+		if !ok {
+			continue
+		}
+
+		text, err := Render(tmpl, profiles[i])
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		profiles[i].BadgeText = text
+	}
+
+	return stderrors.Join(errs...)
+}
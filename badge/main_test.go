@@ -0,0 +1,72 @@
+package badge
+
+import (
+	"testing"
+
+	"github.com/mhristof/germ/iterm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApply(t *testing.T) {
+	templates := Templates{
+		"default": "{{.name}}",
+		"aws":     "{{.role}}@{{.account}}\n{{.environment}}",
+		"ssm":     "{{.instance}} {{.az}}",
+		"vim":     "{{.badge}} ({{.missing}})",
+		"k8s":     "{{.cluster",
+	}
+
+	profiles := []iterm.Profile{
+		{
+			Name:      "payments",
+			BadgeText: "payments\nfoo",
+			Metadata: map[string]string{
+				"source":      "aws",
+				"account":     "123456789012",
+				"role":        "admin",
+				"environment": "prod",
+			},
+		},
+		{
+			Name:     "web",
+			Metadata: map[string]string{"source": "ssm", "instance": "i-123", "az": "eu-west-1a"},
+		},
+		{
+			Name:      "vim",
+			BadgeText: "vim",
+			Metadata:  map[string]string{"source": "vim"},
+		},
+		{
+			Name:     "custom",
+			Metadata: map[string]string{"source": "config", "name": "custom"},
+		},
+		{
+			Name:      "k8s-foo",
+			BadgeText: "k8s-foo",
+			Metadata:  map[string]string{"source": "k8s", "cluster": "foo"},
+		},
+		{
+			Name:      "parent",
+			BadgeText: "parent",
+			Metadata:  map[string]string{"source": "0-parent", "name": "other"},
+		},
+	}
+
+	err := Apply(templates, profiles, "parent")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid badge template for k8s")
+
+	assert.Equal(t, "admin@123456789012\nprod", profiles[0].BadgeText)
+	assert.Equal(t, "i-123 eu-west-1a", profiles[1].BadgeText)
+	assert.Equal(t, "vim ()", profiles[2].BadgeText)
+	assert.Equal(t, "custom", profiles[3].BadgeText)
+	assert.Equal(t, "k8s-foo", profiles[4].BadgeText, "invalid templates are skipped")
+	assert.Equal(t, "parent", profiles[5].BadgeText)
+}
+
+func TestApplyWithoutTemplates(t *testing.T) {
+	profiles := []iterm.Profile{{Name: "foo", BadgeText: "foo\nbar"}}
+
+	assert.NoError(t, Apply(nil, profiles, ""))
+	assert.Equal(t, "foo\nbar", profiles[0].BadgeText)
+}
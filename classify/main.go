@@ -0,0 +1,314 @@
+// Package classify assigns the generated profiles to environments, such as
+// prod or sandbox, with the `classification` section of germ.yaml and
+// colors and badges them accordingly.
+package classify
+
+import (
+	stderrors "errors"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/mhristof/germ/iterm"
+	"github.com/mhristof/germ/scheme"
+	"github.com/pkg/errors"
+)
+
+// Tag is the prefix of the tag added to every classified profile, for
+// example environment=prod.
+const Tag = "environment="
+
+// Environment is the look of the profiles classified into it.
+type Environment struct {
+	// Scheme is applied before the palette.
+	Scheme scheme.Ref
+	// Palette sets the profile colors by name, see iterm.Profile.SetColor.
+	Palette map[string]string
+	// Badge is prepended to the badge text.
+	Badge string
+	// TabColor is the tab color, as #rrggbb.
+	TabColor string `mapstructure:"tab_color"`
+}
+
+// Rule classifies the profiles it matches into Environment. All the set
+// fields must match, the list fields match if any of their items does.
+type Rule struct {
+	Environment string
+	// Accounts are the AWS account IDs of the profile.
+	Accounts []string
+	// Aliases are globs matched against the AWS account alias, see
+	// FactsOf.
+	Aliases []string
+	// Tag is a glob matched against the profile tags.
+	Tag string
+	// Regex is a regular expression matched against the profile name.
+	Regex string
+	// Cluster is a glob matched against the kubernetes cluster name.
+	Cluster string
+}
+
+// Config is the classification section of germ.yaml.
+type Config struct {
+	Environments map[string]Environment
+	// Rules are tried in order, the first match wins.
+	Rules []Rule
+
+	// implicit is set by Default. Its profiles are not tagged, the tag
+	// would show up as a change of every profile for the people who never
+	// configured a classification.
+	implicit bool
+}
+
+// Default returns the classification used when germ.yaml has none, it
+// guesses production from the profile name.
+func Default() Config {
+	return Config{
+		Environments: map[string]Environment{
+			"prod":    {Palette: map[string]string{"background": "#400000"}},
+			"nonprod": {},
+		},
+		Rules: []Rule{
+			{Environment: "nonprod", Regex: "nonprd|nonprod"},
+			{Environment: "prod", Regex: "prod|prd"},
+		},
+		implicit: true,
+	}
+}
+
+// Facts are the properties of a profile the rules match against.
+type Facts struct {
+	Accounts []string
+	Aliases  []string
+	Clusters []string
+	// Hosts are the SSH hosts, the names of the profiles tagged ssh.
+	Hosts []string
+}
+
+// FactsOf returns the accounts, aliases, clusters and hosts of the profile
+// from its identity, its name and its account= and alias= tags. The AWS
+// config has no account alias, the profiles of the AWS config and the
+// kubernetes profiles using one are usually named after it, so the AWS
+// profile name is an alias too.
+func FactsOf(p iterm.Profile) Facts {
+	var ret Facts
+
+	if name, ok := p.Metadata["profile"]; ok {
+		ret.Aliases = append(ret.Aliases, name)
+	}
+
+	id := strings.TrimPrefix(p.Identity, "login:")
+	switch {
+	case strings.HasPrefix(id, "aws:"):
+		parts := strings.SplitN(id, ":", 3)
+		ret.Accounts = append(ret.Accounts, parts[1])
+	case strings.HasPrefix(id, "k8s:"):
+		cluster := strings.TrimPrefix(id, "k8s:")
+		ret.Clusters = append(ret.Clusters, cluster)
+
+		// EKS names the clusters after their ARN
+		if i := strings.LastIndex(cluster, "/"); i >= 0 {
+			ret.Clusters = append(ret.Clusters, cluster[i+1:])
+		}
+	}
+
+	if p.HasTag("ssh") {
+		ret.Hosts = append(ret.Hosts, p.Name)
+	}
+
+	for _, tag := range p.Tags {
+		switch {
+		case strings.HasPrefix(tag, "account="):
+			ret.Accounts = append(ret.Accounts, strings.TrimPrefix(tag, "account="))
+		case strings.HasPrefix(tag, "alias="):
+			ret.Aliases = append(ret.Aliases, strings.TrimPrefix(tag, "alias="))
+		}
+	}
+
+	return ret
+}
+
+// Matches returns true if the profile is selected by the rule.
+func (r Rule) Matches(p iterm.Profile) (bool, error) {
+	facts := FactsOf(p)
+
+	if len(r.Accounts) > 0 && !matchAny(r.Accounts, facts.Accounts, equal) {
+		return false, nil
+	}
+
+	if len(r.Aliases) > 0 {
+		ok, err := anyGlob(r.Aliases, facts.Aliases)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+
+	if r.Tag != "" {
+		ok, err := anyGlob([]string{r.Tag}, p.Tags)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+
+	if r.Cluster != "" {
+		ok, err := anyGlob([]string{r.Cluster}, facts.Clusters)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+
+	if r.Regex != "" {
+		re, err := regexp.Compile(r.Regex)
+		if err != nil {
+			return false, errors.Wrapf(err, "invalid regex %q", r.Regex)
+		}
+
+		if !re.MatchString(p.Name) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func equal(a, b string) bool {
+	return a == b
+}
+
+func matchAny(want, have []string, match func(want, have string) bool) bool {
+	for _, w := range want {
+		for _, h := range have {
+			if match(w, h) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func anyGlob(globs, values []string) (bool, error) {
+	for _, glob := range globs {
+		if _, err := path.Match(glob, ""); err != nil {
+			return false, errors.Wrapf(err, "invalid glob %q", glob)
+		}
+	}
+
+	return matchAny(globs, values, func(glob, value string) bool {
+		ok, _ := path.Match(glob, value)
+		return ok
+	}), nil
+}
+
+// Validate checks the rules and the environments.
+func (c Config) Validate() error {
+	var errs []error
+
+	for i, rule := range c.Rules {
+		if _, ok := c.Environments[rule.Environment]; !ok {
+			errs = append(errs, errors.Errorf("rule %d: unknown environment %q", i, rule.Environment))
+		}
+
+		if _, err := rule.Matches(iterm.Profile{}); err != nil {
+			errs = append(errs, errors.Wrapf(err, "rule %d", i))
+		}
+	}
+
+	for name, env := range c.Environments {
+		if err := env.Apply(&iterm.Profile{}); err != nil {
+			errs = append(errs, errors.Wrapf(err, "environment %s", name))
+		}
+	}
+
+	return stderrors.Join(errs...)
+}
+
+// Classify returns the environment of the first rule matching the profile,
+// or an empty string.
+func (c Config) Classify(p iterm.Profile) string {
+	for _, rule := range c.Rules {
+		if ok, _ := rule.Matches(p); ok {
+			return rule.Environment
+		}
+	}
+
+	return ""
+}
+
+// Apply colors and badges the profile.
+func (e Environment) Apply(p *iterm.Profile) error {
+	var errs []error
+
+	err := e.Scheme.Apply(p)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	for name, hex := range e.Palette {
+		color, err := iterm.ParseColor(hex)
+		if err == nil {
+			err = p.SetColor(name, color)
+		}
+
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if e.Badge != "" {
+		p.BadgeText = e.Badge + p.BadgeText
+	}
+
+	if e.TabColor != "" {
+		color, err := iterm.ParseColor(e.TabColor)
+		if err != nil {
+			errs = append(errs, errors.Wrap(err, "invalid tab color"))
+		} else {
+			p.TabColor = &color
+			p.UseTabColor = true
+		}
+	}
+
+	return stderrors.Join(errs...)
+}
+
+// Annotate sets the environment metadata of the profiles, for the badge
+// templates, skipping the profile named skip.
+func Annotate(c Config, profiles []iterm.Profile, skip string) {
+	for i := range profiles {
+		if profiles[i].Name == skip {
+			continue
+		}
+
+		profiles[i].SetMetadata("environment", c.Classify(profiles[i]))
+	}
+}
+
+// Apply classifies the profiles, tags them with their environment, unless c
+// is the Default, and applies it, skipping the profile named skip. Nothing
+// is changed if the configuration is invalid.
+func Apply(c Config, profiles []iterm.Profile, skip string) error {
+	err := c.Validate()
+	if err != nil {
+		return errors.Wrap(err, "invalid classification")
+	}
+
+	for i := range profiles {
+		if profiles[i].Name == skip {
+			continue
+		}
+
+		env := c.Classify(profiles[i])
+		if env == "" {
+			continue
+		}
+
+		if !c.implicit {
+			profiles[i].Tags = append(profiles[i].Tags, Tag+env)
+		}
+
+		// the environment is valid, it cannot fail
+		_ = c.Environments[env].Apply(&profiles[i])
+	}
+
+	return nil
+}
@@ -0,0 +1,199 @@
+package classify
+
+import (
+	"testing"
+
+	"github.com/mhristof/germ/iterm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFactsOf(t *testing.T) {
+	cases := []struct {
+		name     string
+		profile  iterm.Profile
+		expected Facts
+	}{
+		{
+			name:     "aws role",
+			profile:  iterm.Profile{Identity: "aws:123456789012:admin"},
+			expected: Facts{Accounts: []string{"123456789012"}},
+		},
+		{
+			name:     "aws login",
+			profile:  iterm.Profile{Identity: "login:aws:123456789012:admin"},
+			expected: Facts{Accounts: []string{"123456789012"}},
+		},
+		{
+			name:     "eks cluster",
+			profile:  iterm.Profile{Identity: "k8s:arn:aws:eks:eu-west-1:123456789012:cluster/payments"},
+			expected: Facts{Clusters: []string{"arn:aws:eks:eu-west-1:123456789012:cluster/payments", "payments"}},
+		},
+		{
+			name:     "aws profile name",
+			profile:  iterm.Profile{Identity: "aws:123456789012:admin", Metadata: map[string]string{"profile": "payments-prod"}},
+			expected: Facts{Accounts: []string{"123456789012"}, Aliases: []string{"payments-prod"}},
+		},
+		{
+			name:     "k8s with an aws profile",
+			profile:  iterm.Profile{Identity: "k8s:api", Metadata: map[string]string{"profile": "team-sandbox"}},
+			expected: Facts{Aliases: []string{"team-sandbox"}, Clusters: []string{"api"}},
+		},
+		{
+			name:    "ssm tags",
+			profile: iterm.Profile{Identity: "ssm:i-0123", Tags: []string{"AWS", "account=123456789012", "alias=payments-prod"}},
+			expected: Facts{
+				Accounts: []string{"123456789012"},
+				Aliases:  []string{"payments-prod"},
+			},
+		},
+		{
+			name:    "no facts",
+			profile: iterm.Profile{Name: "vim", Tags: []string{"vim"}},
+		},
+	}
+
+	for _, test := range cases {
+		assert.Equal(t, test.expected, FactsOf(test.profile), test.name)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	config := Config{
+		Environments: map[string]Environment{"prod": {}, "staging": {}, "dev": {}, "sandbox": {}},
+		Rules: []Rule{
+			{Environment: "prod", Accounts: []string{"111111111111", "222222222222"}},
+			{Environment: "staging", Cluster: "*-staging"},
+			{Environment: "sandbox", Aliases: []string{"*-sandbox"}},
+			{Environment: "dev", Tag: "aws-profile=dev-*"},
+			{Environment: "dev", Regex: "^dev-", Tag: "ssh"},
+		},
+	}
+
+	cases := []struct {
+		name     string
+		profile  iterm.Profile
+		expected string
+	}{
+		{name: "account", profile: iterm.Profile{Identity: "aws:222222222222:admin"}, expected: "prod"},
+		{name: "account tag", profile: iterm.Profile{Tags: []string{"account=111111111111"}}, expected: "prod"},
+		{name: "cluster", profile: iterm.Profile{Identity: "k8s:arn:aws:eks:eu-west-1:1:cluster/api-staging"}, expected: "staging"},
+		{name: "alias", profile: iterm.Profile{Tags: []string{"alias=team-sandbox"}}, expected: "sandbox"},
+		{name: "aws profile alias", profile: iterm.Profile{Metadata: map[string]string{"profile": "team-sandbox"}}, expected: "sandbox"},
+		{name: "tag", profile: iterm.Profile{Tags: []string{"k8s", "aws-profile=dev-admin"}}, expected: "dev"},
+		{name: "all fields must match", profile: iterm.Profile{Name: "dev-box"}},
+		{name: "regex and tag", profile: iterm.Profile{Name: "dev-box", Tags: []string{"ssh"}}, expected: "dev"},
+		{name: "first match wins", profile: iterm.Profile{Identity: "aws:111111111111:admin", Tags: []string{"alias=team-sandbox"}}, expected: "prod"},
+		{name: "name is not enough", profile: iterm.Profile{Name: "prod"}},
+	}
+
+	for _, test := range cases {
+		assert.Equal(t, test.expected, config.Classify(test.profile), test.name)
+	}
+}
+
+func TestDefault(t *testing.T) {
+	cases := []struct {
+		name     string
+		expected string
+	}{
+		{name: "prod", expected: "prod"},
+		{name: "app-prd", expected: "prod"},
+		{name: "nonprod", expected: "nonprod"},
+		{name: "app-nonprd", expected: "nonprod"},
+		{name: "sandbox"},
+	}
+
+	for _, test := range cases {
+		assert.Equal(t, test.expected, Default().Classify(iterm.Profile{Name: test.name}), test.name)
+	}
+}
+
+func TestApply(t *testing.T) {
+	config := Config{
+		Environments: map[string]Environment{
+			"prod": {
+				Palette:  map[string]string{"background": "#400000"},
+				Badge:    "PROD ",
+				TabColor: "#ff0000",
+			},
+		},
+		Rules: []Rule{{Environment: "prod", Accounts: []string{"123456789012"}}},
+	}
+
+	profiles := []iterm.Profile{
+		{Name: "payments", Identity: "aws:123456789012:admin", BadgeText: "payments"},
+		{Name: "sandbox", Identity: "aws:999999999999:admin", BadgeText: "sandbox"},
+		{Name: "parent", Tags: []string{"account=123456789012"}},
+	}
+
+	err := Apply(config, profiles, "parent")
+	assert.NoError(t, err)
+
+	assert.Equal(t, "PROD payments", profiles[0].BadgeText)
+	assert.InDelta(t, 0.25, profiles[0].BackgroundColor.RedComponent, 0.01)
+	assert.Contains(t, profiles[0].Tags, "environment=prod")
+	assert.Equal(t, &iterm.Color{ColorSpace: "sRGB", RedComponent: 1, AlphaComponent: 1}, profiles[0].TabColor)
+	assert.True(t, profiles[0].UseTabColor)
+
+	assert.Equal(t, "sandbox", profiles[1].BadgeText)
+	assert.Empty(t, profiles[1].Tags)
+	assert.Nil(t, profiles[1].Extra)
+
+	assert.Empty(t, profiles[2].BadgeText)
+	assert.Equal(t, []string{"account=123456789012"}, profiles[2].Tags)
+
+	guessed := []iterm.Profile{{Name: "payments-prod"}}
+	assert.NoError(t, Apply(Default(), guessed, ""))
+	assert.InDelta(t, 0.25, guessed[0].BackgroundColor.RedComponent, 0.01)
+	assert.Empty(t, guessed[0].Tags, "the default classification does not tag")
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name   string
+		config Config
+		err    bool
+	}{
+		{name: "default", config: Default()},
+		{
+			name:   "unknown environment",
+			config: Config{Rules: []Rule{{Environment: "prod"}}},
+			err:    true,
+		},
+		{
+			name: "invalid regex",
+			config: Config{
+				Environments: map[string]Environment{"prod": {}},
+				Rules:        []Rule{{Environment: "prod", Regex: "("}},
+			},
+			err: true,
+		},
+		{
+			name: "invalid glob",
+			config: Config{
+				Environments: map[string]Environment{"prod": {}},
+				Rules:        []Rule{{Environment: "prod", Aliases: []string{"["}}},
+			},
+			err: true,
+		},
+		{
+			name:   "invalid palette",
+			config: Config{Environments: map[string]Environment{"prod": {Palette: map[string]string{"bg": "#000000"}}}},
+			err:    true,
+		},
+		{
+			name:   "invalid tab color",
+			config: Config{Environments: map[string]Environment{"prod": {TabColor: "red"}}},
+			err:    true,
+		},
+	}
+
+	for _, test := range cases {
+		err := test.config.Validate()
+		if test.err {
+			assert.Error(t, err, test.name)
+		} else {
+			assert.NoError(t, err, test.name)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/mhristof/germ/aws"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	graphFormat string
+	awsGraph    = &cobra.Command{
+		Use:   "graph",
+		Short: "Show how the AWS profiles chain their roles, as text, dot or mermaid",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			cfg, err := aws.Load(AWSConfig, AWSCredentials)
+			if err != nil {
+				log.Fatal().Err(err).Msg("cannot load the AWS config")
+			}
+
+			graph, graphErr := aws.NewGraph(cfg)
+
+			err = graph.Write(os.Stdout, graphFormat)
+			if err != nil {
+				log.Fatal().Err(err).Msg("cannot render the graph")
+			}
+
+			if graphErr != nil {
+				log.Fatal().Err(graphErr).Msg("invalid role chains")
+			}
+		},
+	}
+)
+
+var awsCmd = &cobra.Command{
+	Use:   "aws",
+	Short: "Inspect the AWS profiles",
+}
+
+func init() {
+	awsCmd.PersistentFlags().StringVarP(&AWSConfig, "aws-config", "a", AWSConfig, "AWS config file path")
+	awsCmd.PersistentFlags().StringVarP(&AWSCredentials, "aws-credentials", "c", AWSCredentials, "AWS credentials file path")
+	awsGraph.Flags().StringVarP(&graphFormat, "format", "f", "text", "Output format, text, dot or mermaid")
+
+	awsCmd.AddCommand(awsGraph)
+	rootCmd.AddCommand(awsCmd)
+}
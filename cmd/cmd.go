@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"bytes"
+	stderrors "errors"
 	"fmt"
 	"regexp"
 	"strings"
@@ -26,25 +27,42 @@ var cmdCmd = &cobra.Command{
 		`,
 	),
 	Run: func(cmd *cobra.Command, args []string) {
+		gen := iterm.NewGeneration(0)
+
+		profiles, err := aws.Profiles(gen, "prefix", AWSConfig, AWSCredentials)
+		if err != nil {
+			log.Error().Err(err).Msg("some AWS profiles could not be generated")
+		}
+
+		err = stderrors.Join(gen.Err(), gen.Save())
+		if err != nil {
+			log.Warn().Err(err).Msg("some profile settings are disabled")
+		}
+
+		cfg, err := aws.Load(AWSConfig, AWSCredentials)
+		if err != nil {
+			log.Fatal().Err(err).Msg("cannot load the AWS config")
+		}
+
 		prof := iterm.Profiles{
-			Profiles: aws.Profiles("prefix", AWSConfig),
+			Profiles: profiles,
 		}
 
-		fmt.Println(strings.Join(generateCommands(prof, command), "\n"))
+		fmt.Println(strings.Join(generateCommands(prof, cfg, command), "\n"))
 	},
 }
 
-func generateCommands(prof iterm.Profiles, command string) []string {
+func generateCommands(prof iterm.Profiles, cfg aws.Config, command string) []string {
 	var ret []string
 
 	for source, profiles := range prof.ProfileTree() {
 		login := false
 		for _, profile := range profiles {
 			if !login {
-				loginGUID := fmt.Sprintf("login-%s", source)
-				iProfile, found := prof.FindGUID(loginGUID)
+				loginID := aws.LoginIdentity(source, cfg.Profiles[source])
+				iProfile, found := prof.FindIdentity(loginID)
 				if !found {
-					log.Panic().Msg(loginGUID)
+					log.Panic().Msg(loginID)
 				}
 
 				ret = append(ret, strings.Replace(iProfile.Command, " || sleep 60'", "'", -1))
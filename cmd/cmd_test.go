@@ -79,14 +79,14 @@ func TestGenerateCommands(t *testing.T) {
 			profiles: iterm.Profiles{
 				Profiles: []iterm.Profile{
 					iterm.Profile{
-						GUID: "parent",
+						Name: "parent",
 					},
 					iterm.Profile{
-						GUID:    "login-parent",
+						Name:    "login-parent",
 						Command: "login-command",
 					},
 					iterm.Profile{
-						GUID: "child",
+						Name: "child",
 						Tags: []string{
 							"source-profile=parent",
 						},
@@ -104,20 +104,20 @@ func TestGenerateCommands(t *testing.T) {
 			profiles: iterm.Profiles{
 				Profiles: []iterm.Profile{
 					iterm.Profile{
-						GUID: "parent",
+						Name: "parent",
 					},
 					iterm.Profile{
-						GUID:    "login-parent",
+						Name:    "login-parent",
 						Command: "login-command",
 					},
 					iterm.Profile{
-						GUID: "child1",
+						Name: "child1",
 						Tags: []string{
 							"source-profile=parent",
 						},
 					},
 					iterm.Profile{
-						GUID: "child2",
+						Name: "child2",
 						Tags: []string{
 							"source-profile=parent",
 						},
@@ -136,14 +136,14 @@ func TestGenerateCommands(t *testing.T) {
 			profiles: iterm.Profiles{
 				Profiles: []iterm.Profile{
 					iterm.Profile{
-						GUID: "parent",
+						Name: "parent",
 					},
 					iterm.Profile{
-						GUID:    "login-parent",
+						Name:    "login-parent",
 						Command: "bash -c 'login-command || sleep 60'",
 					},
 					iterm.Profile{
-						GUID: "child",
+						Name: "child",
 						Tags: []string{
 							"source-profile=parent",
 						},
@@ -158,10 +158,28 @@ func TestGenerateCommands(t *testing.T) {
 	}
 
 	for _, test := range cases {
-		assert.Equal(t, generateCommands(test.profiles, test.command), test.out, test.name)
+		assert.Equal(t, generateCommands(test.profiles, aws.Config{}, test.command), test.out, test.name)
 
 	}
 }
+func TestGenerateCommandsIdentity(t *testing.T) {
+	cfg := aws.Config{
+		Profiles: map[string]map[string]string{
+			"parent": {"role_arn": "arn:aws:iam::123456789012:role/admin"},
+		},
+	}
+
+	profiles := iterm.Profiles{
+		Profiles: []iterm.Profile{
+			{Name: "parent", Identity: "aws:123456789012:admin"},
+			{Name: "login-renamed", Identity: "login:aws:123456789012:admin", Command: "login-command"},
+			{Name: "child", Tags: []string{"source-profile=parent"}},
+		},
+	}
+
+	assert.Equal(t, []string{"login-command", "AWS_PROFILE=child aws s3 ls"}, generateCommands(profiles, cfg, "aws s3 ls"))
+}
+
 func TestGenerateTemplateEdgeCases(t *testing.T) {
 	cases := []struct {
 		name    string
@@ -247,12 +265,12 @@ func TestGenerateCommandsEdgeCases(t *testing.T) {
 			name: "multiple source profiles",
 			profiles: iterm.Profiles{
 				Profiles: []iterm.Profile{
-					{GUID: "source1"},
-					{GUID: "login-source1", Command: "login1"},
-					{GUID: "child1", Tags: []string{"source-profile=source1"}},
-					{GUID: "source2"},
-					{GUID: "login-source2", Command: "login2"},
-					{GUID: "child2", Tags: []string{"source-profile=source2"}},
+					{Name: "source1"},
+					{Name: "login-source1", Command: "login1"},
+					{Name: "child1", Tags: []string{"source-profile=source1"}},
+					{Name: "source2"},
+					{Name: "login-source2", Command: "login2"},
+					{Name: "child2", Tags: []string{"source-profile=source2"}},
 				},
 			},
 			command: "aws s3 ls",
@@ -268,7 +286,7 @@ func TestGenerateCommandsEdgeCases(t *testing.T) {
 
 	for _, test := range cases {
 		t.Run(test.name, func(t *testing.T) {
-			result := generateCommands(test.profiles, test.command)
+			result := generateCommands(test.profiles, aws.Config{}, test.command)
 			if test.unordered {
 				assert.ElementsMatch(t, test.expected, result)
 			} else {
@@ -353,7 +371,7 @@ func TestCommandGeneration(t *testing.T) {
 	}
 
 	t.Run("simple command", func(t *testing.T) {
-		result := generateCommands(profiles, "aws sts get-caller-identity")
+		result := generateCommands(profiles, aws.Config{}, "aws sts get-caller-identity")
 		expected := []string{
 			"aws sso login --profile prod-account",
 			"AWS_PROFILE=prod-role1 aws sts get-caller-identity",
@@ -363,7 +381,7 @@ func TestCommandGeneration(t *testing.T) {
 	})
 
 	t.Run("command with region template", func(t *testing.T) {
-		result := generateCommands(profiles, "aws ec2 describe-instances --region {{ .Region }}")
+		result := generateCommands(profiles, aws.Config{}, "aws ec2 describe-instances --region {{ .Region }}")
 		
 		// Should have login command + (2 profiles * number of regions) commands
 		expectedCount := 1 + (2 * len(aws.Regions()))
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 )
 
@@ -15,7 +16,10 @@ var deleteCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		Verbose(cmd)
 
-		keyChain.Delete(deleteName)
+		err := keyChain.Delete(deleteName)
+		if err != nil {
+			log.Fatal().Err(err).Msg("cannot delete keychain profile")
+		}
 	},
 }
 
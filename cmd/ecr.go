@@ -5,13 +5,12 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
-	"strings"
 	"time"
 
+	"github.com/mhristof/germ/aws"
 	"github.com/mitchellh/go-homedir"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
-	"gopkg.in/ini.v1"
 )
 
 var ecrCmd = &cobra.Command{
@@ -28,20 +27,26 @@ var ecrCmd = &cobra.Command{
 			panic(err)
 		}
 
-		config, err := ini.Load(configPath)
+		config, err := aws.Load(configPath, "")
 		if err != nil {
 			panic(err)
 		}
 
 		repos := map[string]string{}
 
-		for _, section := range config.Sections() {
-			if !strings.HasPrefix(section.Name(), "profile ") {
+		for name, section := range config.Profiles {
+			account, ok := section["sso_account_id"]
+			if !ok {
 				continue
 			}
 
-			account := section.Key("sso_account_id")
-			region := section.Key("region")
+			// the sso_region of a session is the region of the portal, not
+			// of the registry
+			region, ok := section["region"]
+			if !ok || region == "" {
+				log.Warn().Str("profile", name).Msg("profile has no region, skipping its ECR registry")
+				continue
+			}
 
 			key := fmt.Sprintf("%s.dkr.ecr.%s.amazonaws.com", account, region)
 			repos[key] = "ecr-login"
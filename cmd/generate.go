@@ -1,22 +1,18 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"sort"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
-	"github.com/adrg/xdg"
-	"github.com/google/go-cmp/cmp"
-	"github.com/mhristof/germ/aws"
 	"github.com/mhristof/germ/config"
+	"github.com/mhristof/germ/diff"
+	"github.com/mhristof/germ/germ"
+	"github.com/mhristof/germ/history"
 	"github.com/mhristof/germ/iterm"
-	"github.com/mhristof/germ/k8s"
-	"github.com/mhristof/germ/ssh"
-	"github.com/mhristof/germ/ssm"
-	"github.com/mhristof/germ/vault"
-	"github.com/mhristof/germ/vim"
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 
 	"github.com/mitchellh/go-homedir"
@@ -27,8 +23,16 @@ var (
 	output          string
 	write           bool
 	kubeConfig      string
-	diff            bool
+	showDiff        bool
+	check           bool
+	diffFormat      string
+	diffIgnore      []string
+	split           bool
 	ignoreInstances bool
+	noParent        bool
+	timeout         time.Duration
+	report          string
+	reportFile      string
 	AWSConfig       = expandUser("~/.aws/config")
 	AWSCredentials  = expandUser("~/.aws/credentials")
 	DefaultProfile  = "default-profile"
@@ -45,150 +49,164 @@ var generateCmd = &cobra.Command{
 			log.Fatal().Msg("--write is incompatible with --dry-run")
 		}
 
-		if write && diff {
-			log.Fatal().Msg("--write and --diff are incompatible")
+		if write && (showDiff || check) {
+			log.Fatal().Msg("--write is incompatible with --diff and --check")
 		}
 
-		var prof iterm.Profiles
-
-		prof.Profiles = append(prof.Profiles, aws.Profiles("", AWSConfig)...)
-		prof.Profiles = append(prof.Profiles, k8s.Profiles(kubeConfig, dryRun)...)
-		prof.Profiles = append(prof.Profiles, keyChain.Profiles()...)
-		prof.Profiles = append(prof.Profiles, *iterm.NewProfile(DefaultProfile, map[string]string{
-			"AllowTitleSetting": "true",
-			"BadgeText":         "",
-		}))
-		prof.Profiles = append(prof.Profiles, vim.Profile())
-		prof.Profiles = append(prof.Profiles, ssh.Profiles()...)
+		if split && !write && !showDiff && !check {
+			log.Fatal().Msg("--split requires --write, --diff or --check")
+		}
 
 		config.Load()
-		prof.Profiles = append(prof.Profiles, config.Generate()...)
-		if !ignoreInstances {
-			ssmProfs := ssm.Generate()
-			prof.Profiles = append(prof.Profiles, ssmProfs...)
 
-			data, err := json.MarshalIndent(ssmProfs, "", "    ")
-			if err != nil {
-				log.Fatal().Err(err).Msg("cannot marshal ssm profiles")
-			}
+		opts := options()
 
-			storeToCache("germ.ssm.json", data)
+		// target holds the files the profiles are written to.
+		target := opts
+		if split {
+			target.OutputDir = filepath.Dir(output)
 		} else {
-			data, path := loadFromCache("germ.ssm.json")
-
-			var ssmProfs []iterm.Profile
-			err := json.Unmarshal(data, &ssmProfs)
-			if err != nil {
-				log.Fatal().Str("path", path).Err(err).Msg("cannot unmarshal ssm profiles")
-			} else {
-				prof.Profiles = append(prof.Profiles, ssmProfs...)
-				log.Info().Str("path", path).Msg("using cached ssm profiles")
-			}
+			target.Output = output
 		}
 
-		vaultProfile, err := vault.Profile()
-		if err != nil {
-			log.Warn().Err(err).Msg("cannot add vault profile")
-		} else {
-			prof.Profiles = append(prof.Profiles, vaultProfile)
-		}
-
-		prof.UpdateKeyboardMaps()
-		prof.UpdateAWSSmartSelectionRules()
+		if write {
+			opts = target
 
-		var uniqProf iterm.Profiles
-		existingProfiles := map[string]struct{}{}
-		for _, profile := range prof.Profiles {
-			if _, ok := existingProfiles[profile.Name]; ok {
-				log.Warn().Str("name", profile.Name).Msg("duplicate profile")
-				continue
+			if _, err := os.Stat(output); err == nil && split {
+				log.Warn().Str("path", output).Msg("single output file exists and duplicates the split profiles, consider removing it")
 			}
+		}
 
-			existingProfiles[profile.Name] = struct{}{}
-			uniqProf.Profiles = append(uniqProf.Profiles, profile)
+		if reportFile != "" {
+			f, err := os.Create(reportFile)
+			if err != nil {
+				log.Fatal().Err(err).Str("path", reportFile).Msg("cannot create report file")
+			}
 
+			opts.Report = f
 		}
 
-		prof = uniqProf
+		prof, errs := germ.Generate(cmd.Context(), opts)
+		drift := false
 
-		profJSON, err := json.MarshalIndent(prof, "", "    ")
-		if err != nil {
-			log.Fatal().Err(err).Msg("cannot indent json")
+		if f, ok := opts.Report.(*os.File); ok && f != os.Stderr {
+			f.Close()
 		}
 
-		// unescape "&" character.
-		profJSON = []byte(strings.ReplaceAll(string(profJSON), `\u0026`, "&"))
-		// unescape ">" character.
-		profJSON = []byte(strings.ReplaceAll(string(profJSON), `\u003e`, ">"))
-
 		if write {
-			err = ioutil.WriteFile(output, profJSON, 0o644)
-			if err != nil {
-				log.Fatal().Err(err).Msg("cannot write to file")
-			}
-
 			// Configure global iTerm settings
 			changes := iterm.ConfigureGlobalSettings()
 			iterm.PrintSettingsChanges(changes)
-		} else if diff {
-			curr, err := ioutil.ReadFile(output)
+		} else if showDiff || check {
+			current, err := germ.Current(target)
 			if err != nil {
-				log.Fatal().Err(err).Msg("cannot read file")
+				log.Fatal().Err(err).Msg("cannot read the current profiles")
 			}
 
-			var current iterm.Profiles
-			err = json.Unmarshal(curr, &current)
+			res, err := diff.Profiles(current, prof.Profiles, diffIgnore)
 			if err != nil {
-				log.Fatal().Err(err).Msg("cannot unmarshal output file")
+				log.Fatal().Err(err).Msg("cannot compare profiles")
 			}
 
-			sort.Slice(current.Profiles, func(i, j int) bool {
-				return current.Profiles[i].GUID < current.Profiles[j].GUID
-			})
-
-			sort.Slice(prof.Profiles, func(i, j int) bool {
-				return prof.Profiles[i].GUID < prof.Profiles[j].GUID
-			})
-
-			if diff := cmp.Diff(current, prof); diff != "" {
-				fmt.Println("Updating (-current +new):", diff)
+			err = diff.Write(os.Stdout, res, diffFormat)
+			if err != nil {
+				log.Fatal().Err(err).Msg("cannot print diff")
 			}
+
+			drift = !res.Empty()
 		} else {
+			profJSON, err := germ.Marshal(prof)
+			if err != nil {
+				log.Fatal().Err(err).Msg("cannot marshal profiles")
+			}
+
 			fmt.Println(string(profJSON))
 		}
+
+		if len(errs) > 0 {
+			fmt.Fprintf(os.Stderr, "%d errors while generating profiles:\n", len(errs))
+			for _, err := range errs {
+				for _, line := range strings.Split(err.Error(), "\n") {
+					fmt.Fprintf(os.Stderr, "  - %s\n", line)
+				}
+			}
+
+			os.Exit(1)
+		}
+
+		if check && drift {
+			os.Exit(1)
+		}
 	},
 }
 
-func loadFromCache(name string) ([]byte, string) {
-	path, err := xdg.CacheFile(name)
+// options returns the generation options from the command line flags and
+// the configuration file.
+func options() germ.Options {
+	opts, err := loadOptions()
 	if err != nil {
-		log.Fatal().Err(err).Msg("cannot get cache file")
-		return nil, path
+		log.Fatal().Err(err).Msg("invalid configuration")
 	}
 
-	data, err := ioutil.ReadFile(path)
+	return opts
+}
+
+// loadOptions is options that returns the configuration errors.
+func loadOptions() (germ.Options, error) {
+	settings := config.Sources()
+	if timeout > 0 {
+		settings.DefaultTimeout = timeout
+	}
+
+	overrides, err := config.Overrides()
 	if err != nil {
-		log.Fatal().Err(err).Msg("cannot read from file")
-		return nil, path
+		return germ.Options{}, errors.Wrap(err, "invalid overrides configuration")
 	}
 
-	log.Debug().Str("path", path).Str("name", name).Msg("loaded from cache")
-	return data, path
-}
+	classification, err := config.Classification()
+	if err != nil {
+		return germ.Options{}, errors.Wrap(err, "invalid classification configuration")
+	}
 
-func storeToCache(name string, data []byte) {
-	path, err := xdg.CacheFile(name)
+	colors, err := config.Scheme()
 	if err != nil {
-		log.Fatal().Err(err).Msg("cannot get cache file")
+		return germ.Options{}, errors.Wrap(err, "invalid scheme configuration")
 	}
 
-	// write to file
-	err = ioutil.WriteFile(path, data, 0o644)
+	accents, err := config.Accent()
 	if err != nil {
-		log.Fatal().Err(err).Msg("cannot write to file")
+		return germ.Options{}, errors.Wrap(err, "invalid accent configuration")
+	}
+
+	logins, err := config.Logins()
+	if err != nil {
+		return germ.Options{}, errors.Wrap(err, "invalid logins configuration")
+	}
+
+	opts := germ.Options{
+		AWSConfig:       AWSConfig,
+		AWSCredentials:  AWSCredentials,
+		Logins:          logins,
+		KubeConfig:      kubeConfig,
+		KeyChain:        keyChain,
+		DefaultProfile:  DefaultProfile,
+		CachedInstances: ignoreInstances,
+		DryRun:          dryRun,
+		Sources:         settings,
+		Overrides:       overrides,
+		Scheme:          colors,
+		Accent:          accents,
+		Badges:          config.Badges(),
+		Keys:            config.Keys(),
+		Classification:  classification,
+		NamesPruneAfter: config.Names(),
+		Parent:          !noParent,
+		History:         history.New(config.History()),
+		Report:          os.Stderr,
+		ReportFormat:    report,
 	}
 
-	log.Debug().Str("path", path).Str("name", name).Msg("stored to cache")
+	return opts, nil
 }
 
 func expandUser(path string) string {
@@ -221,8 +239,16 @@ func init() {
 		"Kubernetes configuration file",
 	)
 	generateCmd.Flags().BoolVarP(&write, "write", "w", false, "Write the output to the destination file")
-	generateCmd.Flags().BoolVarP(&diff, "diff", "d", false, "Generate a diff for the new changes")
+	generateCmd.Flags().BoolVarP(&showDiff, "diff", "d", false, "Generate a diff for the new changes")
+	generateCmd.Flags().BoolVarP(&check, "check", "", false, "Print the diff and exit with 1 if the profiles on disk would change")
+	generateCmd.Flags().StringVarP(&diffFormat, "diff-format", "", "text", "Diff format, text or json")
+	generateCmd.Flags().StringSliceVarP(&diffIgnore, "diff-ignore", "", diff.DefaultIgnore, "Profile fields ignored by the diff, timestamps ignores the timestamp tags")
+	generateCmd.Flags().BoolVarP(&split, "split", "s", false, "Write a germ-<source>.json file per source in the directory of --output")
+	generateCmd.Flags().BoolVarP(&noParent, "no-parent", "", false, "Write every setting in every profile instead of inheriting from the "+germ.ParentName+" profile")
 	generateCmd.Flags().BoolVarP(&ignoreInstances, "ignore-instances", "I", false, "Ignore SSM instance profiles")
+	generateCmd.Flags().DurationVarP(&timeout, "timeout", "t", 0, "Timeout for each source, overrides sources.timeout from the config")
+	generateCmd.Flags().StringVarP(&report, "report", "r", "text", "Generation report format, text or json")
+	generateCmd.Flags().StringVarP(&reportFile, "report-file", "", "", "File to write the generation report to instead of stderr")
 
 	rootCmd.AddCommand(generateCmd)
 }
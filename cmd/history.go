@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mhristof/germ/config"
+	"github.com/mhristof/germ/history"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List the previous generations that can be restored with rollback",
+	Run: func(cmd *cobra.Command, args []string) {
+		config.Load()
+
+		list, err := history.New(config.History()).List()
+		if err != nil {
+			log.Fatal().Err(err).Msg("cannot list generations")
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tTIME\tPROFILES\tFILES")
+
+		for _, gen := range list {
+			fmt.Fprintf(w, "%d\t%s\t%d\t%d\n", gen.ID, gen.Time.Format(time.RFC3339), gen.Profiles(), len(gen.Files))
+		}
+
+		w.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+}
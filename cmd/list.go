@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 
+	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 )
 
@@ -13,7 +14,12 @@ var listCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		Verbose(cmd)
 
-		fmt.Println(keyChain.List())
+		accounts, err := keyChain.List()
+		if err != nil {
+			log.Fatal().Err(err).Msg("cannot list keychain profiles")
+		}
+
+		fmt.Println(accounts)
 	},
 }
 
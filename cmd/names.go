@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mhristof/germ/config"
+	"github.com/mhristof/germ/names"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	unpin     bool
+	resetAll  bool
+	namesList = &cobra.Command{
+		Use:   "list",
+		Short: "List the unique names of the profiles",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			store := openNames()
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "PROFILE\tNAME\tPINNED\tLAST SEEN")
+
+			for _, e := range store.List() {
+				fmt.Fprintf(w, "%s\t%s\t%t\t%s\n", e.Profile, e.Name, e.Pinned, e.Seen.Format(time.RFC3339))
+			}
+
+			w.Flush()
+		},
+	}
+	namesPin = &cobra.Command{
+		Use:   "pin PROFILE|NAME...",
+		Short: "Keep the unique names even when their profiles are no longer generated",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			store := openNames()
+
+			for _, arg := range args {
+				err := store.Pin(arg, !unpin)
+				if err != nil {
+					log.Fatal().Err(err).Msg("cannot pin name")
+				}
+			}
+
+			saveNames(store)
+		},
+	}
+	namesRename = &cobra.Command{
+		Use:   "rename PROFILE|NAME NEW-NAME",
+		Short: "Change the unique name of a profile, the new name is pinned",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			store := openNames()
+
+			err := store.Rename(args[0], args[1])
+			if err != nil {
+				log.Fatal().Err(err).Msg("cannot rename")
+			}
+
+			saveNames(store)
+		},
+	}
+	namesReset = &cobra.Command{
+		Use:   "reset [PROFILE|NAME...]",
+		Short: "Forget unique names, new ones are picked by the next generate",
+		Run: func(cmd *cobra.Command, args []string) {
+			store := openNames()
+
+			if resetAll {
+				for _, e := range store.List() {
+					if !e.Pinned {
+						args = append(args, e.Profile)
+					}
+				}
+			}
+
+			if len(args) == 0 {
+				log.Fatal().Msg("nothing to reset, pass profiles or --all")
+			}
+
+			for _, arg := range args {
+				err := store.Reset(arg)
+				if err != nil {
+					log.Fatal().Err(err).Msg("cannot reset name")
+				}
+			}
+
+			saveNames(store)
+		},
+	}
+)
+
+var namesCmd = &cobra.Command{
+	Use:   "names",
+	Short: "Manage the unique names shown in the profile badges",
+	Run:   namesList.Run,
+}
+
+func openNames() *names.Store {
+	config.Load()
+
+	path, err := names.DefaultPath()
+	if err != nil {
+		log.Fatal().Err(err).Msg("cannot find the names storage")
+	}
+
+	store, err := names.Open(path, config.Names())
+	if err != nil {
+		log.Fatal().Err(err).Msg("cannot open the names storage")
+	}
+
+	return store
+}
+
+func saveNames(store *names.Store) {
+	err := store.Save()
+	if err != nil {
+		log.Fatal().Err(err).Msg("cannot save the names")
+	}
+
+	log.Info().Msg("run germ generate --write to update the profile badges")
+}
+
+func init() {
+	namesPin.Flags().BoolVarP(&unpin, "unpin", "", false, "Let the names be pruned again")
+	namesReset.Flags().BoolVarP(&resetAll, "all", "", false, "Reset all the names that are not pinned")
+
+	namesCmd.AddCommand(namesList, namesPin, namesRename, namesReset)
+	rootCmd.AddCommand(namesCmd)
+}
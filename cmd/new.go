@@ -34,7 +34,10 @@ var newCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		Verbose(cmd)
 
-		keyChain.Add(newName, findPassword(file))
+		err := keyChain.Add(newName, findPassword(file))
+		if err != nil {
+			log.Fatal().Err(err).Msg("cannot add keychain profile")
+		}
 	},
 }
 
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"github.com/mhristof/germ/config"
+	"github.com/mhristof/germ/germ"
+	"github.com/mhristof/germ/history"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var rollbackTo int
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Restore the profiles of a previous generation",
+	Run: func(cmd *cobra.Command, args []string) {
+		config.Load()
+
+		gen, err := germ.Rollback(history.New(config.History()), rollbackTo)
+		if err != nil {
+			log.Fatal().Err(err).Msg("cannot roll back")
+		}
+
+		for _, f := range gen.Files {
+			log.Info().Str("path", f.Path).Int("profiles", f.Profiles).Msg("restored")
+		}
+	},
+}
+
+func init() {
+	rollbackCmd.Flags().IntVarP(&rollbackTo, "to", "", 0, "Generation to restore, see germ history. Defaults to the one before the latest")
+
+	rootCmd.AddCommand(rollbackCmd)
+}
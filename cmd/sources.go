@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/mhristof/germ/config"
+	"github.com/mhristof/germ/germ"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var sourcesCmd = &cobra.Command{
+	Use:   "sources",
+	Short: "List the profile sources and whether they are enabled",
+	Run: func(cmd *cobra.Command, args []string) {
+		config.Load()
+
+		opts := options()
+
+		r, err := germ.Registry(opts)
+		if err != nil {
+			log.Error().Err(err).Msg("some sources are not registered")
+		}
+
+		status, err := r.Status(opts.Sources)
+		if err != nil {
+			log.Fatal().Err(err).Msg("invalid source configuration")
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tSTATUS\tORDER")
+
+		for _, s := range status {
+			state, order := "disabled", "-"
+			if s.Enabled {
+				state, order = "enabled", strconv.Itoa(s.Order)
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%s\n", s.Name, state, order)
+		}
+
+		w.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(sourcesCmd)
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"os"
 
+	"github.com/mhristof/germ/aws"
 	"github.com/mhristof/germ/sso"
 	"github.com/mitchellh/go-homedir"
 	"github.com/rs/zerolog/log"
@@ -20,9 +21,19 @@ var ssoCmd = &cobra.Command{
 			log.Fatal().Msg("cannt retrieve AWS_PROFILE")
 		}
 
-		dir, err := homedir.Expand("~/.aws/config")
+		dir, err := cmd.Flags().GetString("config")
 		if err != nil {
-			log.Fatal().Err(err).Msg("cannot expand aws config path")
+			panic(err)
+		}
+
+		model, err := aws.Load(dir, "")
+		if err != nil {
+			log.Fatal().Err(err).Msg("cannot load aws config")
+		}
+
+		session, ok := model.Session(awsProfile)
+		if !ok {
+			log.Fatal().Str("profile", awsProfile).Msg("profile has no SSO session")
 		}
 
 		config, err := ini.Load(dir)
@@ -30,7 +41,7 @@ var ssoCmd = &cobra.Command{
 			log.Fatal().Err(err).Msg("cannot load aws config")
 		}
 
-		newConfig := sso.UpdateConfig(config, awsProfile, sso.ListAccounts())
+		newConfig := sso.UpdateConfig(config, awsProfile, sso.ListAccounts(session.Region, session.StartURL))
 
 		out, err := cmd.Flags().GetString("out")
 		if err != nil {
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/mhristof/germ/config"
+	"github.com/mhristof/germ/germ"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	debounce time.Duration
+	refresh  bool
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Regenerate the profiles whenever their configuration files change",
+	Run: func(cmd *cobra.Command, args []string) {
+		config.Load()
+
+		// the options are loaded again when germ.yaml changes
+		load := func() (germ.Options, error) {
+			opts, err := loadOptions()
+			if err != nil {
+				return opts, err
+			}
+
+			opts.Report = nil
+			opts.CachedInstances = !refresh
+			// query AWS on start only, not on every germ.yaml change
+			refresh = false
+
+			if split {
+				opts.OutputDir = filepath.Dir(output)
+			} else {
+				opts.Output = output
+			}
+
+			return opts, nil
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		err := germ.Watch(ctx, load, debounce)
+		if err != nil {
+			log.Fatal().Err(err).Msg("cannot watch the configuration files")
+		}
+	},
+}
+
+func init() {
+	watchCmd.Flags().StringVarP(
+		&output, "output", "o",
+		expandUser("~/Library/Application Support/iTerm2/DynamicProfiles/aws-profiles.json"),
+		"File to save the generated profiles",
+	)
+	watchCmd.Flags().StringVarP(&AWSConfig, "aws-config", "a", AWSConfig, "AWS config file path")
+	watchCmd.Flags().StringVarP(&AWSCredentials, "aws-credentials", "c", AWSCredentials, "AWS credentials file path")
+	watchCmd.Flags().StringVarP(&kubeConfig, "kube-config", "k", expandUser("~/.kube/config"), "Kubernetes configuration file")
+	watchCmd.Flags().BoolVarP(&split, "split", "s", false, "Write a germ-<source>.json file per source in the directory of --output")
+	watchCmd.Flags().BoolVarP(&noParent, "no-parent", "", false, "Write every setting in every profile instead of inheriting from the "+germ.ParentName+" profile")
+	watchCmd.Flags().DurationVarP(&debounce, "debounce", "", germ.DefaultDebounce, "Time to wait for more changes before regenerating")
+	watchCmd.Flags().BoolVarP(&refresh, "refresh", "", false, "Query AWS for the SSM instances on start instead of using the cache")
+
+	rootCmd.AddCommand(watchCmd)
+}
@@ -1,11 +1,23 @@
 package config
 
 import (
+	"context"
+	stderrors "errors"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/adrg/xdg"
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/mhristof/germ/accent"
+	"github.com/mhristof/germ/aws"
+	"github.com/mhristof/germ/badge"
+	"github.com/mhristof/germ/classify"
 	"github.com/mhristof/germ/iterm"
+	"github.com/mhristof/germ/override"
+	"github.com/mhristof/germ/scheme"
+	"github.com/mhristof/germ/source"
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
 )
@@ -21,7 +33,7 @@ func Load() {
 	viper.SetConfigType(name[1]) // REQUIRED if the config file does not have the extension in the name
 	viper.AddConfigPath(path)    // path to look for the config file in
 
-	err := viper.ReadInConfig()
+	err := readInConfig()
 	if err != nil {
 		log.Warn().Err(err).Msg("cannot parse config")
 	}
@@ -29,26 +41,255 @@ func Load() {
 	return
 }
 
-func Generate() []iterm.Profile {
-	profiles := viper.GetStringMap("profiles")
+// Sources returns the source selection from the `sources` key, for example
+//
+//	sources:
+//	  enabled: [aws, k8s, ssh]
+//	  disabled: [vault]
+//	  timeout: 2m
+//	  timeouts:
+//	    ssm: 10m
+func Sources() source.Settings {
+	settings := source.Settings{
+		Enabled:        viper.GetStringSlice("sources.enabled"),
+		Disabled:       viper.GetStringSlice("sources.disabled"),
+		DefaultTimeout: viper.GetDuration("sources.timeout"),
+		Timeouts:       map[string]time.Duration{},
+	}
 
-	ret := make([]iterm.Profile, len(profiles))
-	i := 0
+	for name := range viper.GetStringMap("sources.timeouts") {
+		settings.Timeouts[name] = viper.GetDuration("sources.timeouts." + name)
+	}
 
-	for profile := range viper.GetStringMap("profiles") {
-		config := viper.GetStringMapString("profiles." + profile + ".config")
-		pro := iterm.NewProfile(profile, config)
+	return settings
+}
+
+// decodeHook adds the scheme references to the default viper hooks.
+var decodeHook = viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+	mapstructure.StringToTimeDurationHookFunc(),
+	mapstructure.StringToSliceHookFunc(","),
+	scheme.DecodeHook,
+))
+
+// Accent returns how the tab and badge colors are derived from the
+// `accent` key, for example
+//
+//	accent:
+//	  badge_fonts: [Menlo-Bold, Helvetica-Bold]
+//	  pins:
+//	    "123456789012":
+//	      color: "#ff0000"
+//	    payments-cluster:
+//	      color: "#00ff00"
+//	      badge_font: Monaco
+func Accent() (accent.Config, error) {
+	var ret accent.Config
+
+	err := viper.UnmarshalKey("accent", &ret)
+	if err != nil {
+		return accent.Config{}, errors.Wrap(err, "cannot parse accent")
+	}
 
-		var triggers []iterm.Trigger
+	return ret, nil
+}
+
+// Badges returns the badge templates by source name from the `badges` key,
+// see badge.Templates, for example
+//
+//	badges:
+//	  default: "{{.name}}"
+//	  aws: "{{.role}}@{{.account}}"
+//	  ssm: "{{.instance}} {{.az}}"
+func Badges() badge.Templates {
+	return viper.GetStringMapString("badges")
+}
+
+// Keys returns the keyboard shortcuts of all the profiles from the `keys`
+// key, see iterm.Bindings, for example
+//
+//	keys:
+//	  alt+a:
+//	    send_text: "aws sso login\n"
+//	  cmd+shift+|: split_vertical
+//	  cmd+w:
+//	    select_profile: login-foo
+func Keys() iterm.Bindings {
+	return viper.GetStringMap("keys")
+}
+
+// Logins returns the login providers of the AWS profiles the providers
+// cannot detect from the `logins` key, first match wins, for example
+//
+//	logins:
+//	  - match: okta-*
+//	    provider: gimme-aws-creds
+//	  - match: legacy
+//	    provider: none
+func Logins() ([]aws.Login, error) {
+	var ret []aws.Login
+
+	err := viper.UnmarshalKey("logins", &ret)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot parse logins")
+	}
+
+	return ret, nil
+}
+
+// Scheme returns the color scheme of all the profiles from the `scheme`
+// key, either a name or a light and a dark scheme, for example
+//
+//	scheme: Solarized Dark
+//
+//	scheme:
+//	  light: Solarized Light
+//	  dark: ~/schemes/solarized-dark.itermcolors
+func Scheme() (scheme.Ref, error) {
+	var ret scheme.Ref
+
+	err := viper.UnmarshalKey("scheme", &ret, decodeHook)
+	if err != nil {
+		return scheme.Ref{}, errors.Wrap(err, "cannot parse scheme")
+	}
+
+	return ret, nil
+}
+
+// Overrides returns the patches for the generated profiles from the
+// `overrides` key, for example
+//
+//	overrides:
+//	  - match:
+//	      name: k8s-*
+//	    font: Monaco 14
+//	  - match:
+//	      source: ssm
+//	      regex: prod
+//	    badge: PROD
+//	    colors:
+//	      background: "#400000"
+func Overrides() ([]override.Override, error) {
+	var ret []override.Override
+
+	err := viper.UnmarshalKey("overrides", &ret, decodeHook)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot parse overrides")
+	}
+
+	// keep the case of the iTerm2 keys
+	if list, ok := raw("overrides").([]interface{}); ok && len(list) == len(ret) {
+		for i, item := range list {
+			entry, _ := item.(map[string]interface{})
+			if section, ok := entry["iterm"].(map[string]interface{}); ok {
+				ret[i].ITerm = section
+			}
+		}
+	}
+
+	return ret, nil
+}
 
-		err := viper.UnmarshalKey("profiles."+profile+".triggers", &triggers)
+// Classification returns the environments of the profiles from the
+// `classification` key, or classify.Default if it is not set, for example
+//
+//	classification:
+//	  environments:
+//	    prod:
+//	      palette:
+//	        background: "#400000"
+//	      badge: "PROD "
+//	      tab_color: "#ff0000"
+//	    sandbox:
+//	      tab_color: "#00ff00"
+//	  rules:
+//	    - environment: prod
+//	      accounts: ["123456789012"]
+//	    - environment: sandbox
+//	      aliases: ["*-sandbox"]
+func Classification() (classify.Config, error) {
+	if !viper.IsSet("classification") {
+		return classify.Default(), nil
+	}
+
+	var ret classify.Config
+
+	err := viper.UnmarshalKey("classification", &ret, decodeHook)
+	if err != nil {
+		return classify.Config{}, errors.Wrap(err, "cannot parse classification")
+	}
+
+	return ret, nil
+}
+
+// History returns the number of generations to keep from `history.keep`.
+func History() int {
+	return viper.GetInt("history.keep")
+}
+
+// Names returns how long the unique names of profiles that are no longer
+// generated are kept, from `names.prune_after`. A negative value keeps
+// them forever.
+func Names() time.Duration {
+	return viper.GetDuration("names.prune_after")
+}
+
+// Source generates the custom profiles defined in germ.yaml.
+type Source struct{}
+
+func (s *Source) Name() string {
+	return "config"
+}
+
+func (s *Source) Inputs() []string {
+	return []string{Path()}
+}
+
+func (s *Source) Generate(ctx context.Context) ([]iterm.Profile, error) {
+	return Generate(iterm.GenerationFrom(ctx))
+}
+
+// Generate returns the profiles defined in germ.yaml. gen may be nil.
+func Generate(gen *iterm.Generation) ([]iterm.Profile, error) {
+	var ret []iterm.Profile
+	var errs []error
+
+	templates, err := loadTemplates()
+	if err != nil {
+		return nil, err
+	}
+
+	for profile := range viper.GetStringMap("profiles") {
+		def, err := loadDefinition("profiles." + profile)
 		if err == nil {
-			pro.Triggers = append(pro.Triggers, triggers...)
+			def, err = resolve(def, templates, nil)
+		}
+
+		var config map[string]string
+		if err == nil {
+			config, err = render(profile, def.Config)
+		}
+
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "profile %s", profile))
+			continue
+		}
+
+		pro, err := gen.NewProfile(profile, config)
+		if err != nil {
+			errs = append(errs, err)
+		}
+
+		pro.Triggers = append(pro.Triggers, def.Triggers...)
+
+		for key, value := range def.ITerm {
+			err = pro.Set(key, value)
+			if err != nil {
+				errs = append(errs, errors.Wrapf(err, "profile %s", profile))
+			}
 		}
 
-		ret[i] = *pro
-		i++
+		ret = append(ret, *pro)
 	}
 
-	return ret
+	return ret, stderrors.Join(errs...)
 }
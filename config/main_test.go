@@ -3,8 +3,16 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/MakeNowJust/heredoc"
+	"github.com/mhristof/germ/accent"
+	"github.com/mhristof/germ/aws"
+	"github.com/mhristof/germ/classify"
+	"github.com/mhristof/germ/iterm"
+	"github.com/mhristof/germ/override"
+	"github.com/mhristof/germ/scheme"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 )
@@ -89,7 +97,8 @@ func TestGenerate(t *testing.T) {
 				viper.Set(key, value)
 			}
 
-			profiles := Generate()
+			profiles, err := Generate(nil)
+			assert.NoError(t, err)
 			assert.Equal(t, test.expected, len(profiles))
 
 			if test.expected > 0 {
@@ -158,4 +167,340 @@ func TestLoadNonExistentConfig(t *testing.T) {
 	assert.NotPanics(t, func() {
 		Load()
 	})
-}
\ No newline at end of file
+}
+func TestSources(t *testing.T) {
+	viper.Reset()
+	viper.Set("sources", map[string]interface{}{
+		"enabled":  []string{"aws", "ssh"},
+		"disabled": []string{"vault"},
+	})
+	defer viper.Reset()
+
+	settings := Sources()
+	assert.Equal(t, []string{"aws", "ssh"}, settings.Enabled)
+	assert.Equal(t, []string{"vault"}, settings.Disabled)
+}
+
+func TestOverrides(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	viper.SetConfigType("yaml")
+	err := viper.ReadConfig(strings.NewReader(heredoc.Doc(`
+		overrides:
+		  - match:
+		      name: k8s-*
+		      tag: aws-profile=*
+		    font: Monaco 14
+		    command_prefix: env FOO=bar
+		    colors:
+		      background: "#400000"
+		  - match:
+		      source: ssm
+		    badge: PROD
+		    triggers:
+		      - action: HighlightTrigger
+		        regex: ERROR
+		    keyboard_map:
+		      0x61-0x80000:
+		        action: 12
+		        text: ls
+	`)))
+	assert.NoError(t, err)
+
+	overrides, err := Overrides()
+	assert.NoError(t, err)
+	assert.Len(t, overrides, 2)
+
+	assert.Equal(t, override.Selector{Name: "k8s-*", Tag: "aws-profile=*"}, overrides[0].Match)
+	assert.Equal(t, "Monaco 14", overrides[0].Font)
+	assert.Equal(t, "env FOO=bar", overrides[0].CommandPrefix)
+	assert.Equal(t, map[string]string{"background": "#400000"}, overrides[0].Colors)
+	assert.Nil(t, overrides[0].Badge)
+
+	assert.Equal(t, "ssm", overrides[1].Match.Source)
+	assert.Equal(t, "PROD", *overrides[1].Badge)
+	assert.Equal(t, []iterm.Trigger{{Action: "HighlightTrigger", Regex: "ERROR"}}, overrides[1].Triggers)
+	assert.Equal(t, iterm.KeyboardMap{Action: 12, Text: "ls"}, overrides[1].KeyboardMap["0x61-0x80000"])
+}
+
+func TestClassification(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	config, err := Classification()
+	assert.NoError(t, err)
+	assert.Equal(t, classify.Default(), config)
+
+	viper.SetConfigType("yaml")
+	err = viper.ReadConfig(strings.NewReader(heredoc.Doc(`
+		classification:
+		  environments:
+		    prod:
+		      palette:
+		        background: "#400000"
+		      badge: "PROD "
+		      tab_color: "#ff0000"
+		  rules:
+		    - environment: prod
+		      accounts: ["123456789012"]
+		      cluster: "*-prod"
+	`)))
+	assert.NoError(t, err)
+
+	config, err = Classification()
+	assert.NoError(t, err)
+	assert.Equal(t, classify.Config{
+		Environments: map[string]classify.Environment{
+			"prod": {
+				Palette:  map[string]string{"background": "#400000"},
+				Badge:    "PROD ",
+				TabColor: "#ff0000",
+			},
+		},
+		Rules: []classify.Rule{
+			{Environment: "prod", Accounts: []string{"123456789012"}, Cluster: "*-prod"},
+		},
+	}, config)
+}
+
+func TestScheme(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	ref, err := Scheme()
+	assert.NoError(t, err)
+	assert.True(t, ref.IsZero())
+
+	viper.SetConfigType("yaml")
+	err = viper.ReadConfig(strings.NewReader(heredoc.Doc(`
+		scheme:
+		  light: Solarized Light
+		  dark: Solarized Dark
+		classification:
+		  environments:
+		    prod:
+		      scheme: Red Alert
+		overrides:
+		  - match:
+		      name: k8s-*
+		    scheme: ~/k8s.itermcolors
+	`)))
+	assert.NoError(t, err)
+
+	ref, err = Scheme()
+	assert.NoError(t, err)
+	assert.Equal(t, scheme.Ref{Light: "Solarized Light", Dark: "Solarized Dark"}, ref)
+
+	classification, err := Classification()
+	assert.NoError(t, err)
+	assert.Equal(t, scheme.Ref{Name: "Red Alert"}, classification.Environments["prod"].Scheme)
+
+	overrides, err := Overrides()
+	assert.NoError(t, err)
+	assert.Equal(t, scheme.Ref{Name: "~/k8s.itermcolors"}, overrides[0].Scheme)
+}
+
+func TestAccent(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	viper.SetConfigType("yaml")
+	err := viper.ReadConfig(strings.NewReader(heredoc.Doc(`
+		accent:
+		  badge_fonts: [Menlo-Bold]
+		  pins:
+		    "123456789012":
+		      color: "#ff0000"
+		    payments:
+		      badge_font: Monaco
+	`)))
+	assert.NoError(t, err)
+
+	config, err := Accent()
+	assert.NoError(t, err)
+	assert.Equal(t, accent.Config{
+		BadgeFonts: []string{"Menlo-Bold"},
+		Pins: map[string]accent.Pin{
+			"123456789012": {Color: "#ff0000"},
+			"payments":     {BadgeFont: "Monaco"},
+		},
+	}, config)
+}
+
+func TestKeys(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	viper.SetConfigType("yaml")
+	err := viper.ReadConfig(strings.NewReader(heredoc.Doc(`
+		keys:
+		  alt+a:
+		    send_text: "aws sso login\n"
+		  cmd+shift+|: split_vertical
+	`)))
+	assert.NoError(t, err)
+
+	maps, err := Keys().Compile()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]iterm.KeyboardMap{
+		iterm.KeyboardSortcutAltA: {Action: iterm.KeyboardSendText, Text: "aws sso login\n"},
+		"0x7c-0x120000": {
+			Action: iterm.KeyboardSelectMenuItem,
+			Text:   "Split Vertically with Current Profile\nSplit Vertically with Current Profile",
+		},
+	}, maps)
+}
+
+func TestLogins(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	viper.SetConfigType("yaml")
+	err := viper.ReadConfig(strings.NewReader(heredoc.Doc(`
+		logins:
+		  - match: Okta-*
+		    provider: gimme-aws-creds
+		  - match: legacy
+		    provider: none
+	`)))
+	assert.NoError(t, err)
+
+	logins, err := Logins()
+	assert.NoError(t, err)
+	assert.Equal(t, []aws.Login{
+		{Match: "Okta-*", Provider: "gimme-aws-creds"},
+		{Match: "legacy", Provider: aws.NoLogin},
+	}, logins)
+}
+
+func TestTemplates(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	t.Setenv("GERM_TEST_USER", "jdoe")
+
+	viper.SetConfigType("yaml")
+	err := viper.ReadConfig(strings.NewReader(heredoc.Doc(`
+		templates:
+		  ssh:
+		    config:
+		      command: ssh {{ .Env.GERM_TEST_USER }}@{{ .Name }}
+		      region: eu-west-1
+		    triggers:
+		      - action: HighlightTrigger
+		        regex: WARN
+		  prod:
+		    extends: [ssh]
+		    config:
+		      region: us-east-1
+		    triggers:
+		      - action: HighlightTrigger
+		        regex: ERROR
+		  audit:
+		    extends: [ssh]
+		  loop-a:
+		    extends: [loop-b]
+		  loop-b:
+		    extends: [loop-a]
+		profiles:
+		  host1:
+		    extends: [prod]
+		  host2:
+		    extends: [prod]
+		    replace: [triggers]
+		    config:
+		      command: ssh {{ .Name }} -p {{ env "GERM_TEST_USER" }}
+		    triggers:
+		      - action: BellTrigger
+		        regex: DONE
+		  host3:
+		    extends: [prod, audit]
+		  missing:
+		    extends: [nope]
+		  cycle:
+		    extends: [loop-a]
+	`)))
+	assert.NoError(t, err)
+
+	profiles, err := Generate(nil)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "profile missing: unknown template nope")
+	assert.Contains(t, err.Error(), "profile cycle: template cycle loop-a -> loop-b -> loop-a")
+
+	byName := map[string]iterm.Profile{}
+	for _, p := range profiles {
+		byName[p.Name] = p
+	}
+
+	assert.Len(t, byName, 3)
+
+	host1 := byName["host1"]
+	assert.Equal(t, "ssh jdoe@host1", host1.Command)
+	assert.Subset(t, host1.Tags, iterm.AWSRegionTags["us-east-1"])
+	assert.Subset(t, host1.Triggers, []iterm.Trigger{
+		{Action: "HighlightTrigger", Regex: "WARN"},
+		{Action: "HighlightTrigger", Regex: "ERROR"},
+	})
+
+	host2 := byName["host2"]
+	assert.Equal(t, "ssh host2 -p jdoe", host2.Command)
+	assert.Subset(t, host2.Triggers, []iterm.Trigger{{Action: "BellTrigger", Regex: "DONE"}})
+	assert.NotContains(t, host2.Triggers, iterm.Trigger{Action: "HighlightTrigger", Regex: "WARN"})
+
+	warn := 0
+	for _, trigger := range byName["host3"].Triggers {
+		if trigger == (iterm.Trigger{Action: "HighlightTrigger", Regex: "WARN"}) {
+			warn++
+		}
+	}
+
+	assert.Equal(t, 1, warn, "the triggers of a shared template are added once")
+}
+
+func TestITermKeys(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	path := filepath.Join(t.TempDir(), "germ.yaml")
+	err := os.WriteFile(path, []byte(heredoc.Doc(`
+		templates:
+		  base:
+		    iterm:
+		      Normal Font: Monaco 12
+		      Background Color: "#202020"
+		profiles:
+		  foo:
+		    extends: [base]
+		    iterm:
+		      Normal Font: Monaco 14
+		      Working Directory: /tmp
+		      Bound Hosts: [foo.example.com]
+		      My Custom Key:
+		        Nested Value: 1
+		  broken:
+		    iterm:
+		      Unlimited Scrollback: "yes"
+	`)), 0o644)
+	assert.NoError(t, err)
+
+	viper.SetConfigFile(path)
+	assert.NoError(t, readInConfig())
+
+	profiles, err := Generate(nil)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "profile broken: invalid value for Unlimited Scrollback")
+
+	var foo iterm.Profile
+	for _, p := range profiles {
+		if p.Name == "foo" {
+			foo = p
+		}
+	}
+
+	assert.Equal(t, "Monaco 14", foo.NormalFont)
+	assert.Equal(t, 32.0/255, foo.BackgroundColor.RedComponent)
+	assert.Equal(t, []string{"foo.example.com"}, foo.BoundHosts)
+	assert.Equal(t, "/tmp", foo.Extra["Working Directory"])
+	assert.Equal(t, map[string]interface{}{"Nested Value": 1}, foo.Extra["My Custom Key"])
+}
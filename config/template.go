@@ -0,0 +1,293 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/mhristof/germ/iterm"
+	"github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
+)
+
+// definition is a profile or a template from germ.yaml.
+type definition struct {
+	// Extends lists the templates merged below this definition, in order.
+	Extends []string
+	// Replace lists the keys, config, triggers or iterm, that replace the
+	// inherited values instead of being merged with them.
+	Replace  []string
+	Config   map[string]string
+	Triggers []iterm.Trigger
+	// ITerm holds iTerm2 profile keys set as is, for example Normal Font.
+	ITerm map[string]interface{}
+}
+
+func loadDefinition(key string) (definition, error) {
+	def := definition{
+		Extends: viper.GetStringSlice(key + ".extends"),
+		Replace: viper.GetStringSlice(key + ".replace"),
+		Config:  viper.GetStringMapString(key + ".config"),
+	}
+
+	err := viper.UnmarshalKey(key+".triggers", &def.Triggers)
+	if err != nil {
+		return def, errors.Wrapf(err, "cannot parse triggers for %s", key)
+	}
+
+	def.ITerm = viper.GetStringMap(key + ".iterm")
+	if section, ok := raw(key + ".iterm").(map[string]interface{}); ok {
+		def.ITerm = section
+	}
+
+	return def, nil
+}
+
+func loadTemplates() (map[string]definition, error) {
+	ret := map[string]definition{}
+
+	for name := range viper.GetStringMap("templates") {
+		def, err := loadDefinition("templates." + name)
+		if err != nil {
+			return nil, err
+		}
+
+		ret[name] = def
+	}
+
+	return ret, nil
+}
+
+// merge returns base with top applied on it. Config values of top override
+// the ones of base and triggers are appended, unless the key is listed in
+// top.Replace.
+func (base definition) merge(top definition) definition {
+	ret := definition{
+		Config:   map[string]string{},
+		Triggers: append([]iterm.Trigger{}, base.Triggers...),
+		ITerm:    map[string]interface{}{},
+	}
+
+	if !top.replaces("config") {
+		for k, v := range base.Config {
+			ret.Config[k] = v
+		}
+	}
+
+	if !top.replaces("iterm") {
+		for k, v := range base.ITerm {
+			ret.ITerm[k] = v
+		}
+	}
+
+	for k, v := range top.ITerm {
+		ret.ITerm[k] = v
+	}
+
+	for k, v := range top.Config {
+		ret.Config[k] = v
+	}
+
+	if top.replaces("triggers") {
+		ret.Triggers = []iterm.Trigger{}
+	}
+
+	ret.Triggers = append(ret.Triggers, top.Triggers...)
+
+	return ret
+}
+
+func (d definition) replaces(key string) bool {
+	return slices.Contains(d.Replace, key)
+}
+
+// resolve merges the templates def extends, recursively, and def itself.
+// chain holds the templates being resolved to detect cycles.
+func resolve(def definition, templates map[string]definition, chain []string) (definition, error) {
+	ret := definition{}
+
+	for _, name := range def.Extends {
+		if slices.Contains(chain, name) {
+			return definition{}, fmt.Errorf("template cycle %s -> %s", strings.Join(chain, " -> "), name)
+		}
+
+		tmpl, ok := templates[name]
+		if !ok {
+			return definition{}, fmt.Errorf("unknown template %s", name)
+		}
+
+		parent, err := resolve(tmpl, templates, append(chain, name))
+		if err != nil {
+			return definition{}, err
+		}
+
+		ret = ret.merge(parent)
+	}
+
+	ret = ret.merge(def)
+	// a template extended through two parents adds its triggers twice
+	ret.Triggers = unique(ret.Triggers)
+
+	return ret, nil
+}
+
+// unique returns the triggers without the repeated ones, in order.
+func unique(triggers []iterm.Trigger) []iterm.Trigger {
+	ret := []iterm.Trigger{}
+	seen := map[iterm.Trigger]struct{}{}
+
+	for _, t := range triggers {
+		if _, ok := seen[t]; ok {
+			continue
+		}
+
+		seen[t] = struct{}{}
+		ret = append(ret, t)
+	}
+
+	return ret
+}
+
+// document is the config file used by viper, parsed with the case of the
+// keys preserved, see raw.
+var document struct {
+	path string
+	tree interface{}
+}
+
+// readInConfig reads the config file into viper and parses it once for raw.
+func readInConfig() error {
+	document.path, document.tree = "", nil
+
+	err := viper.ReadInConfig()
+	if err != nil {
+		return err
+	}
+
+	path := viper.ConfigFileUsed()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return errors.Wrapf(err, "cannot read %s", path)
+	}
+
+	var tree interface{}
+
+	err = yaml.Unmarshal(data, &tree)
+	if err != nil {
+		return errors.Wrapf(err, "cannot parse %s", path)
+	}
+
+	document.path, document.tree = path, normalize(tree)
+
+	return nil
+}
+
+// raw returns the value of key from the config file with the case of the
+// keys preserved, viper lower cases them. It returns nil if the config file
+// was not loaded or key is not in it.
+func raw(key string) interface{} {
+	if document.path == "" || document.path != viper.ConfigFileUsed() {
+		return nil
+	}
+
+	doc := document.tree
+
+	for _, part := range strings.Split(key, ".") {
+		section, ok := doc.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+
+		doc = nil
+
+		for k, v := range section {
+			if strings.EqualFold(k, part) {
+				doc = v
+				break
+			}
+		}
+
+		if doc == nil {
+			return nil
+		}
+	}
+
+	return doc
+}
+
+// normalize converts the maps decoded by yaml to map[string]interface{}.
+func normalize(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[interface{}]interface{}:
+		ret := map[string]interface{}{}
+		for k, v := range value {
+			ret[fmt.Sprintf("%v", k)] = normalize(v)
+		}
+
+		return ret
+	case []interface{}:
+		ret := make([]interface{}, len(value))
+		for i, v := range value {
+			ret[i] = normalize(v)
+		}
+
+		return ret
+	default:
+		return v
+	}
+}
+
+// templateData is available to the config values, for example
+// {{ .Name }}, {{ .Home }} or {{ .Env.USER }}.
+type templateData struct {
+	Name string
+	Home string
+	Env  map[string]string
+}
+
+// render executes the config values as Go templates.
+func render(name string, config map[string]string) (map[string]string, error) {
+	home, err := homedir.Dir()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot find home directory")
+	}
+
+	data := templateData{
+		Name: name,
+		Home: home,
+		Env:  map[string]string{},
+	}
+
+	for _, kv := range os.Environ() {
+		parts := strings.SplitN(kv, "=", 2)
+		data.Env[parts[0]] = parts[1]
+	}
+
+	ret := map[string]string{}
+
+	for k, v := range config {
+		t, err := template.New(k).Option("missingkey=zero").Funcs(template.FuncMap{
+			"env": os.Getenv,
+		}).Parse(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot parse template of %s", k)
+		}
+
+		var out bytes.Buffer
+
+		err = t.Execute(&out, data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot render template of %s", k)
+		}
+
+		ret[k] = out.String()
+	}
+
+	return ret, nil
+}
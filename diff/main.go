@@ -0,0 +1,253 @@
+// Package diff compares two sets of profiles profile by profile.
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mhristof/germ/iterm"
+	"github.com/pkg/errors"
+)
+
+// Timestamps can be passed to Ignore to skip the generation timestamp tags.
+const Timestamps = "timestamps"
+
+// DefaultIgnore are the volatile fields ignored unless configured otherwise.
+var DefaultIgnore = []string{Timestamps}
+
+// Change is a field with a different value in the two profiles.
+type Change struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// Profile lists the changes of a profile found in both sets.
+type Profile struct {
+	GUID    string   `json:"guid"`
+	Name    string   `json:"name"`
+	OldName string   `json:"oldName,omitempty"`
+	Changes []Change `json:"changes,omitempty"`
+}
+
+// Result is the difference between two sets of profiles.
+type Result struct {
+	Added   []string  `json:"added"`
+	Removed []string  `json:"removed"`
+	Renamed []Profile `json:"renamed"`
+	Changed []Profile `json:"changed"`
+}
+
+// Empty returns true when the two sets are the same.
+func (r Result) Empty() bool {
+	return len(r.Added) == 0 && len(r.Removed) == 0 && len(r.Renamed) == 0 && len(r.Changed) == 0
+}
+
+// Profiles compares the profiles by GUID. ignore holds iTerm2 field names,
+// for example "Tags", or Timestamps, that are not compared.
+func Profiles(old, new []iterm.Profile, ignore []string) (Result, error) {
+	ret := Result{
+		Added:   []string{},
+		Removed: []string{},
+		Renamed: []Profile{},
+		Changed: []Profile{},
+	}
+
+	oldByGUID := map[string]iterm.Profile{}
+	for _, p := range old {
+		oldByGUID[p.GUID] = p
+	}
+
+	newGUIDs := map[string]struct{}{}
+
+	for _, p := range new {
+		newGUIDs[p.GUID] = struct{}{}
+
+		prev, ok := oldByGUID[p.GUID]
+		if !ok {
+			ret.Added = append(ret.Added, p.Name)
+			continue
+		}
+
+		changes, err := compare(prev, p, ignore)
+		if err != nil {
+			return Result{}, err
+		}
+
+		if prev.Name != p.Name {
+			ret.Renamed = append(ret.Renamed, Profile{
+				GUID:    p.GUID,
+				Name:    p.Name,
+				OldName: prev.Name,
+				Changes: changes,
+			})
+
+			continue
+		}
+
+		if len(changes) > 0 {
+			ret.Changed = append(ret.Changed, Profile{
+				GUID:    p.GUID,
+				Name:    p.Name,
+				Changes: changes,
+			})
+		}
+	}
+
+	for _, p := range old {
+		if _, ok := newGUIDs[p.GUID]; !ok {
+			ret.Removed = append(ret.Removed, p.Name)
+		}
+	}
+
+	sort.Strings(ret.Added)
+	sort.Strings(ret.Removed)
+
+	for _, list := range [][]Profile{ret.Renamed, ret.Changed} {
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].Name < list[j].Name
+		})
+	}
+
+	return ret, nil
+}
+
+func compare(old, new iterm.Profile, ignore []string) ([]Change, error) {
+	oldFields, err := fields(old, ignore)
+	if err != nil {
+		return nil, err
+	}
+
+	newFields, err := fields(new, ignore)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for k := range oldFields {
+		keys = append(keys, k)
+	}
+
+	for k := range newFields {
+		if _, ok := oldFields[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+
+	var ret []Change
+
+	for _, k := range keys {
+		if k == "Name" || reflect.DeepEqual(oldFields[k], newFields[k]) {
+			continue
+		}
+
+		ret = append(ret, Change{Field: k, Old: oldFields[k], New: newFields[k]})
+	}
+
+	return ret, nil
+}
+
+// fields returns the profile as iTerm2 sees it, without the ignored fields.
+func fields(p iterm.Profile, ignore []string) (map[string]interface{}, error) {
+	for _, i := range ignore {
+		if i == Timestamps {
+			p.Tags = withoutTimestamps(p.Tags)
+		}
+	}
+
+	data, err := json.Marshal(p)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot marshal profile %s", p.Name)
+	}
+
+	var ret map[string]interface{}
+
+	err = json.Unmarshal(data, &ret)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot unmarshal profile %s", p.Name)
+	}
+
+	for _, i := range ignore {
+		delete(ret, i)
+	}
+
+	return ret, nil
+}
+
+func withoutTimestamps(tags []string) []string {
+	ret := []string{}
+
+	for _, tag := range tags {
+		if _, err := time.Parse(time.RFC3339, tag); err == nil {
+			continue
+		}
+
+		ret = append(ret, tag)
+	}
+
+	return ret
+}
+
+// Write prints the result in the given format, text or json.
+func Write(w io.Writer, r Result, format string) error {
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(r, "", "    ")
+		if err != nil {
+			return errors.Wrap(err, "cannot marshal diff")
+		}
+
+		_, err = fmt.Fprintln(w, string(data))
+
+		return err
+	case "text":
+		for _, name := range r.Added {
+			fmt.Fprintf(w, "+ %s\n", name)
+		}
+
+		for _, name := range r.Removed {
+			fmt.Fprintf(w, "- %s\n", name)
+		}
+
+		for _, p := range r.Renamed {
+			fmt.Fprintf(w, "~ %s -> %s\n", p.OldName, p.Name)
+			writeChanges(w, p.Changes)
+		}
+
+		for _, p := range r.Changed {
+			fmt.Fprintf(w, "~ %s\n", p.Name)
+			writeChanges(w, p.Changes)
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("unknown diff format %q", format)
+	}
+}
+
+func writeChanges(w io.Writer, changes []Change) {
+	for _, c := range changes {
+		fmt.Fprintf(w, "    %s: %s -> %s\n", c.Field, value(c.Old), value(c.New))
+	}
+}
+
+func value(v interface{}) string {
+	var buf bytes.Buffer
+
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+
+	if err := enc.Encode(v); err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+
+	return strings.TrimSpace(buf.String())
+}
@@ -0,0 +1,128 @@
+package diff
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/mhristof/germ/iterm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfiles(t *testing.T) {
+	cases := []struct {
+		name   string
+		old    []iterm.Profile
+		new    []iterm.Profile
+		ignore []string
+		out    Result
+		text   string
+	}{
+		{
+			name: "identical",
+			old:  []iterm.Profile{{GUID: "1", Name: "a"}},
+			new:  []iterm.Profile{{GUID: "1", Name: "a"}},
+		},
+		{
+			name: "added and removed",
+			old:  []iterm.Profile{{GUID: "1", Name: "a"}, {GUID: "2", Name: "b"}},
+			new:  []iterm.Profile{{GUID: "2", Name: "b"}, {GUID: "3", Name: "c"}},
+			out: Result{
+				Added:   []string{"c"},
+				Removed: []string{"a"},
+			},
+			text: heredoc.Doc(`
+				+ c
+				- a
+			`),
+		},
+		{
+			name: "renamed",
+			old:  []iterm.Profile{{GUID: "1", Name: "a"}},
+			new:  []iterm.Profile{{GUID: "1", Name: "b", Command: "ls"}},
+			out: Result{
+				Renamed: []Profile{
+					{
+						GUID:    "1",
+						Name:    "b",
+						OldName: "a",
+						Changes: []Change{{Field: "Command", Old: "", New: "ls"}},
+					},
+				},
+			},
+			text: heredoc.Doc(`
+				~ a -> b
+				    Command: "" -> "ls"
+			`),
+		},
+		{
+			name:   "timestamps are ignored",
+			old:    []iterm.Profile{{GUID: "1", Name: "a", Tags: []string{"aws", "2021-03-01T16:01:03Z"}}},
+			new:    []iterm.Profile{{GUID: "1", Name: "a", Tags: []string{"aws", "2022-03-01T16:01:03Z"}}},
+			ignore: DefaultIgnore,
+		},
+		{
+			name: "timestamps are compared unless ignored",
+			old:  []iterm.Profile{{GUID: "1", Name: "a", Tags: []string{"2021-03-01T16:01:03Z"}}},
+			new:  []iterm.Profile{{GUID: "1", Name: "a", Tags: []string{"2022-03-01T16:01:03Z"}}},
+			out: Result{
+				Changed: []Profile{
+					{
+						GUID: "1",
+						Name: "a",
+						Changes: []Change{{
+							Field: "Tags",
+							Old:   []interface{}{"2021-03-01T16:01:03Z"},
+							New:   []interface{}{"2022-03-01T16:01:03Z"},
+						}},
+					},
+				},
+			},
+			text: heredoc.Doc(`
+				~ a
+				    Tags: ["2021-03-01T16:01:03Z"] -> ["2022-03-01T16:01:03Z"]
+			`),
+		},
+		{
+			name:   "ignored fields",
+			old:    []iterm.Profile{{GUID: "1", Name: "a", Command: "ls"}},
+			new:    []iterm.Profile{{GUID: "1", Name: "a", Command: "ls && date"}},
+			ignore: []string{"Command"},
+		},
+	}
+
+	for _, test := range cases {
+		res, err := Profiles(test.old, test.new, test.ignore)
+		assert.NoError(t, err, test.name)
+
+		assert.ElementsMatch(t, test.out.Added, res.Added, test.name)
+		assert.ElementsMatch(t, test.out.Removed, res.Removed, test.name)
+		assert.ElementsMatch(t, test.out.Renamed, res.Renamed, test.name)
+		assert.ElementsMatch(t, test.out.Changed, res.Changed, test.name)
+		assert.Equal(t, test.text == "", res.Empty(), test.name)
+
+		var text bytes.Buffer
+		assert.NoError(t, Write(&text, res, "text"), test.name)
+		assert.Equal(t, test.text, text.String(), test.name)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	res, err := Profiles(nil, []iterm.Profile{{GUID: "1", Name: "a"}}, nil)
+	assert.NoError(t, err)
+
+	var js bytes.Buffer
+	assert.NoError(t, Write(&js, res, "json"))
+	assert.Equal(t, heredoc.Doc(`
+		{
+		    "added": [
+		        "a"
+		    ],
+		    "removed": [],
+		    "renamed": [],
+		    "changed": []
+		}
+	`), js.String())
+
+	assert.Error(t, Write(&js, res, "yaml"))
+}
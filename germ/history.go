@@ -0,0 +1,128 @@
+package germ
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mhristof/germ/atomic"
+	"github.com/mhristof/germ/history"
+	"github.com/mhristof/germ/iterm"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// record saves the files written by the generation into the history.
+func record(opts Options) error {
+	var paths []string
+
+	if opts.Output != "" {
+		paths = append(paths, opts.Output)
+	}
+
+	if opts.OutputDir != "" {
+		owned, err := ownedFiles(opts.OutputDir)
+		if err != nil {
+			return err
+		}
+
+		for _, path := range owned {
+			paths = append(paths, path)
+		}
+	}
+
+	var files []history.File
+
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "cannot read %s", path)
+		}
+
+		var prof iterm.Profiles
+		if err := json.Unmarshal(data, &prof); err != nil {
+			log.Warn().Err(err).Str("path", path).Msg("cannot count profiles")
+		}
+
+		files = append(files, history.File{
+			Path:     path,
+			Profiles: len(prof.Profiles),
+			Data:     data,
+		})
+	}
+
+	gen, err := opts.History.Save(files)
+	if err != nil {
+		return err
+	}
+
+	log.Debug().Int("generation", gen.ID).Msg("recorded generation")
+
+	return nil
+}
+
+// Rollback restores the files of the generation with the given id, or the
+// one before the latest if id is 0. Split files of sources that were not part
+// of the generation are removed.
+func Rollback(store *history.Store, id int) (history.Generation, error) {
+	if id == 0 {
+		list, err := store.List()
+		if err != nil {
+			return history.Generation{}, err
+		}
+
+		if len(list) < 2 {
+			return history.Generation{}, fmt.Errorf("no previous generation to roll back to")
+		}
+
+		id = list[1].ID
+	}
+
+	gen, err := store.Load(id)
+	if err != nil {
+		return history.Generation{}, err
+	}
+
+	var errs []error
+	restored := map[string]struct{}{}
+	splitDirs := map[string]struct{}{}
+
+	for _, f := range gen.Files {
+		err = atomic.WriteFile(f.Path, f.Data, 0o644)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		restored[f.Path] = struct{}{}
+
+		if strings.HasPrefix(filepath.Base(f.Path), splitPrefix) {
+			splitDirs[filepath.Dir(f.Path)] = struct{}{}
+		}
+	}
+
+	for dir := range splitDirs {
+		owned, err := ownedFiles(dir)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		for _, path := range owned {
+			if _, ok := restored[path]; ok {
+				continue
+			}
+
+			err = os.Remove(path)
+			if err != nil {
+				errs = append(errs, errors.Wrapf(err, "cannot remove %s", path))
+			}
+		}
+	}
+
+	return gen, stderrors.Join(errs...)
+}
@@ -0,0 +1,449 @@
+// Package germ generates iTerm2 dynamic profiles from all the registered
+// sources. It is the engine behind `germ generate` and can be embedded in
+// other tools.
+package germ
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mhristof/germ/accent"
+	"github.com/mhristof/germ/atomic"
+	"github.com/mhristof/germ/aws"
+	"github.com/mhristof/germ/badge"
+	"github.com/mhristof/germ/classify"
+	"github.com/mhristof/germ/config"
+	"github.com/mhristof/germ/history"
+	"github.com/mhristof/germ/iterm"
+	"github.com/mhristof/germ/k8s"
+	"github.com/mhristof/germ/keychain"
+	"github.com/mhristof/germ/override"
+	"github.com/mhristof/germ/scheme"
+	"github.com/mhristof/germ/source"
+	"github.com/mhristof/germ/ssh"
+	"github.com/mhristof/germ/ssm"
+	"github.com/mhristof/germ/vault"
+	"github.com/mhristof/germ/vim"
+	"github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// Options configures a profile generation.
+type Options struct {
+	// AWSConfig is the path of the AWS config file.
+	AWSConfig string
+	// AWSCredentials is the path of the AWS credentials file.
+	AWSCredentials string
+	// Logins pick the login provider of the AWS profiles.
+	Logins []aws.Login
+	// KubeConfig is the path of the kubernetes config file.
+	KubeConfig string
+	// KeyChain holds the secrets used for the keychain profiles.
+	KeyChain keychain.KeyChain
+	// DefaultProfile is the name of the default profile.
+	DefaultProfile string
+	// CachedInstances reuses the SSM instances from the previous run
+	// instead of querying AWS.
+	CachedInstances bool
+	// DryRun avoids writing the split kubeconfig files.
+	DryRun bool
+	// Sources selects and orders the sources to run.
+	Sources source.Settings
+	// Extra sources are registered after the builtin ones.
+	Extra []source.ProfileSource
+	// Overrides patch the profiles after all the sources run.
+	Overrides []override.Override
+	// Scheme is the color scheme of all the profiles, applied before the
+	// classification.
+	Scheme scheme.Ref
+	// Accent sets the tab and badge colors from what the profiles connect
+	// to, after the scheme.
+	Accent accent.Config
+	// Badges are the badge templates by source, rendered before the
+	// environment badge prefix is added.
+	Badges badge.Templates
+	// Classification colors and badges the profiles by environment, before
+	// the overrides.
+	Classification classify.Config
+	// Keys are the keyboard shortcuts of all the profiles, applied after
+	// the classification.
+	Keys iterm.Bindings
+	// NamesPruneAfter is how long the unique names of the profiles that are
+	// no longer generated are kept, see names.Store.PruneAfter.
+	NamesPruneAfter time.Duration
+	// Parent adds a profile with the shared defaults and writes the other
+	// profiles with only the keys that differ from it.
+	Parent bool
+
+	// Output is the file the profiles are written to. Nothing is written
+	// when it is empty.
+	Output string
+	// OutputDir, if set, gets one germ-<source>.json file per source
+	// instead of the single Output file.
+	OutputDir string
+	// History, if set, records a copy of the written files.
+	History *history.Store
+	// Report receives the generation report, if set.
+	Report io.Writer
+	// ReportFormat is the format of the report, text or json.
+	ReportFormat string
+}
+
+// DefaultOptions returns the options used by `germ generate` without any
+// flags. Use config.Load and config.Sources to honour germ.yaml.
+func DefaultOptions() Options {
+	return Options{
+		AWSConfig:      expand("~/.aws/config"),
+		AWSCredentials: expand("~/.aws/credentials"),
+		KubeConfig:     expand("~/.kube/config"),
+		KeyChain: keychain.KeyChain{
+			Service:     "germ",
+			AccessGroup: "germ",
+		},
+		DefaultProfile: "default-profile",
+		Classification: classify.Default(),
+		Parent:         true,
+		ReportFormat:   "text",
+	}
+}
+
+// Registry returns all the profile sources in their default order. The
+// extra sources clashing with another source are reported in the returned
+// error and left out of the registry.
+func Registry(opts Options) (*source.Registry, error) {
+	keyChain := opts.KeyChain
+
+	r := source.NewRegistry(
+		&aws.Source{Config: opts.AWSConfig, Credentials: opts.AWSCredentials, Logins: opts.Logins},
+		&k8s.Source{Config: opts.KubeConfig, DryRun: opts.DryRun},
+		&keyChain,
+		source.Func("default", func(ctx context.Context) ([]iterm.Profile, error) {
+			prof, err := iterm.GenerationFrom(ctx).NewProfile(opts.DefaultProfile, map[string]string{
+				"AllowTitleSetting": "true",
+				"BadgeText":         "",
+			})
+
+			return []iterm.Profile{*prof}, err
+		}),
+		&vim.Source{},
+		&ssh.Source{},
+		&config.Source{},
+		&ssm.Source{Config: opts.AWSConfig, Cached: opts.CachedInstances},
+		&vault.Source{},
+	)
+
+	var errs []error
+	for _, s := range opts.Extra {
+		errs = append(errs, r.Register(s))
+	}
+
+	return r, stderrors.Join(errs...)
+}
+
+// Generate runs the enabled sources and returns the merged profiles. The
+// profiles are usable even when errors are returned, they contain everything
+// that could be generated.
+func Generate(ctx context.Context, opts Options) (iterm.Profiles, []error) {
+	r, registryErr := Registry(opts)
+
+	sources, err := r.Enabled(opts.Sources)
+	if err != nil {
+		return iterm.Profiles{}, []error{errors.Wrap(err, "invalid source configuration")}
+	}
+
+	gen, results := run(ctx, sources, opts)
+	prof, errs := output(opts, gen, results)
+
+	if registryErr != nil {
+		errs = append([]error{errors.Wrap(registryErr, "invalid extra sources")}, errs...)
+	}
+
+	return prof, errs
+}
+
+// run runs the sources with a generation shared by all their profiles.
+func run(ctx context.Context, sources []source.ProfileSource, opts Options) (*iterm.Generation, []source.Result) {
+	gen := iterm.NewGeneration(opts.NamesPruneAfter)
+
+	return gen, source.Run(iterm.WithGeneration(ctx, gen), sources, opts.Sources)
+}
+
+// output merges the results of gen and writes the profiles, the unique
+// names, the history and the report as configured in opts.
+func output(opts Options, gen *iterm.Generation, results []source.Result) (iterm.Profiles, []error) {
+	errs := source.Errors(results)
+	if err := gen.Err(); err != nil {
+		errs = append(errs, err)
+	}
+
+	// all also holds the parent, it is written like any other source.
+	all := results
+	if opts.Parent {
+		parent, err := newParent(gen)
+		if err != nil {
+			errs = append(errs, errors.Wrap(err, "cannot create the parent profile"))
+		}
+
+		all = append([]source.Result{{Name: parentSource, Profiles: []iterm.Profile{parent}}}, results...)
+	}
+
+	prof, origin := merge(all)
+	gen.AssignGUIDs(prof.Profiles)
+	// the results reused by watch and the cached profiles did not ask gen
+	// for their unique names, they still exist.
+	gen.Generated(prof.Profiles)
+
+	if opts.Parent {
+		for i := range prof.Profiles {
+			if prof.Profiles[i].Name == ParentName {
+				continue
+			}
+
+			if prof.Profiles[i].Extra == nil {
+				prof.Profiles[i].Extra = map[string]interface{}{}
+			}
+
+			prof.Profiles[i].Extra[parentKey] = ParentName
+		}
+	}
+
+	var err error
+
+	if !opts.Scheme.IsZero() {
+		err = applyScheme(opts.Scheme, prof.Profiles)
+		if err != nil {
+			errs = append(errs, errors.Wrap(err, "cannot apply the color scheme"))
+		}
+	}
+
+	err = accent.Apply(opts.Accent, prof.Profiles, ParentName)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	for i := range prof.Profiles {
+		prof.Profiles[i].SetMetadata("source", origin[prof.Profiles[i].Name])
+	}
+
+	classify.Annotate(opts.Classification, prof.Profiles, ParentName)
+
+	err = badge.Apply(opts.Badges, prof.Profiles, ParentName)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	err = classify.Apply(opts.Classification, prof.Profiles, ParentName)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	err = applyKeys(opts.Keys, prof.Profiles)
+	if err != nil {
+		errs = append(errs, errors.Wrap(err, "invalid keys"))
+	}
+
+	err = override.Apply(opts.Overrides, prof.Profiles, origin)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	prof.ResolveProfiles()
+
+	if opts.Output != "" {
+		err = Write(opts.Output, prof)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if opts.OutputDir != "" {
+		err = WriteSplit(opts.OutputDir, prof, origin, all)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// the unique names and the GUIDs are only kept for the profiles that
+	// are written, check and diff change nothing on disk
+	if opts.Output != "" || opts.OutputDir != "" {
+		err = gen.Save()
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if opts.History != nil && (opts.Output != "" || opts.OutputDir != "") {
+		err = record(opts)
+		if err != nil {
+			errs = append(errs, errors.Wrap(err, "cannot record generation history"))
+		}
+	}
+
+	if opts.Report != nil {
+		err = source.WriteReport(opts.Report, results, opts.ReportFormat)
+		if err != nil {
+			errs = append(errs, errors.Wrap(err, "cannot write generation report"))
+		}
+	}
+
+	return prof, errs
+}
+
+// applyKeys binds the keys on all the profiles, including the parent.
+// Invalid bindings are reported and skipped.
+func applyKeys(bindings iterm.Bindings, profiles []iterm.Profile) error {
+	maps, err := bindings.Compile()
+
+	for i := range profiles {
+		for code, km := range maps {
+			profiles[i].Bind(code, km)
+		}
+	}
+
+	return err
+}
+
+// applyScheme applies the scheme to all the profiles, including the parent.
+func applyScheme(ref scheme.Ref, profiles []iterm.Profile) error {
+	s, err := ref.Load()
+	if err != nil {
+		return err
+	}
+
+	for i := range profiles {
+		err = s.Apply(&profiles[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Merge combines the profiles of all results, dropping duplicate names and
+// adding the cross profile keyboard maps and smart selection rules.
+func Merge(results []source.Result) iterm.Profiles {
+	prof, _ := merge(results)
+
+	return prof
+}
+
+// merge is Merge that also returns the source of each profile, by name.
+func merge(results []source.Result) (iterm.Profiles, map[string]string) {
+	var prof iterm.Profiles
+	origin := map[string]string{}
+
+	for _, res := range results {
+		if res.Err != nil {
+			log.Warn().Err(res.Err).Str("source", res.Name).Msg("cannot generate profiles")
+		}
+
+		for _, p := range res.Profiles {
+			if _, ok := origin[p.Name]; !ok {
+				origin[p.Name] = res.Name
+			}
+		}
+
+		prof.Profiles = append(prof.Profiles, res.Profiles...)
+	}
+
+	prof.UpdateKeyboardMaps()
+	prof.UpdateAWSSmartSelectionRules()
+
+	var uniqProf iterm.Profiles
+	existingProfiles := map[string]struct{}{}
+	for _, profile := range prof.Profiles {
+		if _, ok := existingProfiles[profile.Name]; ok {
+			log.Warn().Str("name", profile.Name).Msg("duplicate profile")
+			continue
+		}
+
+		existingProfiles[profile.Name] = struct{}{}
+		uniqProf.Profiles = append(uniqProf.Profiles, profile)
+	}
+
+	return uniqProf, origin
+}
+
+// Current returns the profiles already written to the Output file, or to
+// the OutputDir files. A missing file has no profiles.
+func Current(opts Options) ([]iterm.Profile, error) {
+	if opts.OutputDir != "" {
+		return ReadSplit(opts.OutputDir)
+	}
+
+	data, err := ioutil.ReadFile(opts.Output)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read %s", opts.Output)
+	}
+
+	raw, err := decode(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot parse %s", opts.Output)
+	}
+
+	prof, err := inflate(raw)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot parse %s", opts.Output)
+	}
+
+	return prof, nil
+}
+
+// Marshal returns the profiles in the format iTerm2 expects. Profiles with
+// their parent in prof only keep the keys that differ from it.
+func Marshal(prof iterm.Profiles) ([]byte, error) {
+	profiles, err := thin(prof.Profiles, prof.Profiles)
+	if err != nil {
+		return nil, err
+	}
+
+	return marshal(struct {
+		Profiles []interface{} `json:"Profiles"`
+	}{profiles})
+}
+
+func marshal(v interface{}) ([]byte, error) {
+	profJSON, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot indent json")
+	}
+
+	// unescape "&" character.
+	profJSON = []byte(strings.ReplaceAll(string(profJSON), `\u0026`, "&"))
+	// unescape ">" character.
+	profJSON = []byte(strings.ReplaceAll(string(profJSON), `\u003e`, ">"))
+
+	return profJSON, nil
+}
+
+// Write saves the profiles to path.
+func Write(path string, prof iterm.Profiles) error {
+	profJSON, err := Marshal(prof)
+	if err != nil {
+		return err
+	}
+
+	return atomic.WriteFile(path, profJSON, 0o644)
+}
+
+func expand(path string) string {
+	out, err := homedir.Expand(path)
+	if err != nil {
+		log.Error().Err(err).Str("path", path).Msg("cannot expand homedir")
+		return path
+	}
+
+	return out
+}
@@ -0,0 +1,549 @@
+package germ
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/adrg/xdg"
+	"github.com/mhristof/germ/config"
+	"github.com/mhristof/germ/history"
+	"github.com/mhristof/germ/iterm"
+	"github.com/mhristof/germ/names"
+	"github.com/mhristof/germ/override"
+	"github.com/mhristof/germ/source"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	// keep the unique names and guids of the tests out of the user config
+	dir, err := ioutil.TempDir("", "germ")
+	if err != nil {
+		panic(err)
+	}
+
+	os.Setenv("XDG_CONFIG_HOME", dir)
+	xdg.Reload()
+
+	code := m.Run()
+
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func fake(name string, err error, profiles ...string) source.ProfileSource {
+	return source.Func(name, func(ctx context.Context) ([]iterm.Profile, error) {
+		var ret []iterm.Profile
+		for _, p := range profiles {
+			ret = append(ret, iterm.Profile{Name: p, GUID: p})
+		}
+
+		return ret, err
+	})
+}
+
+func TestGenerate(t *testing.T) {
+	cases := []struct {
+		name     string
+		extra    []source.ProfileSource
+		enabled  []string
+		profiles []string
+		errs     []string
+	}{
+		{
+			name:     "single source",
+			extra:    []source.ProfileSource{fake("foo", nil, "a", "b")},
+			enabled:  []string{"foo"},
+			profiles: []string{ParentName, "a", "b"},
+		},
+		{
+			name: "duplicate names are dropped",
+			extra: []source.ProfileSource{
+				fake("foo", nil, "a", "b"),
+				fake("bar", nil, "b", "c"),
+			},
+			enabled:  []string{"foo", "bar"},
+			profiles: []string{ParentName, "a", "b", "c"},
+		},
+		{
+			name: "failing sources keep their profiles",
+			extra: []source.ProfileSource{
+				fake("foo", errors.New("boom"), "a"),
+				fake("bar", nil, "b"),
+			},
+			enabled:  []string{"foo", "bar"},
+			profiles: []string{ParentName, "a", "b"},
+			errs:     []string{"foo: boom"},
+		},
+		{
+			name:    "unknown source",
+			enabled: []string{"foo"},
+			errs:    []string{`invalid source configuration: unknown enabled source "foo"`},
+		},
+	}
+
+	for _, test := range cases {
+		opts := DefaultOptions()
+		opts.Extra = test.extra
+		opts.Sources = source.Settings{Enabled: test.enabled}
+
+		prof, errs := Generate(context.Background(), opts)
+
+		var names []string
+		for _, p := range prof.Profiles {
+			names = append(names, p.Name)
+		}
+
+		var msgs []string
+		for _, err := range errs {
+			msgs = append(msgs, err.Error())
+		}
+
+		assert.Equal(t, test.profiles, names, test.name)
+		assert.Equal(t, test.errs, msgs, test.name)
+	}
+}
+
+func TestGenerateOutput(t *testing.T) {
+	var report bytes.Buffer
+
+	opts := DefaultOptions()
+	opts.Extra = []source.ProfileSource{fake("foo", nil, "a&b")}
+	opts.Sources = source.Settings{Enabled: []string{"foo"}}
+	opts.Output = filepath.Join(t.TempDir(), "profiles.json")
+	opts.Report = &report
+
+	_, errs := Generate(context.Background(), opts)
+	assert.Empty(t, errs)
+	assert.Contains(t, report.String(), "foo")
+
+	data, err := ioutil.ReadFile(opts.Output)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"a&b"`)
+
+	var prof iterm.Profiles
+	assert.NoError(t, json.Unmarshal(data, &prof))
+	assert.Equal(t, "a&b", prof.Profiles[1].Name)
+}
+
+func TestGenerateSplit(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"germ-old.json":     `{"Germ": {"Source": "old"}, "Profiles": []}`,
+		"germ-failed.json":  `{"Germ": {"Source": "failed"}, "Profiles": [{"Name": "previous"}]}`,
+		"germ-foreign.json": `{"Profiles": []}`,
+		"other.json":        `{"Profiles": []}`,
+	}
+
+	for name, content := range files {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+
+	opts := DefaultOptions()
+	opts.Extra = []source.ProfileSource{
+		fake("foo", nil, "a", "b"),
+		fake("failed", errors.New("boom")),
+	}
+	opts.Sources = source.Settings{Enabled: []string{"foo", "failed"}}
+	opts.OutputDir = dir
+
+	_, errs := Generate(context.Background(), opts)
+	assert.Len(t, errs, 1)
+
+	data, err := ioutil.ReadFile(SplitPath(dir, "foo"))
+	assert.NoError(t, err)
+
+	var prof iterm.Profiles
+	assert.NoError(t, json.Unmarshal(data, &prof))
+	assert.Len(t, prof.Profiles, 2)
+
+	data, err = ioutil.ReadFile(SplitPath(dir, "failed"))
+	assert.NoError(t, err)
+	assert.Equal(t, files["germ-failed.json"], string(data))
+
+	assert.NoFileExists(t, SplitPath(dir, "old"))
+	assert.FileExists(t, SplitPath(dir, "foreign"))
+	assert.FileExists(t, filepath.Join(dir, "other.json"))
+}
+
+func TestRollback(t *testing.T) {
+	dir := t.TempDir()
+	store := &history.Store{Dir: filepath.Join(dir, "history")}
+
+	generate := func(enabled []string, profiles ...string) {
+		opts := DefaultOptions()
+		opts.Extra = []source.ProfileSource{
+			fake("foo", nil, profiles...),
+			fake("bar", nil, "bar"),
+		}
+		opts.Sources = source.Settings{Enabled: enabled}
+		opts.OutputDir = dir
+		opts.History = store
+
+		_, errs := Generate(context.Background(), opts)
+		assert.Empty(t, errs)
+	}
+
+	generate([]string{"foo"}, "a")
+	generate([]string{"foo", "bar"}, "b", "c")
+
+	list, err := store.List()
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+	assert.Equal(t, 4, list[0].Profiles())
+	assert.Equal(t, 2, list[1].Profiles())
+
+	gen, err := Rollback(store, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, gen.ID)
+
+	data, err := ioutil.ReadFile(SplitPath(dir, "foo"))
+	assert.NoError(t, err)
+
+	var prof iterm.Profiles
+	assert.NoError(t, json.Unmarshal(data, &prof))
+	assert.Equal(t, "a", prof.Profiles[0].Name)
+	assert.NoFileExists(t, SplitPath(dir, "bar"))
+
+	_, err = Rollback(store, 2)
+	assert.NoError(t, err)
+	assert.FileExists(t, SplitPath(dir, "bar"))
+
+	_, err = Rollback(store, 42)
+	assert.Error(t, err)
+}
+
+type watchable struct {
+	name  string
+	input string
+	runs  *int32
+}
+
+func (w *watchable) Name() string {
+	return w.name
+}
+
+func (w *watchable) Inputs() []string {
+	return []string{w.input}
+}
+
+func (w *watchable) Generate(ctx context.Context) ([]iterm.Profile, error) {
+	atomic.AddInt32(w.runs, 1)
+
+	data, err := ioutil.ReadFile(w.input)
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSpace(string(data))
+
+	return []iterm.Profile{{Name: name, GUID: name}}, nil
+}
+
+func TestWatch(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	dir := t.TempDir()
+	var fooRuns, barRuns int32
+
+	foo := &watchable{name: "foo", input: filepath.Join(dir, "foo"), runs: &fooRuns}
+	bar := &watchable{name: "bar", input: filepath.Join(dir, "bar"), runs: &barRuns}
+
+	assert.NoError(t, ioutil.WriteFile(foo.input, []byte("a"), 0o644))
+	assert.NoError(t, ioutil.WriteFile(bar.input, []byte("b"), 0o644))
+
+	opts := DefaultOptions()
+	opts.Extra = []source.ProfileSource{foo, bar}
+	opts.Sources = source.Settings{Enabled: []string{"foo", "bar"}}
+	opts.Output = filepath.Join(dir, "profiles.json")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+
+	go func() {
+		done <- Watch(ctx, func() (Options, error) { return opts, nil }, 10*time.Millisecond)
+	}()
+
+	names := func() []string {
+		prof, _ := Current(opts)
+
+		var ret []string
+		for _, p := range prof {
+			ret = append(ret, p.Name)
+		}
+
+		return ret
+	}
+
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual([]string{ParentName, "a", "b"}, names())
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// give the watcher time to start before changing the file
+	time.Sleep(100 * time.Millisecond)
+	assert.NoError(t, ioutil.WriteFile(foo.input, []byte("c"), 0o644))
+
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual([]string{ParentName, "c", "b"}, names())
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	assert.NoError(t, <-done)
+
+	assert.GreaterOrEqual(t, atomic.LoadInt32(&fooRuns), int32(2))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&barRuns))
+}
+
+func TestWatchConfig(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Cleanup(viper.Reset)
+
+	writeConfig := func(badge string) {
+		data := fmt.Sprintf("overrides:\n  - match:\n      name: a\n    badge: %s\n", badge)
+		assert.NoError(t, ioutil.WriteFile(config.Path(), []byte(data), 0o644))
+	}
+
+	writeConfig("FOO")
+	t.Cleanup(func() { os.Remove(config.Path()) })
+	config.Load()
+
+	output := filepath.Join(t.TempDir(), "profiles.json")
+	load := func() (Options, error) {
+		overrides, err := config.Overrides()
+		if err != nil {
+			return Options{}, err
+		}
+
+		opts := DefaultOptions()
+		opts.Extra = []source.ProfileSource{fake("foo", nil, "a")}
+		opts.Sources = source.Settings{Enabled: []string{"foo"}}
+		opts.Overrides = overrides
+		opts.Output = output
+
+		return opts, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+
+	go func() {
+		done <- Watch(ctx, load, 10*time.Millisecond)
+	}()
+
+	badge := func() string {
+		prof, _ := Current(Options{Output: output})
+		for _, p := range prof {
+			if p.Name == "a" {
+				return p.BadgeText
+			}
+		}
+
+		return ""
+	}
+
+	assert.Eventually(t, func() bool {
+		return badge() == "FOO"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// give the watcher time to start before changing the file
+	time.Sleep(100 * time.Millisecond)
+	writeConfig("BAR")
+
+	assert.Eventually(t, func() bool {
+		return badge() == "BAR"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestInputsAffected(t *testing.T) {
+	in := inputs{
+		files: map[string][]string{
+			"/home/.aws/config":  {"aws", "ssm"},
+			"/home/.kube/config": {"k8s"},
+		},
+		global: []string{"/home/.germ.ssr.json", "/home/.germ.trigger.*.json"},
+	}
+
+	names, all := in.affected([]string{"/home/.aws/config", "/home/.kube/config"})
+	assert.False(t, all)
+	assert.Equal(t, []string{"aws", "ssm", "k8s"}, names)
+
+	_, all = in.affected([]string{"/home/.kube/config", "/home/.germ.trigger.foo.json"})
+	assert.True(t, all)
+
+	assert.True(t, in.watched("/home/.germ.ssr.json"))
+	assert.False(t, in.watched("/home/.bashrc"))
+	assert.Equal(t, []string{"/home", "/home/.aws", "/home/.kube"}, in.dirs())
+}
+
+func TestGenerateOverrides(t *testing.T) {
+	badge := "FOO"
+
+	opts := DefaultOptions()
+	opts.Extra = []source.ProfileSource{fake("foo", nil, "a"), fake("bar", nil, "b")}
+	opts.Sources = source.Settings{Enabled: []string{"foo", "bar"}}
+	opts.Overrides = []override.Override{
+		{Match: override.Selector{Source: "foo"}, Patch: override.Patch{Badge: &badge}},
+	}
+
+	prof, errs := Generate(context.Background(), opts)
+	assert.Empty(t, errs)
+	assert.Equal(t, "FOO", prof.Profiles[1].BadgeText)
+	assert.Equal(t, "", prof.Profiles[2].BadgeText)
+}
+
+func TestGenerateKeys(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Extra = []source.ProfileSource{fake("foo", nil, "a"), fake("bar", nil, "b")}
+	opts.Sources = source.Settings{Enabled: []string{"foo", "bar"}}
+	opts.Keys = iterm.Bindings{
+		"cmd+w":       "ignore",
+		"cmd+shift+o": map[string]interface{}{"select_profile": "b"},
+		"hyper+a":     "ignore",
+	}
+	opts.Overrides = []override.Override{
+		{Match: override.Selector{Source: "bar"}, Patch: override.Patch{Keys: iterm.Bindings{"cmd+w": "next_pane"}}},
+	}
+
+	prof, errs := Generate(context.Background(), opts)
+	assert.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "hyper")
+
+	a, b := prof.Profiles[1], prof.Profiles[2]
+	assert.Equal(t, int64(13), a.KeyboardMap["0x77-0x100000"].Action)
+	assert.Equal(t, int64(30), b.KeyboardMap["0x77-0x100000"].Action)
+	assert.Equal(t, b.GUID, a.KeyboardMap["0x6f-0x120000"].Text)
+}
+
+func TestGenerateDuplicateSource(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Extra = []source.ProfileSource{fake("foo", nil, "a"), fake("vim", nil, "b")}
+	opts.Sources = source.Settings{Enabled: []string{"foo"}}
+
+	prof, errs := Generate(context.Background(), opts)
+	assert.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), `source "vim" is already registered`)
+	assert.True(t, slices.ContainsFunc(prof.Profiles, func(p iterm.Profile) bool { return p.Name == "a" }))
+}
+
+func TestGenerateNames(t *testing.T) {
+	path, err := names.DefaultPath()
+	assert.NoError(t, err)
+	t.Cleanup(func() { os.Remove(path) })
+
+	old := time.Now().Add(-48 * time.Hour)
+	data, err := json.Marshal(map[string]names.Entry{
+		"cached": {Name: "cached-name", Seen: old},
+		"gone":   {Name: "gone-name", Seen: old},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(path, data, 0o644))
+
+	opts := DefaultOptions()
+	opts.Extra = []source.ProfileSource{fake("foo", nil, "cached")}
+	opts.Sources = source.Settings{Enabled: []string{"foo"}}
+	opts.NamesPruneAfter = 24 * time.Hour
+
+	// without an output, like check and diff, nothing is saved
+	_, errs := Generate(context.Background(), opts)
+	assert.Empty(t, errs)
+
+	store, err := names.Open(path, -1)
+	assert.NoError(t, err)
+
+	_, ok := store.Find("gone")
+	assert.True(t, ok)
+
+	opts.Output = filepath.Join(t.TempDir(), "profiles.json")
+
+	_, errs = Generate(context.Background(), opts)
+	assert.Empty(t, errs)
+
+	store, err = names.Open(path, -1)
+	assert.NoError(t, err)
+
+	entry, ok := store.Find("cached")
+	assert.True(t, ok)
+	assert.Equal(t, "cached-name", entry.Name)
+
+	_, ok = store.Find("gone")
+	assert.False(t, ok)
+}
+
+func TestGenerateParent(t *testing.T) {
+	dir := t.TempDir()
+
+	opts := DefaultOptions()
+	opts.Extra = []source.ProfileSource{
+		source.Func("foo", func(ctx context.Context) ([]iterm.Profile, error) {
+			return []iterm.Profile{{Name: "a", GUID: "a", BadgeText: "a", NormalFont: "HackNFM-Regular 12"}}, nil
+		}),
+	}
+	opts.Sources = source.Settings{Enabled: []string{"foo"}}
+	opts.Output = filepath.Join(dir, "profiles.json")
+
+	_, errs := Generate(context.Background(), opts)
+	assert.Empty(t, errs)
+
+	data, err := ioutil.ReadFile(opts.Output)
+	assert.NoError(t, err)
+
+	var file struct {
+		Profiles []map[string]interface{}
+	}
+	assert.NoError(t, json.Unmarshal(data, &file))
+	assert.Len(t, file.Profiles, 2)
+	assert.Equal(t, ParentName, file.Profiles[0]["Name"])
+	assert.Equal(t, "HackNFM-Regular 12", file.Profiles[0]["Normal Font"])
+	assert.NotContains(t, file.Profiles[0], parentKey)
+
+	child := file.Profiles[1]
+	assert.Equal(t, iterm.NewGUID("name:a"), child["Guid"])
+	assert.Equal(t, "a", child["Badge Text"])
+	assert.Equal(t, ParentName, child[parentKey])
+	assert.NotContains(t, child, "Normal Font")
+
+	current, err := Current(opts)
+	assert.NoError(t, err)
+	assert.Len(t, current, 2)
+	assert.Equal(t, "HackNFM-Regular 12", current[1].NormalFont)
+	assert.Equal(t, "a", current[1].BadgeText)
+
+	opts.Output = ""
+	opts.OutputDir = dir
+
+	_, errs = Generate(context.Background(), opts)
+	assert.Empty(t, errs)
+	assert.FileExists(t, SplitPath(dir, parentSource))
+
+	split, err := ReadSplit(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, current, split)
+
+	opts.Parent = false
+	opts.OutputDir = ""
+	opts.Output = filepath.Join(dir, "flat.json")
+
+	prof, errs := Generate(context.Background(), opts)
+	assert.Empty(t, errs)
+	assert.Len(t, prof.Profiles, 1)
+
+	data, err = ioutil.ReadFile(opts.Output)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"Normal Font": "HackNFM-Regular 12"`)
+	assert.NotContains(t, string(data), parentKey)
+}
@@ -0,0 +1,170 @@
+package germ
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+
+	"github.com/mhristof/germ/iterm"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ParentName is the name of the profile holding the settings shared by
+	// all the generated profiles.
+	ParentName = "germ-parent"
+	// parentSource is the origin of the parent profile. In split mode the
+	// parent file sorts before the files of the sources, iTerm2 needs the
+	// parent loaded before its children.
+	parentSource = "0-parent"
+	// parentKey is the iTerm2 key linking a profile to its parent.
+	parentKey = "Dynamic Profile Parent Name"
+)
+
+// alwaysKept are the keys stored in every child even when they match the
+// parent.
+var alwaysKept = map[string]struct{}{
+	"Name":    {},
+	"Guid":    {},
+	parentKey: {},
+}
+
+// newParent returns the profile with the defaults shared by every profile.
+func newParent(gen *iterm.Generation) (iterm.Profile, error) {
+	prof, err := gen.NewParentProfile(ParentName)
+
+	return *prof, err
+}
+
+// toMap returns the profile as iTerm2 sees it.
+func toMap(p interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot marshal profile")
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var ret map[string]interface{}
+
+	err = dec.Decode(&ret)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot unmarshal profile")
+	}
+
+	return ret, nil
+}
+
+// thin returns the profiles ready to be written. Profiles with a parent in
+// all only keep the keys that differ from it.
+func thin(profiles []iterm.Profile, all []iterm.Profile) ([]interface{}, error) {
+	parents := map[string]map[string]interface{}{}
+
+	for _, p := range all {
+		if _, ok := p.Extra[parentKey]; ok {
+			continue
+		}
+
+		m, err := toMap(p)
+		if err != nil {
+			return nil, err
+		}
+
+		parents[p.Name] = m
+	}
+
+	ret := make([]interface{}, len(profiles))
+
+	for i, p := range profiles {
+		ret[i] = p
+
+		name, ok := p.StringValue(parentKey)
+		if !ok {
+			continue
+		}
+
+		parent, ok := parents[name]
+		if !ok {
+			continue
+		}
+
+		child, err := toMap(p)
+		if err != nil {
+			return nil, err
+		}
+
+		for k, v := range child {
+			if _, ok := alwaysKept[k]; ok {
+				continue
+			}
+
+			if reflect.DeepEqual(v, parent[k]) {
+				delete(child, k)
+			}
+		}
+
+		ret[i] = child
+	}
+
+	return ret, nil
+}
+
+// decode returns the raw profiles of an iTerm2 dynamic profiles file.
+func decode(data []byte) ([]map[string]interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var file struct {
+		Profiles []map[string]interface{} `json:"Profiles"`
+	}
+
+	err := dec.Decode(&file)
+
+	return file.Profiles, err
+}
+
+// inflate parses profiles written by thin, filling the keys children
+// inherit from their parent.
+func inflate(raw []map[string]interface{}) ([]iterm.Profile, error) {
+	parents := map[string]map[string]interface{}{}
+
+	for _, m := range raw {
+		if _, ok := m[parentKey]; ok {
+			continue
+		}
+
+		if name, ok := m["Name"].(string); ok {
+			parents[name] = m
+		}
+	}
+
+	ret := make([]iterm.Profile, len(raw))
+
+	for i, m := range raw {
+		if name, ok := m[parentKey].(string); ok {
+			full := map[string]interface{}{}
+			for k, v := range parents[name] {
+				full[k] = v
+			}
+
+			for k, v := range m {
+				full[k] = v
+			}
+
+			m = full
+		}
+
+		data, err := json.Marshal(m)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot marshal profile")
+		}
+
+		err = json.Unmarshal(data, &ret[i])
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot unmarshal profile")
+		}
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,167 @@
+package germ
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mhristof/germ/atomic"
+	"github.com/mhristof/germ/iterm"
+	"github.com/mhristof/germ/source"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// splitPrefix is the file name prefix of the per source files.
+const splitPrefix = "germ-"
+
+// splitFile is the content of a per source file. The Germ key marks the file
+// as owned by germ, iTerm2 ignores it.
+type splitFile struct {
+	Germ     splitHeader   `json:"Germ"`
+	Profiles []interface{} `json:"Profiles"`
+}
+
+type splitHeader struct {
+	Source string `json:"Source"`
+}
+
+// SplitPath returns the file used for the profiles of the named source.
+func SplitPath(dir, name string) string {
+	return filepath.Join(dir, fmt.Sprintf("%s%s.json", splitPrefix, name))
+}
+
+// WriteSplit writes the profiles of each source into its own file in dir.
+// origin maps profile names to their source. Sources that failed without
+// any profiles keep their previous file, and files owned by germ for sources
+// that did not run are removed. Profiles with their parent in prof only keep
+// the keys that differ from it.
+func WriteSplit(dir string, prof iterm.Profiles, origin map[string]string, results []source.Result) error {
+	bySource := map[string][]iterm.Profile{}
+	for _, p := range prof.Profiles {
+		bySource[origin[p.Name]] = append(bySource[origin[p.Name]], p)
+	}
+
+	var errs []error
+	active := map[string]struct{}{}
+
+	for _, res := range results {
+		active[res.Name] = struct{}{}
+
+		if res.Err != nil && len(bySource[res.Name]) == 0 {
+			log.Warn().Str("source", res.Name).Msg("keeping previous profiles of failed source")
+			continue
+		}
+
+		profiles, err := thin(bySource[res.Name], prof.Profiles)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		data, err := marshal(splitFile{
+			Germ:     splitHeader{Source: res.Name},
+			Profiles: profiles,
+		})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		path := SplitPath(dir, res.Name)
+
+		err = atomic.WriteFile(path, data, 0o644)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	owned, err := ownedFiles(dir)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	for name, path := range owned {
+		if _, ok := active[name]; ok {
+			continue
+		}
+
+		log.Info().Str("path", path).Msg("removing profiles of disabled source")
+
+		err = os.Remove(path)
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "cannot remove %s", path))
+		}
+	}
+
+	return stderrors.Join(errs...)
+}
+
+// ownedFiles returns the files in dir written by WriteSplit, by source name.
+// A file is owned only if both its name and its header match.
+func ownedFiles(dir string) (map[string]string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, splitPrefix+"*.json"))
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot list %s", dir)
+	}
+
+	ret := map[string]string{}
+
+	for _, path := range matches {
+		name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), splitPrefix), ".json")
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			log.Warn().Err(err).Str("path", path).Msg("cannot read file")
+			continue
+		}
+
+		var file splitFile
+		if json.Unmarshal(data, &file) != nil || file.Germ.Source != name {
+			log.Debug().Str("path", path).Msg("file not owned by germ")
+			continue
+		}
+
+		ret[name] = path
+	}
+
+	return ret, nil
+}
+
+// ReadSplit returns the profiles of all the files in dir owned by germ.
+func ReadSplit(dir string) ([]iterm.Profile, error) {
+	owned, err := ownedFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for name := range owned {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var raw []map[string]interface{}
+
+	for _, name := range names {
+		data, err := ioutil.ReadFile(owned[name])
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot read %s", owned[name])
+		}
+
+		profiles, err := decode(data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot parse %s", owned[name])
+		}
+
+		raw = append(raw, profiles...)
+	}
+
+	return inflate(raw)
+}
@@ -0,0 +1,339 @@
+package germ
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/mhristof/germ/config"
+	"github.com/mhristof/germ/diff"
+	"github.com/mhristof/germ/iterm"
+	"github.com/mhristof/germ/source"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultDebounce is how long Watch waits for more changes before it
+// regenerates the profiles.
+const DefaultDebounce = 2 * time.Second
+
+// inputs maps the watched files to the sources that read them. Files shared
+// by all the profiles, like germ.yaml or the user smart selection rules, are
+// matched by the global patterns and regenerate every source.
+type inputs struct {
+	files  map[string][]string
+	global []string
+}
+
+func newInputs(sources []source.ProfileSource) inputs {
+	ret := inputs{
+		files: map[string][]string{},
+		global: []string{
+			config.Path(),
+			expand(iterm.UserSSR),
+			expand(fmt.Sprintf(iterm.UserTriggers, "*")),
+		},
+	}
+
+	for _, s := range sources {
+		w, ok := s.(source.Watchable)
+		if !ok {
+			continue
+		}
+
+		for _, path := range w.Inputs() {
+			path = filepath.Clean(path)
+			ret.files[path] = append(ret.files[path], s.Name())
+		}
+	}
+
+	return ret
+}
+
+// dirs returns the directories to watch. Directories are watched instead of
+// the files so that editors replacing a file are noticed.
+func (in inputs) dirs() []string {
+	set := map[string]struct{}{}
+
+	for path := range in.files {
+		set[filepath.Dir(path)] = struct{}{}
+	}
+
+	for _, pattern := range in.global {
+		set[filepath.Dir(pattern)] = struct{}{}
+	}
+
+	var ret []string
+	for dir := range set {
+		ret = append(ret, dir)
+	}
+
+	sort.Strings(ret)
+
+	return ret
+}
+
+func (in inputs) isGlobal(path string) bool {
+	for _, pattern := range in.global {
+		if ok, _ := filepath.Match(pattern, path); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (in inputs) watched(path string) bool {
+	_, ok := in.files[path]
+
+	return ok || in.isGlobal(path)
+}
+
+// affected returns the names of the sources that need to run again because
+// of the changed files, or all when every source does.
+func (in inputs) affected(changed []string) (names []string, all bool) {
+	set := map[string]struct{}{}
+
+	for _, path := range changed {
+		if in.isGlobal(path) {
+			return nil, true
+		}
+
+		for _, name := range in.files[path] {
+			if _, ok := set[name]; ok {
+				continue
+			}
+
+			set[name] = struct{}{}
+			names = append(names, name)
+		}
+	}
+
+	return names, false
+}
+
+// Watch generates the profiles with the options returned by load and then
+// regenerates them whenever the files read by the sources change, until ctx
+// is done. Only the affected sources run again, the rest reuse their
+// previous results. A change of germ.yaml loads it and the options again, so
+// the enabled sources and the inputs they watch follow the configuration.
+func Watch(ctx context.Context, load func() (Options, error), debounce time.Duration) error {
+	opts, err := load()
+	if err != nil {
+		return errors.Wrap(err, "invalid configuration")
+	}
+
+	sources, err := enabled(opts)
+	if err != nil {
+		return errors.Wrap(err, "invalid source configuration")
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.Wrap(err, "cannot create file watcher")
+	}
+	defer watcher.Close()
+
+	w := &watch{
+		load:    load,
+		opts:    opts,
+		sources: sources,
+		in:      newInputs(sources),
+		watcher: watcher,
+		dirs:    map[string]struct{}{},
+	}
+
+	gen, results := run(ctx, sources, opts)
+	prof, errs := output(opts, gen, cloneResults(results))
+	logErrors(errs)
+
+	w.results, w.prof = results, prof
+	w.watchDirs()
+
+	changed := map[string]struct{}{}
+	var fire <-chan time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-watcher.Errors:
+			log.Error().Err(err).Msg("file watcher error")
+		case event := <-watcher.Events:
+			path := filepath.Clean(event.Name)
+			if !w.in.watched(path) {
+				continue
+			}
+
+			log.Debug().Str("path", path).Str("op", event.Op.String()).Msg("file changed")
+
+			changed[path] = struct{}{}
+			fire = time.After(debounce)
+		case <-fire:
+			fire = nil
+
+			var files []string
+			for path := range changed {
+				files = append(files, path)
+			}
+
+			sort.Strings(files)
+			changed = map[string]struct{}{}
+
+			w.regenerate(ctx, files)
+		}
+	}
+}
+
+// watch is the state of Watch between two regenerations.
+type watch struct {
+	load    func() (Options, error)
+	opts    Options
+	sources []source.ProfileSource
+	results []source.Result
+	in      inputs
+	prof    iterm.Profiles
+	watcher *fsnotify.Watcher
+	// dirs are the directories already watched.
+	dirs map[string]struct{}
+}
+
+// watchDirs watches the directories of the inputs that are not watched yet.
+func (w *watch) watchDirs() {
+	for _, dir := range w.in.dirs() {
+		if _, ok := w.dirs[dir]; ok {
+			continue
+		}
+
+		err := w.watcher.Add(dir)
+		if err != nil {
+			log.Warn().Err(err).Str("dir", dir).Msg("cannot watch directory")
+			continue
+		}
+
+		w.dirs[dir] = struct{}{}
+		log.Debug().Str("dir", dir).Msg("watching")
+	}
+}
+
+// reload loads germ.yaml and the options again, keeping the previous ones
+// if they are invalid.
+func (w *watch) reload() {
+	config.Load()
+
+	opts, err := w.load()
+	if err != nil {
+		log.Error().Err(err).Msg("invalid configuration, keeping the previous one")
+		return
+	}
+
+	sources, err := enabled(opts)
+	if err != nil {
+		log.Error().Err(err).Msg("invalid source configuration, keeping the previous one")
+		return
+	}
+
+	w.opts, w.sources = opts, sources
+	w.in = newInputs(sources)
+	w.watchDirs()
+}
+
+// regenerate runs the sources affected by the changed files and writes the
+// profiles, logging what changed.
+func (w *watch) regenerate(ctx context.Context, files []string) {
+	names, all := w.in.affected(files)
+	if all {
+		w.reload()
+	}
+
+	var rerun []source.ProfileSource
+	for _, s := range w.sources {
+		if all || slices.Contains(names, s.Name()) {
+			rerun = append(rerun, s)
+		}
+	}
+
+	log.Info().Strs("files", files).Strs("sources", sourceNames(rerun)).Msg("regenerating profiles")
+
+	gen, rerunResults := run(ctx, rerun, w.opts)
+
+	if all {
+		// the enabled sources may have changed
+		w.results = rerunResults
+	} else {
+		updated := map[string]source.Result{}
+		for _, res := range rerunResults {
+			updated[res.Name] = res
+		}
+
+		for i, res := range w.results {
+			if u, ok := updated[res.Name]; ok {
+				w.results[i] = u
+			}
+		}
+	}
+
+	// output changes the profiles, the cached results must stay as the
+	// sources generated them
+	prof, errs := output(w.opts, gen, cloneResults(w.results))
+	logErrors(errs)
+
+	res, err := diff.Profiles(w.prof.Profiles, prof.Profiles, diff.DefaultIgnore)
+	w.prof = prof
+
+	if err != nil {
+		log.Error().Err(err).Msg("cannot compare profiles")
+		return
+	}
+
+	log.Info().
+		Strs("added", res.Added).
+		Strs("removed", res.Removed).
+		Int("renamed", len(res.Renamed)).
+		Int("changed", len(res.Changed)).
+		Msg("profiles regenerated")
+}
+
+// enabled returns the enabled sources of opts.
+func enabled(opts Options) ([]source.ProfileSource, error) {
+	r, err := Registry(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.Enabled(opts.Sources)
+}
+
+// cloneResults returns a deep copy of the profiles of the results.
+func cloneResults(results []source.Result) []source.Result {
+	ret := make([]source.Result, len(results))
+	for i, res := range results {
+		ret[i] = res
+		ret[i].Profiles = make([]iterm.Profile, len(res.Profiles))
+
+		for j, p := range res.Profiles {
+			ret[i].Profiles[j] = p.Copy()
+		}
+	}
+
+	return ret
+}
+
+func logErrors(errs []error) {
+	for _, err := range errs {
+		log.Error().Err(err).Msg("cannot generate profiles")
+	}
+}
+
+func sourceNames(sources []source.ProfileSource) []string {
+	var ret []string
+	for _, s := range sources {
+		ret = append(ret, s.Name())
+	}
+
+	return ret
+}
@@ -0,0 +1,198 @@
+// Package history keeps copies of the last generated profile files so they
+// can be restored later.
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/adrg/xdg"
+	"github.com/mhristof/germ/atomic"
+	"github.com/pkg/errors"
+)
+
+// DefaultKeep is the number of generations kept when not configured.
+const DefaultKeep = 10
+
+const metaName = "meta.json"
+
+// File is a single file written by a generation.
+type File struct {
+	Path     string `json:"path"`
+	Profiles int    `json:"profiles"`
+	Data     []byte `json:"-"`
+}
+
+// Generation is a set of files written together.
+type Generation struct {
+	ID    int       `json:"id"`
+	Time  time.Time `json:"time"`
+	Files []File    `json:"files"`
+}
+
+// Profiles returns the number of profiles in all the files.
+func (g Generation) Profiles() int {
+	ret := 0
+	for _, f := range g.Files {
+		ret += f.Profiles
+	}
+
+	return ret
+}
+
+// Store keeps the generations in a directory, one sub directory each.
+type Store struct {
+	Dir  string
+	Keep int
+}
+
+// New returns the store under the XDG state directory.
+func New(keep int) *Store {
+	return &Store{
+		Dir:  filepath.Join(xdg.StateHome, "germ", "history"),
+		Keep: keep,
+	}
+}
+
+// Save records a new generation and removes the oldest ones above Keep.
+func (s *Store) Save(files []File) (Generation, error) {
+	list, err := s.List()
+	if err != nil {
+		return Generation{}, err
+	}
+
+	gen := Generation{ID: 1, Time: time.Now()}
+	if len(list) > 0 {
+		gen.ID = list[0].ID + 1
+	}
+
+	dir := s.path(gen.ID)
+
+	err = os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return Generation{}, errors.Wrapf(err, "cannot create %s", dir)
+	}
+
+	for i, f := range files {
+		err = atomic.WriteFile(filepath.Join(dir, dataName(i)), f.Data, 0o644)
+		if err != nil {
+			return Generation{}, err
+		}
+
+		gen.Files = append(gen.Files, File{Path: f.Path, Profiles: f.Profiles})
+	}
+
+	meta, err := json.MarshalIndent(gen, "", "    ")
+	if err != nil {
+		return Generation{}, errors.Wrap(err, "cannot marshal generation")
+	}
+
+	// meta.json is written last, a generation without it is ignored.
+	err = atomic.WriteFile(filepath.Join(dir, metaName), meta, 0o644)
+	if err != nil {
+		return Generation{}, err
+	}
+
+	return gen, s.prune(append([]Generation{gen}, list...))
+}
+
+// List returns the generations, newest first.
+func (s *Store) List() ([]Generation, error) {
+	entries, err := ioutil.ReadDir(s.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot list %s", s.Dir)
+	}
+
+	var ret []Generation
+
+	for _, e := range entries {
+		id, err := strconv.Atoi(e.Name())
+		if err != nil || !e.IsDir() {
+			continue
+		}
+
+		gen, err := s.meta(id)
+		if err != nil {
+			continue
+		}
+
+		ret = append(ret, gen)
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].ID > ret[j].ID
+	})
+
+	return ret, nil
+}
+
+// Load returns the generation with the given id, including the file contents.
+func (s *Store) Load(id int) (Generation, error) {
+	gen, err := s.meta(id)
+	if err != nil {
+		return Generation{}, err
+	}
+
+	for i := range gen.Files {
+		path := filepath.Join(s.path(id), dataName(i))
+
+		gen.Files[i].Data, err = ioutil.ReadFile(path)
+		if err != nil {
+			return Generation{}, errors.Wrapf(err, "cannot read %s", path)
+		}
+	}
+
+	return gen, nil
+}
+
+func (s *Store) meta(id int) (Generation, error) {
+	path := filepath.Join(s.path(id), metaName)
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Generation{}, errors.Wrapf(err, "cannot find generation %d", id)
+	}
+
+	var gen Generation
+
+	err = json.Unmarshal(data, &gen)
+	if err != nil {
+		return Generation{}, errors.Wrapf(err, "cannot parse %s", path)
+	}
+
+	return gen, nil
+}
+
+func (s *Store) prune(list []Generation) error {
+	keep := s.Keep
+	if keep <= 0 {
+		keep = DefaultKeep
+	}
+
+	for i := keep; i < len(list); i++ {
+		err := os.RemoveAll(s.path(list[i].ID))
+		if err != nil {
+			return errors.Wrapf(err, "cannot remove generation %d", list[i].ID)
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) path(id int) string {
+	return filepath.Join(s.Dir, strconv.Itoa(id))
+}
+
+func dataName(i int) string {
+	return fmt.Sprintf("%d.json", i)
+}
@@ -0,0 +1,46 @@
+package history
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSave(t *testing.T) {
+	s := &Store{Dir: t.TempDir(), Keep: 2}
+
+	for i := 1; i <= 3; i++ {
+		gen, err := s.Save([]File{
+			{Path: "/a.json", Profiles: i, Data: []byte(fmt.Sprintf("a%d", i))},
+			{Path: "/b.json", Profiles: 1, Data: []byte(fmt.Sprintf("b%d", i))},
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, i, gen.ID)
+	}
+
+	list, err := s.List()
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+	assert.Equal(t, 3, list[0].ID)
+	assert.Equal(t, 2, list[1].ID)
+	assert.Equal(t, 3, list[1].Profiles())
+
+	gen, err := s.Load(2)
+	assert.NoError(t, err)
+	assert.Equal(t, "/a.json", gen.Files[0].Path)
+	assert.Equal(t, "a2", string(gen.Files[0].Data))
+	assert.Equal(t, "b2", string(gen.Files[1].Data))
+
+	_, err = s.Load(1)
+	assert.Error(t, err)
+}
+
+func TestListEmpty(t *testing.T) {
+	s := &Store{Dir: t.TempDir() + "/missing"}
+
+	list, err := s.List()
+	assert.NoError(t, err)
+	assert.Empty(t, list)
+}
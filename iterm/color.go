@@ -0,0 +1,126 @@
+package iterm
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ParseColor parses a #rrggbb hex color.
+func ParseColor(hex string) (Color, error) {
+	value := strings.TrimPrefix(hex, "#")
+	if len(value) != 6 {
+		return Color{}, fmt.Errorf("invalid color %q, expected #rrggbb", hex)
+	}
+
+	rgb, err := strconv.ParseUint(value, 16, 32)
+	if err != nil {
+		return Color{}, fmt.Errorf("invalid color %q, expected #rrggbb", hex)
+	}
+
+	return Color{
+		ColorSpace:     "sRGB",
+		RedComponent:   float64(rgb>>16&0xff) / 255,
+		GreenComponent: float64(rgb>>8&0xff) / 255,
+		BlueComponent:  float64(rgb&0xff) / 255,
+		AlphaComponent: 1,
+	}, nil
+}
+
+// The iTerm2 color keys have a light and a dark variant, with these
+// suffixes, used instead of the key when SeparateColors is set.
+const (
+	LightSuffix    = " (Light)"
+	DarkSuffix     = " (Dark)"
+	SeparateColors = "Use Separate Colors for Light and Dark Mode"
+)
+
+// colorNames maps the short color names of germ.yaml to their iTerm2 key.
+var colorNames = func() map[string]string {
+	ret := map[string]string{
+		"background":  "Background Color",
+		"foreground":  "Foreground Color",
+		"cursor":      "Cursor Color",
+		"cursor_text": "Cursor Text Color",
+	}
+
+	for i := 0; i < 16; i++ {
+		ret[fmt.Sprintf("ansi%d", i)] = fmt.Sprintf("Ansi %d Color", i)
+	}
+
+	return ret
+}()
+
+// isColor returns true if the value of the iTerm2 key is a color.
+func isColor(key string) bool {
+	if i := fieldIndex(key); i >= 0 {
+		typ := reflect.TypeOf(Profile{}).Field(i).Type
+		return typ == reflect.TypeOf(Color{}) || typ == reflect.TypeOf(&Color{})
+	}
+
+	return known[key] == reflect.TypeOf(Color{})
+}
+
+// the light and dark variants of every color key are known keys.
+func init() {
+	var keys []string
+	for name := range fields {
+		keys = append(keys, name)
+	}
+
+	for name := range known {
+		keys = append(keys, name)
+	}
+
+	for _, key := range keys {
+		if isColor(key) {
+			known[key+LightSuffix] = reflect.TypeOf(Color{})
+			known[key+DarkSuffix] = reflect.TypeOf(Color{})
+		}
+	}
+}
+
+// ColorKey returns the iTerm2 key of a color given by its short name, such
+// as background or ansi0, or by any iTerm2 color key.
+func ColorKey(name string) (string, error) {
+	if key, ok := colorNames[strings.ToLower(name)]; ok {
+		return key, nil
+	}
+
+	key, ok := Key(name)
+	if ok && isColor(key) {
+		return key, nil
+	}
+
+	return "", fmt.Errorf("unknown color %q", name)
+}
+
+// SetColor sets one of the profile colors by name: background, foreground,
+// cursor, cursor_text or ansi0 to ansi15.
+func (p *Profile) SetColor(name string, c Color) error {
+	key, ok := colorNames[strings.ToLower(name)]
+	if !ok {
+		return fmt.Errorf("unknown color %q", name)
+	}
+
+	return p.SetColorKey(key, c)
+}
+
+// SetColorKey sets the color of an iTerm2 key, such as "Ansi 0 Color", and
+// of its light and dark variants if the profile uses separate colors.
+func (p *Profile) SetColorKey(key string, c Color) error {
+	keys := []string{key}
+	if separate, _ := p.Extra[SeparateColors].(bool); separate {
+		keys = append(keys, key+LightSuffix, key+DarkSuffix)
+	}
+
+	for _, k := range keys {
+		err := p.Set(k, c)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
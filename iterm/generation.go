@@ -0,0 +1,250 @@
+package iterm
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// Generation holds what every profile needs and is the same for all of
+// them: the semantic history command, the smart selection rules, the
+// triggers and the unique names. It is loaded once and shared by all the
+// profiles of a generation instead of being loaded for every profile. It is
+// safe for concurrent use.
+//
+// A nil *Generation is valid, the profiles then share one generation
+// loaded the first time it is needed and saved after every profile.
+type Generation struct {
+	semanticHistory map[string]string
+	ssr             []SmartSelectionRule
+	triggers        []Trigger
+	custom          map[string][]Trigger
+	customErrs      map[string]error
+	names           *uniqueNames
+	guids           *guids
+	// err is what could not be loaded, see Err.
+	err error
+}
+
+type generationKey struct{}
+
+// shared is the generation of the profiles created without one.
+var shared = struct {
+	sync.Once
+	gen *Generation
+}{}
+
+// sharedGeneration returns the generation of the profiles created without
+// one. What it cannot load is logged once instead of being reported for
+// every profile.
+func sharedGeneration() *Generation {
+	shared.Do(func() {
+		shared.gen = NewGeneration(0)
+		if err := shared.gen.Err(); err != nil {
+			log.Warn().Err(err).Msg("some profile settings are disabled")
+		}
+	})
+
+	return shared.gen
+}
+
+// NewGeneration loads everything the profiles need. The unique names of
+// the profiles missing from the generation are pruned after namesPruneAfter,
+// see names.Store.PruneAfter. Call Save once all the profiles are created.
+func NewGeneration(namesPruneAfter time.Duration) *Generation {
+	var errs []error
+
+	g := &Generation{
+		semanticHistory: map[string]string{},
+		names:           loadNames(namesPruneAfter),
+		guids:           loadGUIDs(),
+	}
+
+	python3, err := exec.LookPath("python3")
+	if err != nil {
+		errs = append(errs, errors.Wrap(err, "cannot find python3, semantic history is disabled"))
+	} else {
+		g.semanticHistory = map[string]string{
+			"text":   fmt.Sprintf(`%s $HOME/bin/nvim-edit.py \1 \2`, python3),
+			"action": "command",
+		}
+	}
+
+	g.ssr, err = SmartSelectionRules(UserSSR)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	g.triggers, err = defaultTriggers()
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	g.custom, g.customErrs = allProfileTriggers()
+	g.err = stderrors.Join(errs...)
+
+	return g
+}
+
+// Err returns what NewGeneration could not load, like python3 or the smart
+// selection rules. The profiles are created without it, it is reported once
+// for the whole generation.
+func (g *Generation) Err() error {
+	if g == nil {
+		return nil
+	}
+
+	return g.err
+}
+
+// WithGeneration returns a copy of ctx carrying g, sources use it to create
+// their profiles.
+func WithGeneration(ctx context.Context, g *Generation) context.Context {
+	return context.WithValue(ctx, generationKey{}, g)
+}
+
+// GenerationFrom returns the generation of ctx, or nil if there is none.
+func GenerationFrom(ctx context.Context) *Generation {
+	g, _ := ctx.Value(generationKey{}).(*Generation)
+
+	return g
+}
+
+// Generated records the profiles of the whole generation, including the
+// ones that were not created by g, like cached profiles. Save then prunes
+// the unique names of the profiles that are no longer generated.
+func (g *Generation) Generated(profiles []Profile) {
+	if g == nil {
+		return
+	}
+
+	var ret []string
+	for _, p := range profiles {
+		ret = append(ret, p.Name)
+	}
+
+	g.names.generated(ret)
+}
+
+// Save writes the unique names picked during the generation.
+func (g *Generation) Save() error {
+	if g == nil {
+		return nil
+	}
+
+	return stderrors.Join(
+		errors.Wrap(g.names.save(), "cannot save the unique profile names"),
+		errors.Wrap(g.guids.save(), "cannot save the profile guids"),
+	)
+}
+
+// NewProfile creates a profile with the default settings and applies the
+// given config on top. The returned profile is always usable; the error
+// lists the settings that could not be applied to it.
+func NewProfile(name string, config map[string]string) (*Profile, error) {
+	g := sharedGeneration()
+
+	prof, err := g.NewProfile(name, config)
+
+	return prof, stderrors.Join(err, g.Save())
+}
+
+// NewProfile is the package NewProfile using the state loaded by g.
+func (g *Generation) NewProfile(name string, config map[string]string) (*Profile, error) {
+	if g == nil {
+		return NewProfile(name, config)
+	}
+
+	prof, err := g.createBaseProfile(name, config)
+	errs := []error{err}
+
+	errs = append(errs, applyConfigOverrides(&prof, config))
+	prof.Colors()
+
+	err = stderrors.Join(errs...)
+	if err != nil {
+		err = errors.Wrapf(err, "profile %s", name)
+	}
+
+	return &prof, err
+}
+
+// NewParentProfile creates a profile with only the default settings shared
+// by all profiles, to be used as the Dynamic Profile Parent of other
+// profiles. It has no unique name, badge or tags.
+func NewParentProfile(name string) (*Profile, error) {
+	return sharedGeneration().NewParentProfile(name)
+}
+
+// NewParentProfile is the package NewParentProfile using the state loaded by
+// g.
+func (g *Generation) NewParentProfile(name string) (*Profile, error) {
+	if g == nil {
+		return NewParentProfile(name)
+	}
+
+	prof, err := g.defaultProfile(name, map[string]string{})
+	prof.Tags = []string{}
+	prof.Colors()
+
+	if err != nil {
+		err = errors.Wrapf(err, "profile %s", name)
+	}
+
+	return &prof, err
+}
+
+// createBaseProfile creates a profile with default settings
+func (g *Generation) createBaseProfile(name string, config map[string]string) (Profile, error) {
+	prof, err := g.defaultProfile(name, config)
+
+	uname := g.names.unique(name)
+	prof.BadgeText = name + "\n" + uname
+	prof.Tags = append(prof.Tags, uname)
+	prof.SetMetadata("name", name)
+	prof.SetMetadata("unique", uname)
+
+	return prof, err
+}
+
+// defaultProfile creates a profile with the default settings that do not
+// depend on the unique name of the profile.
+func (g *Generation) defaultProfile(name string, config map[string]string) (Profile, error) {
+	semanticHistory := map[string]string{}
+	for k, v := range g.semanticHistory {
+		semanticHistory[k] = v
+	}
+
+	// the profiles must not share the backing arrays, they are appended to
+	ssr := append([]SmartSelectionRule{}, g.ssr...)
+	triggers := append(append([]Trigger{}, g.triggers...), g.custom[name]...)
+
+	prof := Profile{
+		Name:                    name,
+		GUID:                    name,
+		Tags:                    Tags(config),
+		CustomDirectory:         "Recycle",
+		SmartSelectionRules:     ssr,
+		Triggers:                triggers,
+		TitleComponents:         32,
+		CustomWindowTitle:       name,
+		AllowTitleSetting:       false,
+		FlashingBell:            true,
+		SilenceBell:             true,
+		KeyboardMap:             CreateKeyboardMap(name, config),
+		UnlimitedScrollback:     true,
+		NormalFont:              "HackNFM-Regular 12",
+		Transparency:            0,
+		InitialUseTransparency:  false,
+		SemanticHistory:         semanticHistory,
+		SetLocalEnvironmentVars: 2,
+	}
+
+	return prof, g.customErrs[name]
+}
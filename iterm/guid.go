@@ -0,0 +1,264 @@
+package iterm
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/adrg/xdg"
+	"github.com/mhristof/germ/atomic"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// guidNamespace is the UUID namespace of the GUIDs, the URL namespace of RFC
+// 4122.
+var guidNamespace = [16]byte{
+	0x6b, 0xa7, 0xb8, 0x11, 0x9d, 0xad, 0x11, 0xd1,
+	0x80, 0xb4, 0x00, 0xc0, 0x4f, 0xd4, 0x30, 0xc8,
+}
+
+// NewGUID returns the version 5 UUID of identity.
+func NewGUID(identity string) string {
+	h := sha1.New()
+	h.Write(guidNamespace[:])
+	h.Write([]byte("https://github.com/mhristof/germ/" + identity))
+
+	var u [16]byte
+	copy(u[:], h.Sum(nil))
+
+	u[6] = (u[6] & 0x0f) | 0x50
+	u[8] = (u[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%X-%X-%X-%X-%X", u[0:4], u[4:6], u[6:8], u[8:10], u[10:])
+}
+
+// guids maps the identity of every profile to its GUID, persisted in
+// germ-guids.json. Identities seen for the first time get the GUID of their
+// previous name if the profile was generated before GUIDs were introduced,
+// so existing window arrangements and hotkeys keep working.
+type guids struct {
+	sync.Mutex
+	storage    string
+	byIdentity map[string]string
+	// changed are the keys set by this process, save applies them to the
+	// content of the file.
+	changed map[string]struct{}
+}
+
+// loadGUIDs reads the GUID storage.
+func loadGUIDs() *guids {
+	ret := &guids{byIdentity: map[string]string{}, changed: map[string]struct{}{}}
+
+	storage, err := xdg.ConfigFile("germ-guids.json")
+	if err != nil {
+		log.Error().Err(err).Msg("cannot find the guid storage")
+		return ret
+	}
+
+	byIdentity, err := readGUIDs(storage)
+	if err != nil {
+		// keep the broken file as it is, the GUIDs are still deterministic
+		log.Warn().Err(err).Str("storage", storage).Msg("cannot read the guid storage")
+		return ret
+	}
+
+	ret.storage = storage
+	ret.byIdentity = byIdentity
+
+	return ret
+}
+
+// readGUIDs parses the GUID storage, a missing file is empty.
+func readGUIDs(storage string) (map[string]string, error) {
+	ret := map[string]string{}
+
+	contents, err := os.ReadFile(storage)
+	if os.IsNotExist(err) {
+		return ret, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read %s", storage)
+	}
+
+	err = json.Unmarshal(contents, &ret)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot parse %s", storage)
+	}
+
+	return ret, nil
+}
+
+// get returns the GUID of identity, using legacy for identities seen for the
+// first time.
+func (g *guids) get(identity, legacy string) string {
+	g.Lock()
+	defer g.Unlock()
+
+	if guid, ok := g.byIdentity[identity]; ok {
+		return guid
+	}
+
+	guid := legacy
+	if guid == "" {
+		guid = NewGUID(identity)
+	}
+
+	g.byIdentity[identity] = guid
+	g.changed[identity] = struct{}{}
+
+	return guid
+}
+
+// lookup returns the GUID of the key, if any.
+func (g *guids) lookup(key string) (string, bool) {
+	g.Lock()
+	defer g.Unlock()
+
+	guid, ok := g.byIdentity[key]
+
+	return guid, ok
+}
+
+// set stores the GUID of the key.
+func (g *guids) set(key, guid string) {
+	g.Lock()
+	defer g.Unlock()
+
+	if g.byIdentity[key] == guid {
+		return
+	}
+
+	g.byIdentity[key] = guid
+	g.changed[key] = struct{}{}
+}
+
+// save writes the GUIDs set by this process. The storage is locked and read
+// again, like the unique names, so the GUIDs of other processes are kept.
+func (g *guids) save() error {
+	g.Lock()
+	defer g.Unlock()
+
+	if len(g.changed) == 0 || g.storage == "" {
+		return nil
+	}
+
+	unlock, err := atomic.Lock(g.storage)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	current, err := readGUIDs(g.storage)
+	if err != nil {
+		return err
+	}
+
+	for key := range g.changed {
+		current[key] = g.byIdentity[key]
+	}
+
+	data, err := json.MarshalIndent(current, "", "    ")
+	if err != nil {
+		return errors.Wrapf(err, "cannot marshal %s", g.storage)
+	}
+
+	err = atomic.WriteFile(g.storage, data, 0o644)
+	if err != nil {
+		return err
+	}
+
+	g.byIdentity = current
+	g.changed = map[string]struct{}{}
+
+	return nil
+}
+
+// identity returns the identity of p.
+func identity(p Profile) string {
+	if p.Identity != "" {
+		return p.Identity
+	}
+
+	return "name:" + p.Name
+}
+
+// AssignGUIDs sets the GUID of all the profiles from their identity.
+// Profiles sharing an identity, for example two AWS profiles for the same
+// account and role, are told apart by their name, whatever their order.
+func (g *Generation) AssignGUIDs(profiles []Profile) {
+	if g == nil {
+		return
+	}
+
+	var ids []string
+	byIdentity := map[string][]int{}
+
+	for i := range profiles {
+		id := identity(profiles[i])
+		if _, ok := byIdentity[id]; !ok {
+			ids = append(ids, id)
+		}
+
+		byIdentity[id] = append(byIdentity[id], i)
+	}
+
+	for _, id := range ids {
+		g.assignGUIDs(id, profiles, byIdentity[id])
+	}
+}
+
+// assignGUIDs sets the GUIDs of the profiles sharing the identity. Every
+// GUID is also stored under the identity and the profile name, so a profile
+// keeps its GUID when the other profiles of the identity come and go. A
+// profile without one, like a renamed profile, takes the GUID of the
+// identity unless another profile of the identity has it.
+func (g *Generation) assignGUIDs(id string, profiles []Profile, indexes []int) {
+	sort.Slice(indexes, func(a, b int) bool {
+		return profiles[indexes[a]].Name < profiles[indexes[b]].Name
+	})
+
+	taken := map[string]struct{}{}
+	for _, i := range indexes {
+		if guid, ok := g.guids.lookup(named(id, profiles[i].Name)); ok {
+			taken[guid] = struct{}{}
+		}
+	}
+
+	for _, i := range indexes {
+		key := named(id, profiles[i].Name)
+
+		legacy := ""
+		if g.names.known(profiles[i].Name) {
+			legacy = profiles[i].Name
+		}
+
+		guid, ok := g.guids.lookup(key)
+		if !ok {
+			shared, ok := g.guids.lookup(id)
+			if _, used := taken[shared]; !ok || !used {
+				guid = g.guids.get(id, legacy)
+			} else {
+				guid = g.guids.get(key, legacy)
+			}
+		}
+
+		profiles[i].GUID = guid
+		taken[guid] = struct{}{}
+		g.guids.set(key, guid)
+
+		if len(indexes) == 1 {
+			g.guids.set(id, guid)
+		}
+	}
+}
+
+// named returns the key of the GUID of the profile among the profiles
+// sharing its identity.
+func named(id, name string) string {
+	return fmt.Sprintf("%s#%s", id, name)
+}
@@ -0,0 +1,322 @@
+package iterm
+
+import (
+	stderrors "errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// The iTerm2 key binding actions germ uses, see KeyboardActions for the
+// names accepted in germ.yaml.
+const (
+	KeyboardSelectMenuItem           = 25
+	KeyboardSplitHorizontallyProfile = 28
+)
+
+// The modifier flags of the iTerm2 key codes.
+const (
+	ModifierShift    = 0x20000
+	ModifierCtrl     = 0x40000
+	ModifierAlt      = 0x80000
+	ModifierCmd      = 0x100000
+	ModifierNumpad   = 0x200000
+	ModifierFunction = 0x800000
+)
+
+var modifiers = map[string]int{
+	"shift":   ModifierShift,
+	"ctrl":    ModifierCtrl,
+	"control": ModifierCtrl,
+	"alt":     ModifierAlt,
+	"opt":     ModifierAlt,
+	"option":  ModifierAlt,
+	"cmd":     ModifierCmd,
+	"command": ModifierCmd,
+}
+
+type namedKey struct {
+	code  int
+	flags int
+}
+
+// namedKeys are the keys that cannot be written as a single character.
+var namedKeys = func() map[string]namedKey {
+	ret := map[string]namedKey{
+		"up":             {0xf700, ModifierNumpad},
+		"down":           {0xf701, ModifierNumpad},
+		"left":           {0xf702, ModifierNumpad},
+		"right":          {0xf703, ModifierNumpad},
+		"forward_delete": {0xf728, ModifierFunction},
+		"home":           {0xf729, ModifierFunction},
+		"end":            {0xf72b, ModifierFunction},
+		"pageup":         {0xf72c, ModifierFunction},
+		"pagedown":       {0xf72d, ModifierFunction},
+		"return":         {0xd, 0},
+		"enter":          {0xd, 0},
+		"tab":            {0x9, 0},
+		"esc":            {0x1b, 0},
+		"escape":         {0x1b, 0},
+		"space":          {0x20, 0},
+		"backspace":      {0x7f, 0},
+		"delete":         {0x7f, 0},
+		"plus":           {'+', 0},
+		"minus":          {'-', 0},
+		"period":         {'.', 0},
+		"comma":          {',', 0},
+	}
+
+	for i := 1; i <= 12; i++ {
+		ret[fmt.Sprintf("f%d", i)] = namedKey{0xf704 + i - 1, ModifierFunction}
+	}
+
+	return ret
+}()
+
+// KeyCode converts a key in the notation of germ.yaml, modifiers and a key
+// joined by +, for example alt+a, cmd+shift+| or ctrl+left, to its iTerm2
+// key code, such as 0x61-0x80000.
+func KeyCode(notation string) (string, error) {
+	value := strings.ToLower(strings.TrimSpace(notation))
+
+	// the + key itself
+	if strings.HasSuffix(value, "++") || value == "+" {
+		value = strings.TrimSuffix(value, "+") + "plus"
+	}
+
+	parts := strings.Split(value, "+")
+	key := parts[len(parts)-1]
+	flags := 0
+
+	for _, name := range parts[:len(parts)-1] {
+		flag, ok := modifiers[name]
+		if !ok {
+			return "", fmt.Errorf("unknown modifier %q in %q", name, notation)
+		}
+
+		if flags&flag != 0 {
+			return "", fmt.Errorf("duplicate modifier %q in %q", name, notation)
+		}
+
+		flags |= flag
+	}
+
+	if named, ok := namedKeys[key]; ok {
+		return fmt.Sprintf("0x%x-0x%x", named.code, flags|named.flags), nil
+	}
+
+	if utf8.RuneCountInString(key) != 1 {
+		return "", fmt.Errorf("unknown key %q in %q", key, notation)
+	}
+
+	r, _ := utf8.DecodeRuneInString(key)
+
+	return fmt.Sprintf("0x%x-0x%x", r, flags), nil
+}
+
+// keyboardAction describes a binding action of germ.yaml.
+type keyboardAction struct {
+	action int64
+	// text is used by the actions without an argument, the others need one.
+	text     string
+	argument bool
+}
+
+func menu(item string) string {
+	return item + "\n" + item
+}
+
+// KeyboardActions maps the action names of germ.yaml to iTerm2 actions.
+var KeyboardActions = map[string]keyboardAction{
+	"next_session":      {action: 0},
+	"next_window":       {action: 1},
+	"previous_session":  {action: 2},
+	"previous_window":   {action: 3},
+	"scroll_end":        {action: 4},
+	"scroll_home":       {action: 5},
+	"scroll_line_down":  {action: 6},
+	"scroll_line_up":    {action: 7},
+	"scroll_page_down":  {action: 8},
+	"scroll_page_up":    {action: 9},
+	"send_escape":       {action: 10, argument: true},
+	"send_hex":          {action: 11, argument: true},
+	"send_text":         {action: KeyboardSendText, argument: true},
+	"ignore":            {action: 13},
+	"toggle_fullscreen": {action: 23},
+	"menu":              {action: KeyboardSelectMenuItem, argument: true},
+	"split_horizontal":  {action: KeyboardSelectMenuItem, text: menu("Split Horizontally with Current Profile")},
+	"split_vertical":    {action: KeyboardSelectMenuItem, text: menu("Split Vertically with Current Profile")},
+	"new_window":        {action: 26, argument: true},
+	"new_tab":           {action: 27, argument: true},
+	// select_profile opens the profile in a split, like the alt+a login
+	// shortcuts.
+	"select_profile":                  {action: KeyboardSplitHorizontallyProfile, argument: true},
+	"split_horizontally_with_profile": {action: KeyboardSplitHorizontallyProfile, argument: true},
+	"split_vertically_with_profile":   {action: 29, argument: true},
+	"next_pane":                       {action: 30},
+	"previous_pane":                   {action: 31},
+	"move_tab_left":                   {action: 33},
+	"move_tab_right":                  {action: 34},
+	"run_coprocess":                   {action: 35, argument: true},
+	"set_profile":                     {action: 37, argument: true},
+}
+
+// profileActions take a profile GUID as their argument, see ResolveProfiles.
+var profileActions = map[int64]struct{}{
+	26:                               {},
+	27:                               {},
+	KeyboardSplitHorizontallyProfile: {},
+	29:                               {},
+	37:                               {},
+}
+
+// ParseBinding converts a binding of germ.yaml to a keyboard map. A binding
+// is the name of an action without argument, such as split_vertical, a map
+// from an action name to its argument, such as {send_text: "ls\n"}, or a raw
+// {action: 12, text: "ls\n"}.
+func ParseBinding(value interface{}) (KeyboardMap, error) {
+	switch v := value.(type) {
+	case string:
+		a, ok := KeyboardActions[v]
+		if !ok {
+			return KeyboardMap{}, fmt.Errorf("unknown action %q", v)
+		}
+
+		if a.argument {
+			return KeyboardMap{}, fmt.Errorf("action %s needs an argument, use {%s: ...}", v, v)
+		}
+
+		return KeyboardMap{Action: a.action, Text: a.text}, nil
+	case map[string]interface{}:
+		if action, ok := v["action"]; ok {
+			number, err := strconv.ParseInt(fmt.Sprint(action), 10, 64)
+			if err != nil {
+				return KeyboardMap{}, fmt.Errorf("invalid action %v", action)
+			}
+
+			text, _ := v["text"].(string)
+
+			return KeyboardMap{Action: number, Text: text}, nil
+		}
+
+		if len(v) != 1 {
+			return KeyboardMap{}, fmt.Errorf("expected a single action, got %d", len(v))
+		}
+
+		for name, argument := range v {
+			a, ok := KeyboardActions[name]
+			if !ok {
+				return KeyboardMap{}, fmt.Errorf("unknown action %q", name)
+			}
+
+			text, ok := argument.(string)
+			if !ok || !a.argument {
+				return KeyboardMap{}, fmt.Errorf("action %s takes no argument", name)
+			}
+
+			if a.action == KeyboardSelectMenuItem && !strings.Contains(text, "\n") {
+				text = menu(text)
+			}
+
+			return KeyboardMap{Action: a.action, Text: text}, nil
+		}
+	case map[interface{}]interface{}:
+		converted := map[string]interface{}{}
+		for k, item := range v {
+			converted[fmt.Sprint(k)] = item
+		}
+
+		return ParseBinding(converted)
+	}
+
+	return KeyboardMap{}, fmt.Errorf("invalid binding %v", value)
+}
+
+// Bindings are key bindings by key notation, see KeyCode and ParseBinding.
+type Bindings map[string]interface{}
+
+// Compile returns the keyboard maps of the bindings by iTerm2 key code.
+// Invalid bindings are reported and left out.
+func (b Bindings) Compile() (map[string]KeyboardMap, error) {
+	var keys []string
+	for key := range b {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	ret := map[string]KeyboardMap{}
+	var errs []error
+
+	for _, key := range keys {
+		code, err := KeyCode(key)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		km, err := ParseBinding(b[key])
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "key %s", key))
+			continue
+		}
+
+		ret[code] = km
+	}
+
+	return ret, stderrors.Join(errs...)
+}
+
+// keyPrefix returns the character and modifiers of a key code, without the
+// optional virtual key code.
+func keyPrefix(code string) string {
+	parts := strings.SplitN(code, "-", 3)
+	if len(parts) < 2 {
+		return code
+	}
+
+	return parts[0] + "-" + parts[1]
+}
+
+// Bind sets the keyboard map of the key code, replacing the bindings of the
+// same key written with or without a virtual key code.
+func (p *Profile) Bind(code string, km KeyboardMap) {
+	if p.KeyboardMap == nil {
+		p.KeyboardMap = map[string]KeyboardMap{}
+	}
+
+	for existing := range p.KeyboardMap {
+		if keyPrefix(existing) == keyPrefix(code) {
+			delete(p.KeyboardMap, existing)
+		}
+	}
+
+	p.KeyboardMap[code] = km
+}
+
+// ResolveProfiles replaces the profile names in the arguments of the profile
+// actions, such as select_profile, with the GUIDs iTerm2 expects. Arguments
+// that do not name a profile are left untouched.
+func (p *Profiles) ResolveProfiles() {
+	guids := map[string]string{}
+	for _, prof := range p.Profiles {
+		guids[prof.Name] = prof.GUID
+	}
+
+	for i := range p.Profiles {
+		for code, km := range p.Profiles[i].KeyboardMap {
+			if _, ok := profileActions[km.Action]; !ok {
+				continue
+			}
+
+			if guid, ok := guids[km.Text]; ok && guid != "" {
+				km.Text = guid
+				p.Profiles[i].KeyboardMap[code] = km
+			}
+		}
+	}
+}
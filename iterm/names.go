@@ -1,85 +1,59 @@
 package iterm
 
 import (
-	"encoding/json"
-	"fmt"
-	"math/rand"
-	"os"
+	"time"
 
-	"github.com/adrg/xdg"
-	log "github.com/sirupsen/logrus"
+	"github.com/mhristof/germ/names"
+	"github.com/pkg/errors"
 )
 
-func newUniqueName(name string) string {
-	storage, err := xdg.ConfigFile("germ-profiles.json")
-	if err != nil {
-		log.WithFields(log.Fields{
-			"error":   err,
-			"storage": storage,
-		}).Error("Failed to get storage path")
-
-		return name
-	}
-
-	contents, err := os.ReadFile(storage)
-	if err != nil {
-		log.WithFields(log.Fields{
-			"error":   err,
-			"storage": storage,
-		}).Debug("Failed to read storage file")
-		os.WriteFile(storage, []byte(fmt.Sprintf(`{"%s": "%s"}`, name, name)), 0o644)
-		return name
-	}
+// uniqueNames is the names store of a generation. A store that cannot be
+// read is never written, the profiles then use their own name and the error
+// is reported when saving.
+type uniqueNames struct {
+	store *names.Store
+	err   error
+}
 
-	var existing map[string]string
-	err = json.Unmarshal(contents, &existing)
+// loadNames opens the unique names store, see names.Store.PruneAfter.
+func loadNames(pruneAfter time.Duration) *uniqueNames {
+	path, err := names.DefaultPath()
 	if err != nil {
-		log.WithFields(log.Fields{
-			"error":   err,
-			"storage": storage,
-		}).Debug("Failed to unmarshal storage file")
-		return name
+		return &uniqueNames{err: err}
 	}
 
-	uname, found := existing[name]
-	if found {
-		return uname
-	}
+	store, err := names.Open(path, pruneAfter)
 
-	for {
-		uname = fmt.Sprintf("%s-%s", left[rand.Intn(len(left))], right[rand.Intn(len(right))])
-
-		inUse := false
-		for _, existingName := range existing {
-			if existingName == uname {
-				inUse = true
-				continue
-			}
-		}
+	return &uniqueNames{store: store, err: err}
+}
 
-		if !inUse {
-			break
-		}
+// unique returns the unique name of the profile.
+func (n *uniqueNames) unique(name string) string {
+	if n.store == nil {
+		return name
 	}
 
-	existing[name] = uname
+	return n.store.Unique(name)
+}
 
-	prettyJSON, err := json.MarshalIndent(existing, "", "    ")
-	if err != nil {
-		log.WithFields(log.Fields{
-			"error":   err,
-			"storage": storage,
-		}).Debug("Failed to marshal storage file")
-		return name
+// known returns true if the profile had a unique name before this
+// generation.
+func (n *uniqueNames) known(name string) bool {
+	return n.store != nil && n.store.Known(name)
+}
+
+// generated records all the profiles of the generation.
+func (n *uniqueNames) generated(profiles []string) {
+	if n.store != nil {
+		n.store.Generated(profiles)
 	}
+}
 
-	err = os.WriteFile(storage, []byte(prettyJSON), 0o644)
-	if err != nil {
-		log.WithFields(log.Fields{
-			"error":   err,
-			"storage": storage,
-		}).Error("Failed to write storage file")
+// save writes the unique names picked during the generation.
+func (n *uniqueNames) save() error {
+	if n.err != nil {
+		return errors.Wrap(n.err, "unique names are disabled")
 	}
 
-	return existing[name]
+	return n.store.Save()
 }
@@ -2,14 +2,17 @@ package iterm
 
 import (
 	"encoding/json"
+	stderrors "errors"
 	"fmt"
 	"io/ioutil"
-	"os/exec"
+	"maps"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 )
 
@@ -26,6 +29,10 @@ type Profiles struct {
 type Profile struct {
 	AllowTitleSetting       bool                   `json:"Allow Title Setting"`
 	BadgeText               string                 `json:"Badge Text"`
+	BadgeColor              *Color                 `json:"Badge Color,omitempty"`
+	BadgeFont               string                 `json:"Badge Font,omitempty"`
+	TabColor                *Color                 `json:"Tab Color,omitempty"`
+	UseTabColor             bool                   `json:"Use Tab Color,omitempty"`
 	Command                 string                 `json:"Command"`
 	CustomCommand           string                 `json:"Custom Command"`
 	InitialText             string                 `json:"Initial Text"`
@@ -68,6 +75,15 @@ type Profile struct {
 	InitialUseTransparency  bool                   `json:"Initial Use Transparency"`
 	SemanticHistory         map[string]string      `json:"Semantic History"`
 	SetLocalEnvironmentVars int                    `json:"Set Local Environment Vars"`
+	// Extra holds the keys germ does not model, they are written as is.
+	Extra map[string]interface{} `json:"-"`
+	// Identity is what the profile connects to, for example the AWS account
+	// and role. The GUID is derived from it so it survives renames. Empty
+	// means the name is the identity.
+	Identity string `json:"-"`
+	// Metadata describes what the profile connects to for the badge
+	// templates, for example its AWS account or kubernetes cluster.
+	Metadata map[string]string `json:"-"`
 }
 
 type Color struct {
@@ -81,7 +97,7 @@ type Color struct {
 type KeyboardMap struct {
 	Action  int64  `json:"Action"`
 	Text    string `json:"Text"`
-	Version int64  `json:"Version",omitempty`
+	Version int64  `json:"Version,omitempty"`
 }
 
 type Trigger struct {
@@ -108,6 +124,81 @@ func (p *Profiles) Add(prof Profile) {
 	p.Profiles = append(p.Profiles, prof)
 }
 
+// SetMetadata sets the metadata key, empty values are ignored.
+func (p *Profile) SetMetadata(key, value string) {
+	if value == "" {
+		return
+	}
+
+	if p.Metadata == nil {
+		p.Metadata = map[string]string{}
+	}
+
+	p.Metadata[key] = value
+}
+
+// Copy returns a deep copy of the profile, changing it leaves p untouched.
+func (p Profile) Copy() Profile {
+	ret := p
+
+	if p.BadgeColor != nil {
+		c := *p.BadgeColor
+		ret.BadgeColor = &c
+	}
+
+	if p.TabColor != nil {
+		c := *p.TabColor
+		ret.TabColor = &c
+	}
+
+	ret.SmartSelectionRules = slices.Clone(p.SmartSelectionRules)
+	for i := range ret.SmartSelectionRules {
+		ret.SmartSelectionRules[i].Actions = slices.Clone(p.SmartSelectionRules[i].Actions)
+	}
+
+	ret.KeyboardMap = maps.Clone(p.KeyboardMap)
+	ret.Tags = slices.Clone(p.Tags)
+	ret.Triggers = slices.Clone(p.Triggers)
+	ret.BoundHosts = slices.Clone(p.BoundHosts)
+	ret.SemanticHistory = maps.Clone(p.SemanticHistory)
+	ret.Metadata = maps.Clone(p.Metadata)
+
+	if p.Extra != nil {
+		ret.Extra = copyValue(p.Extra).(map[string]interface{})
+	}
+
+	return ret
+}
+
+// copyValue returns a deep copy of a decoded JSON or YAML value.
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		ret := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			ret[k] = copyValue(item)
+		}
+
+		return ret
+	case map[interface{}]interface{}:
+		ret := make(map[interface{}]interface{}, len(v))
+		for k, item := range v {
+			ret[k] = copyValue(item)
+		}
+
+		return ret
+	case []interface{}:
+		ret := make([]interface{}, len(v))
+		for i, item := range v {
+			ret[i] = copyValue(item)
+		}
+
+		return ret
+	}
+
+	return value
+}
+
 func (p *Profile) HasTag(needle string) bool {
 	for _, tag := range p.Tags {
 		if tag == needle {
@@ -134,6 +225,18 @@ func (p *Profile) FindTag(key string) (string, bool) {
 	return "", found
 }
 
+// FindIdentity returns the first profile with the identity, "name:<name>"
+// for the profiles without one. Use it instead of FindGUID to find related
+// profiles, the identity survives renames.
+func (p *Profiles) FindIdentity(id string) (Profile, bool) {
+	for _, prof := range p.Profiles {
+		if identity(prof) == id {
+			return prof, true
+		}
+	}
+	return Profile{}, false
+}
+
 func (p *Profiles) FindGUID(guid string) (Profile, bool) {
 	for _, prof := range p.Profiles {
 		if prof.GUID == guid {
@@ -143,87 +246,54 @@ func (p *Profiles) FindGUID(guid string) (Profile, bool) {
 	return Profile{}, false
 }
 
-func NewProfilesFromFile(path string) []Profile {
+func NewProfilesFromFile(path string) ([]Profile, error) {
 	file, err := homedir.Expand(path)
 	if err != nil {
-		panic(err)
+		return nil, errors.Wrap(err, "cannot expand path")
 	}
 
 	data, err := ioutil.ReadFile(file)
 	if err != nil {
-		return []Profile{}
+		return []Profile{}, nil
 	}
 
 	var profs map[string]map[string]string
 
 	err = json.Unmarshal(data, &profs)
 	if err != nil {
-		panic(err)
+		return nil, errors.Wrapf(err, "cannot unmarshal %s", file)
 	}
 
 	ret := make([]Profile, len(profs))
+	var errs []error
+
+	gen := NewGeneration(0)
 
 	i := 0
 	for key, config := range profs {
-		ret[i] = *NewProfile(key, config)
+		prof, err := gen.NewProfile(key, config)
+		if err != nil {
+			errs = append(errs, err)
+		}
+
+		ret[i] = *prof
 		i++
 	}
 
-	return ret
-}
+	errs = append(errs, gen.Err(), gen.Save())
 
-func NewProfile(name string, config map[string]string) *Profile {
-	prof := createBaseProfile(name, config)
-	applyConfigOverrides(&prof, config)
-	prof.Colors()
-	return &prof
-}
-
-// createBaseProfile creates a profile with default settings
-func createBaseProfile(name string, config map[string]string) Profile {
-	python3, err := exec.LookPath("python3")
-	if err != nil {
-		log.Fatal().Err(err).Msg("cannot find python3")
-	}
-
-	uname := newUniqueName(name)
-
-	prof := Profile{
-		Name:                   name,
-		GUID:                   name,
-		Tags:                   Tags(config),
-		CustomDirectory:        "Recycle",
-		SmartSelectionRules:    SmartSelectionRules("~/.germ.ssr.json"),
-		Triggers:               Triggers(name),
-		BadgeText:              name + "\n" + uname,
-		TitleComponents:        32,
-		CustomWindowTitle:      name,
-		AllowTitleSetting:      false,
-		FlashingBell:           true,
-		SilenceBell:            true,
-		KeyboardMap:            CreateKeyboardMap(name, config),
-		UnlimitedScrollback:    true,
-		NormalFont:             "HackNFM-Regular 12",
-		Transparency:           0,
-		InitialUseTransparency: false,
-		SemanticHistory: map[string]string{
-			"text":   fmt.Sprintf(`%s $HOME/bin/nvim-edit.py \1 \2`, python3),
-			"action": "command",
-		},
-		SetLocalEnvironmentVars: 2,
-	}
-
-	prof.Tags = append(prof.Tags, uname)
-	return prof
+	return ret, stderrors.Join(errs...)
 }
 
 // applyConfigOverrides applies configuration overrides to the profile
-func applyConfigOverrides(prof *Profile, config map[string]string) {
+func applyConfigOverrides(prof *Profile, config map[string]string) error {
 	applyCommandConfig(prof, config)
 	applyBadgeTextConfig(prof, config)
-	applyTitleSettingConfig(prof, config)
+	err := applyTitleSettingConfig(prof, config)
 	applyRegionConfig(prof, config)
 	applyInitialTextConfig(prof, config)
+
+	return err
 }
 
 // applyCommandConfig handles command-related configuration
@@ -249,14 +319,16 @@ func applyBadgeTextConfig(prof *Profile, config map[string]string) {
 }
 
 // applyTitleSettingConfig handles title setting configuration
-func applyTitleSettingConfig(prof *Profile, config map[string]string) {
+func applyTitleSettingConfig(prof *Profile, config map[string]string) error {
 	if v, found := config["AllowTitleSetting"]; found {
 		value, err := strconv.ParseBool(v)
 		if err != nil {
-			log.Fatal().Interface("v", v).Msg("value is not convertible to bool")
+			return errors.Wrapf(err, "AllowTitleSetting value %q is not convertible to bool", v)
 		}
 		prof.AllowTitleSetting = value
 	}
+
+	return nil
 }
 
 // applyRegionConfig handles AWS region configuration
@@ -377,11 +449,11 @@ func CreateKeyboardMap(name string, config map[string]string) map[string]Keyboar
 func createDefaultKeyboardMaps() map[string]KeyboardMap {
 	return map[string]KeyboardMap{
 		"0x5f-0x120000": {
-			Action: 25,
+			Action: KeyboardSelectMenuItem,
 			Text:   "Split Horizontally with Current Profile\nSplit Horizontally with Current Profile",
 		},
 		"0x7c-0x120000": {
-			Action: 25,
+			Action: KeyboardSelectMenuItem,
 			Text:   "Split Vertically with Current Profile\nSplit Vertically with Current Profile",
 		},
 	}
@@ -392,7 +464,7 @@ func addProfileSpecificKeyboardMaps(maps map[string]KeyboardMap, config map[stri
 	// Add source profile shortcut
 	if v, found := config["source_profile"]; found {
 		maps[KeyboardSortcutAltA] = KeyboardMap{
-			Action: 28,
+			Action: KeyboardSplitHorizontallyProfile,
 			Text:   fmt.Sprintf("login-%s", v),
 		}
 	}
@@ -401,7 +473,7 @@ func addProfileSpecificKeyboardMaps(maps map[string]KeyboardMap, config map[stri
 	if _, found := config["sso_account_id"]; found {
 		maps[KeyboardSortcutAltA] = KeyboardMap{
 			Version: 1,
-			Action:  12,
+			Action:  KeyboardSendText,
 			Text:    "aws sso login",
 		}
 	}
@@ -446,6 +518,20 @@ func (p *Profiles) UpdateAWSSmartSelectionRules() {
 }
 
 func (p *Profiles) UpdateKeyboardMaps() {
+	// the k8s profiles name their AWS profile as in the AWS config, the
+	// AWS profiles keep that name in their metadata.
+	awsIdentities := map[string]string{}
+	for _, profile := range p.Profiles {
+		name, ok := profile.Metadata["profile"]
+		if !ok || profile.HasTag("k8s") {
+			continue
+		}
+
+		if _, ok := awsIdentities[name]; !ok {
+			awsIdentities[name] = identity(profile)
+		}
+	}
+
 	for _, profile := range p.Profiles {
 		if !profile.HasTag("k8s") {
 			continue
@@ -457,11 +543,16 @@ func (p *Profiles) UpdateKeyboardMaps() {
 			continue
 		}
 
-		sourceProfile, found := p.FindGUID(awsProfile)
+		id, ok := awsIdentities[awsProfile]
+		if !ok {
+			id = "name:" + awsProfile
+		}
+
+		sourceProfile, found := p.FindIdentity(id)
 
 		if !found {
 			log.Error().Str("awsProfile", awsProfile).
-				Str("k8s", profile.GUID).
+				Str("k8s", profile.Name).
 				Msg("AWS profile not found")
 		}
 
@@ -481,49 +572,54 @@ func (p *Profiles) SourceProfiles() []string {
 			}
 		}
 		if isSource {
-			ret = append(ret, profile.GUID)
+			ret = append(ret, profile.Name)
 		}
 	}
 	return ret
 }
 
+// ProfileTree groups the profiles with a source-profile= tag by the root of
+// their role chain, following the source profiles of their source profiles.
+// Profiles in a source profile cycle are left out.
 func (p *Profiles) ProfileTree() map[string][]string {
 	ret := map[string][]string{}
+	sources := map[string]string{}
 
 	for _, profile := range p.Profiles {
-		for _, tag := range profile.Tags {
-			if strings.HasPrefix(tag, "source-profile=") {
-				parts := strings.Split(tag, "=")
-				if _, ok := ret[parts[1]]; ok != true {
-					ret[parts[1]] = make([]string, 1)
-					ret[parts[1]][0] = profile.GUID
-				} else {
-					ret[parts[1]] = append(ret[parts[1]], profile.GUID)
-				}
-			}
+		if source, found := profile.FindTag("source-profile"); found {
+			sources[profile.Name] = source
 		}
 	}
 
-	return ret
-}
+	for _, profile := range p.Profiles {
+		root, found := sources[profile.Name]
+		if !found {
+			continue
+		}
 
-func isProd(name string) bool {
-	if strings.Contains(name, "nonprd") {
-		return false
-	}
+		seen := map[string]struct{}{profile.Name: {}}
+		for {
+			if _, ok := seen[root]; ok {
+				root = ""
+				break
+			}
 
-	if strings.Contains(name, "nonprod") {
-		return false
-	}
+			seen[root] = struct{}{}
 
-	if strings.Contains(name, "prod") {
-		return true
-	}
+			source, found := sources[root]
+			if !found {
+				break
+			}
 
-	if strings.Contains(name, "prd") {
-		return true
+			root = source
+		}
+
+		if root != "" {
+			ret[root] = append(ret[root], profile.Name)
+		}
 	}
-	return false
+
+	return ret
 }
 
 func (p *Profile) Colors() {
@@ -585,14 +681,6 @@ func (p *Profile) Colors() {
 	// Ansi 15 - Bright White
 	p.Ansi15Color = Color{ColorSpace: "sRGB", RedComponent: 1, GreenComponent: 1, BlueComponent: 1, AlphaComponent: 1}
 
-	if isProd(p.Name) {
-		p.BackgroundColor.ColorSpace = "sRGB"
-		p.BackgroundColor.RedComponent = 0.25
-		p.BackgroundColor.GreenComponent = 0
-		p.BackgroundColor.BlueComponent = 0
-		p.BackgroundColor.AlphaComponent = 1
-		return
-	}
 	if p.HasTag("k8s") {
 		p.BackgroundColor.RedComponent = 0
 		p.BackgroundColor.ColorSpace = "sRGB"
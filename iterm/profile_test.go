@@ -6,10 +6,14 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/MakeNowJust/heredoc"
+	"github.com/adrg/xdg"
+	"github.com/mitchellh/go-homedir"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -140,13 +144,6 @@ func TestNewProfile(t *testing.T) {
 				return p.Tags[1] == "this" && p.Tags[2] == "that"
 			},
 		},
-		{
-			name:   "production profile (red)",
-			config: map[string]string{},
-			eval: func(p *Profile) bool {
-				return p.BackgroundColor.RedComponent != 0
-			},
-		},
 		{
 			name:   "nonproduction profile (nonred)",
 			config: map[string]string{},
@@ -184,7 +181,8 @@ func TestNewProfile(t *testing.T) {
 	}
 
 	for _, test := range cases {
-		profile := NewProfile(test.name, test.config)
+		profile, err := NewProfile(test.name, test.config)
+		assert.NoError(t, err, test.name)
 		assert.Contains(t, []string{"Recycle"}, profile.CustomDirectory)
 		if test.eval != nil {
 			assert.True(t, test.eval(profile), test.name)
@@ -202,7 +200,32 @@ func TestUpdateKeyboardMaps(t *testing.T) {
 			profiles: Profiles{
 				Profiles: []Profile{
 					{
-						GUID: "awesomeAWSProfile",
+						Name: "awesomeAWSProfile",
+						KeyboardMap: map[string]KeyboardMap{
+							KeyboardSortcutAltA: {
+								Text: "tada!",
+							},
+						},
+					},
+					{
+						Name: "k8s",
+						Tags: []string{
+							"k8s",
+							"aws-profile=awesomeAWSProfile",
+						},
+						KeyboardMap: map[string]KeyboardMap{},
+					},
+				},
+			},
+		},
+		{
+			name: "renamed aws profile with an identity",
+			profiles: Profiles{
+				Profiles: []Profile{
+					{
+						Name:     "prefix-awesomeAWSProfile",
+						Identity: "aws:123:admin",
+						Metadata: map[string]string{"profile": "awesomeAWSProfile"},
 						KeyboardMap: map[string]KeyboardMap{
 							KeyboardSortcutAltA: {
 								Text: "tada!",
@@ -210,7 +233,8 @@ func TestUpdateKeyboardMaps(t *testing.T) {
 						},
 					},
 					{
-						GUID: "k8s",
+						Name:     "k8s",
+						Metadata: map[string]string{"profile": "awesomeAWSProfile"},
 						Tags: []string{
 							"k8s",
 							"aws-profile=awesomeAWSProfile",
@@ -308,15 +332,6 @@ func TestColors(t *testing.T) {
 		profile Profile
 		exp     func(Profile) bool
 	}{
-		{
-			name: "Production profile",
-			profile: Profile{
-				Name: "prod",
-			},
-			exp: func(p Profile) bool {
-				return p.BackgroundColor.RedComponent != 0
-			},
-		},
 		{
 			name: "Non Production profile",
 			profile: Profile{
@@ -326,15 +341,6 @@ func TestColors(t *testing.T) {
 				return p.BackgroundColor.RedComponent == 0
 			},
 		},
-		{
-			name: "Production profile",
-			profile: Profile{
-				Name: "prd",
-			},
-			exp: func(p Profile) bool {
-				return p.BackgroundColor.RedComponent != 0
-			},
-		},
 		{
 			name: "Non Production profile",
 			profile: Profile{
@@ -407,11 +413,234 @@ func TestSmartSelectionRules(t *testing.T) {
 		file, cleanup := tempFile(t, test.customContents)
 		defer cleanup()
 
-		assert.True(t, test.exp(SmartSelectionRules(file)), test.name)
+		rules, err := SmartSelectionRules(file)
+		assert.NoError(t, err, test.name)
+		assert.True(t, test.exp(rules), test.name)
+
+	}
+}
+
+func TestSmartSelectionRulesInvalidFile(t *testing.T) {
+	file, cleanup := tempFile(t, "[{")
+	defer cleanup()
+
+	rules, err := SmartSelectionRules(file)
+	assert.Error(t, err)
+	assert.NotEmpty(t, rules, "the default rules are still returned")
+}
+
+func TestNewProfileErrors(t *testing.T) {
+	profile, err := NewProfile("bad-title", map[string]string{
+		"AllowTitleSetting": "maybe",
+		"Command":           "date",
+	})
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "bad-title")
+	assert.Equal(t, "date", profile.Command)
+}
+
+func TestTriggersFromFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	homedir.Reset()
+	t.Cleanup(homedir.Reset)
+
+	err := os.WriteFile(filepath.Join(home, ".germ.trigger.foo.json"), []byte(`[{"action": "BounceTrigger", "regex": "done"}]`), 0o644)
+	assert.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(home, ".germ.trigger.broken.json"), []byte(`{`), 0o644)
+	assert.NoError(t, err)
+
+	triggers, err := Triggers("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, Trigger{Action: "BounceTrigger", Regex: "done"}, triggers[len(triggers)-1])
+
+	triggers, err = Triggers("broken")
+	assert.Error(t, err)
+	assert.NotEmpty(t, triggers)
+}
+
+// isolate points HOME and the germ config to an empty directory.
+func isolate(tb testing.TB) string {
+	home := tb.TempDir()
+
+	for _, env := range []string{"HOME", "XDG_CONFIG_HOME"} {
+		old, ok := os.LookupEnv(env)
+		os.Setenv(env, home)
+
+		tb.Cleanup(func() {
+			if ok {
+				os.Setenv(env, old)
+			} else {
+				os.Unsetenv(env)
+			}
+		})
+	}
+
+	homedir.Reset()
+	xdg.Reload()
+	tb.Cleanup(func() {
+		homedir.Reset()
+		xdg.Reload()
+	})
+
+	return home
+}
+
+func TestGeneration(t *testing.T) {
+	home := isolate(t)
+
+	err := os.WriteFile(filepath.Join(home, ".germ.trigger.foo.json"), []byte(`[{"action": "BounceTrigger", "regex": "done"}]`), 0o644)
+	assert.NoError(t, err)
+
+	gen := NewGeneration(0)
+
+	foo, _ := gen.NewProfile("foo", map[string]string{})
+	bar, _ := gen.NewProfile("bar", map[string]string{})
+
+	assert.Equal(t, Trigger{Action: "BounceTrigger", Regex: "done"}, foo.Triggers[len(foo.Triggers)-1])
+	assert.Equal(t, len(foo.Triggers)-1, len(bar.Triggers))
+
+	// the profiles do not share the loaded rules
+	foo.SmartSelectionRules[0].Notes = "changed"
+	assert.NotEqual(t, "changed", bar.SmartSelectionRules[0].Notes)
+
+	storage := filepath.Join(home, "germ-profiles.json")
+	assert.NoFileExists(t, storage, "names are only written by Save")
+
+	assert.NoError(t, gen.Save())
+
+	data, err := os.ReadFile(storage)
+	assert.NoError(t, err)
+
+	var stored map[string]struct{ Name string }
+	assert.NoError(t, json.Unmarshal(data, &stored))
+	assert.Len(t, stored, 2)
+	assert.Contains(t, foo.BadgeText, stored["foo"].Name)
+
+	again, _ := NewGeneration(0).NewProfile("foo", map[string]string{})
+	assert.Equal(t, foo.BadgeText, again.BadgeText)
+
+	// the profiles without a generation share one, loaded from the new home
+	shared.Once, shared.gen = sync.Once{}, nil
+	t.Cleanup(func() { shared.Once, shared.gen = sync.Once{}, nil })
+
+	var nilGen *Generation
+	prof, _ := nilGen.NewProfile("foo", map[string]string{})
+	assert.Equal(t, foo.BadgeText, prof.BadgeText)
+	assert.Same(t, sharedGeneration(), sharedGeneration())
+}
+
+func TestNewGUID(t *testing.T) {
+	// uuid.uuid5(uuid.NAMESPACE_URL, "https://github.com/mhristof/germ/name:a") in python
+	assert.Equal(t, "D7999AEA-0352-51F8-AF13-699F8220AB62", NewGUID("name:a"))
+	assert.NotEqual(t, NewGUID("name:a"), NewGUID("name:b"))
+}
+
+func TestAssignGUIDs(t *testing.T) {
+	home := isolate(t)
+
+	err := os.WriteFile(filepath.Join(home, "germ-profiles.json"), []byte(`{"legacy": "foo-bar"}`), 0o644)
+	assert.NoError(t, err)
+
+	gen := NewGeneration(0)
+	profiles := []Profile{
+		{Name: "prod", Identity: "aws:123:admin"},
+		{Name: "prod-copy", Identity: "aws:123:admin"},
+		{Name: "legacy", Identity: "aws:456:admin"},
+		{Name: "ssh-host"},
+	}
+
+	gen.AssignGUIDs(profiles)
+	assert.NoError(t, gen.Save())
+
+	assert.Equal(t, NewGUID("aws:123:admin"), profiles[0].GUID)
+	assert.Equal(t, NewGUID("aws:123:admin#prod-copy"), profiles[1].GUID)
+	assert.Equal(t, "legacy", profiles[2].GUID, "profiles from before the guids keep their name")
+	assert.Equal(t, NewGUID("name:ssh-host"), profiles[3].GUID)
+
+	renamed := []Profile{
+		{Name: "production", Identity: "aws:123:admin"},
+		{Name: "legacy-renamed", Identity: "aws:456:admin"},
+	}
+
+	NewGeneration(0).AssignGUIDs(renamed)
+	assert.Equal(t, profiles[0].GUID, renamed[0].GUID)
+	assert.Equal(t, "legacy", renamed[1].GUID)
+
+	reordered := []Profile{
+		{Name: "staging-copy", Identity: "aws:789:admin"},
+		{Name: "staging", Identity: "aws:789:admin"},
+	}
+
+	gen = NewGeneration(0)
+	gen.AssignGUIDs(reordered)
+	assert.NoError(t, gen.Save())
+	assert.Equal(t, NewGUID("aws:789:admin"), reordered[1].GUID, "the order of the profiles does not matter")
+	assert.Equal(t, NewGUID("aws:789:admin#staging-copy"), reordered[0].GUID)
+
+	alone := []Profile{{Name: "staging-copy", Identity: "aws:789:admin"}}
 
+	NewGeneration(0).AssignGUIDs(alone)
+	assert.Equal(t, reordered[0].GUID, alone[0].GUID, "the GUID of a removed profile is not taken over")
+}
+
+func TestSaveGUIDsMerge(t *testing.T) {
+	home := isolate(t)
+
+	first, second := NewGeneration(0), NewGeneration(0)
+	first.AssignGUIDs([]Profile{{Name: "a"}})
+	second.AssignGUIDs([]Profile{{Name: "b"}})
+
+	assert.NoError(t, first.Save())
+	assert.NoError(t, second.Save())
+
+	data, err := os.ReadFile(filepath.Join(home, "germ-guids.json"))
+	assert.NoError(t, err)
+
+	var stored map[string]string
+	assert.NoError(t, json.Unmarshal(data, &stored))
+	assert.Equal(t, NewGUID("name:a"), stored["name:a"], "the guids of the other generation are kept")
+	assert.Equal(t, NewGUID("name:b"), stored["name:b"])
+}
+
+// benchmarkProfiles measures the creation of a generation of 100 profiles.
+func benchmarkProfiles(b *testing.B, generate func(names []string)) {
+	isolate(b)
+
+	var names []string
+	for i := 0; i < 100; i++ {
+		names = append(names, fmt.Sprintf("profile-%d", i))
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		generate(names)
 	}
 }
 
+func BenchmarkNewProfile(b *testing.B) {
+	benchmarkProfiles(b, func(names []string) {
+		for _, name := range names {
+			NewProfile(name, map[string]string{})
+		}
+	})
+}
+
+func BenchmarkGenerationNewProfile(b *testing.B) {
+	benchmarkProfiles(b, func(names []string) {
+		gen := NewGeneration(0)
+
+		for _, name := range names {
+			gen.NewProfile(name, map[string]string{})
+		}
+
+		gen.Save()
+	})
+}
+
 func tempFile(t *testing.T, contents string) (string, func()) {
 	dir, err := ioutil.TempDir("", "example")
 	if err != nil {
@@ -438,16 +667,16 @@ func TestProfileTree(t *testing.T) {
 			profiles: Profiles{
 				Profiles: []Profile{
 					{
-						GUID: "parent",
+						Name: "parent",
 					},
 					{
-						GUID: "child1",
+						Name: "child1",
 						Tags: []string{
 							"source-profile=parent",
 						},
 					},
 					{
-						GUID: "child2",
+						Name: "child2",
 						Tags: []string{
 							"source-profile=parent",
 						},
@@ -461,6 +690,32 @@ func TestProfileTree(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "role chain",
+			profiles: Profiles{
+				Profiles: []Profile{
+					{Name: "user"},
+					{Name: "hub", Tags: []string{"source-profile=user"}},
+					{Name: "account", Tags: []string{"source-profile=hub"}},
+				},
+			},
+			out: map[string][]string{
+				"user": {"hub", "account"},
+			},
+		},
+		{
+			name: "cycle",
+			profiles: Profiles{
+				Profiles: []Profile{
+					{Name: "a", Tags: []string{"source-profile=b"}},
+					{Name: "b", Tags: []string{"source-profile=a"}},
+					{Name: "c", Tags: []string{"source-profile=d"}},
+				},
+			},
+			out: map[string][]string{
+				"d": {"c"},
+			},
+		},
 	}
 
 	for _, test := range cases {
@@ -561,7 +816,8 @@ func TestProfiles_ProfileTree(t *testing.T) {
 }
 
 func TestProfile_ConfigDefaults(t *testing.T) {
-	profile := NewProfile("test", map[string]string{})
+	profile, err := NewProfile("test", map[string]string{})
+	assert.NoError(t, err)
 	
 	// Test that default smart selection rules are added
 	assert.NotEmpty(t, profile.SmartSelectionRules)
@@ -633,4 +889,369 @@ func TestSmartSelectionRule(t *testing.T) {
 	err = json.Unmarshal(data, &unmarshaled)
 	assert.NoError(t, err)
 	assert.Equal(t, rule, unmarshaled)
-}
\ No newline at end of file
+}
+func TestParseColor(t *testing.T) {
+	c, err := ParseColor("#ff8000")
+	assert.NoError(t, err)
+	assert.Equal(t, Color{ColorSpace: "sRGB", RedComponent: 1, GreenComponent: 128.0 / 255, AlphaComponent: 1}, c)
+
+	_, err = ParseColor("red")
+	assert.Error(t, err)
+
+	p := Profile{}
+	assert.NoError(t, p.SetColor("ansi1", c))
+	assert.Equal(t, c, p.Ansi1Color)
+	assert.Error(t, p.SetColor("ansi16", c))
+
+	assert.NoError(t, p.Set(SeparateColors, true))
+	assert.NoError(t, p.SetColor("background", c))
+	assert.Equal(t, c, p.BackgroundColor)
+	assert.Equal(t, c, p.Extra["Background Color (Light)"])
+	assert.Equal(t, c, p.Extra["Background Color (Dark)"])
+}
+
+func TestCopy(t *testing.T) {
+	orig := Profile{
+		Name:                "foo",
+		Tags:                []string{},
+		KeyboardMap:         map[string]KeyboardMap{"a": {Action: 1}},
+		SmartSelectionRules: []SmartSelectionRule{{Actions: []SmartSelectionRuleAction{{Title: "a"}}}},
+		BadgeColor:          &Color{RedComponent: 1},
+		Metadata:            map[string]string{"name": "foo"},
+		Extra:               map[string]interface{}{"nested": map[string]interface{}{"a": 1}},
+	}
+
+	copied := orig.Copy()
+	copied.Tags = append(copied.Tags, "bar")
+	copied.Bind("b", KeyboardMap{Action: 2})
+	copied.SmartSelectionRules[0].Actions[0].Title = "b"
+	copied.BadgeColor.RedComponent = 0
+	copied.SetMetadata("name", "bar")
+	copied.Extra["nested"].(map[string]interface{})["a"] = 2
+
+	assert.Equal(t, []string{}, orig.Tags)
+	assert.Len(t, orig.KeyboardMap, 1)
+	assert.Equal(t, "a", orig.SmartSelectionRules[0].Actions[0].Title)
+	assert.Equal(t, float64(1), orig.BadgeColor.RedComponent)
+	assert.Equal(t, "foo", orig.Metadata["name"])
+	assert.Equal(t, 1, orig.Extra["nested"].(map[string]interface{})["a"])
+	assert.Nil(t, orig.Copy().Triggers)
+}
+
+func TestColorKey(t *testing.T) {
+	cases := []struct {
+		name     string
+		expected string
+	}{
+		{name: "background", expected: "Background Color"},
+		{name: "ANSI15", expected: "Ansi 15 Color"},
+		{name: "selection color", expected: "Selection Color"},
+		{name: "Tab Color (Dark)", expected: "Tab Color (Dark)"},
+		{name: "Use Tab Color"},
+		{name: "bg"},
+	}
+
+	for _, test := range cases {
+		key, err := ColorKey(test.name)
+		if test.expected == "" {
+			assert.Error(t, err, test.name)
+			continue
+		}
+
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, test.expected, key, test.name)
+	}
+}
+
+func TestSet(t *testing.T) {
+	cases := []struct {
+		name  string
+		key   string
+		value interface{}
+		check func(p Profile)
+		err   bool
+	}{
+		{
+			name:  "modelled field, case insensitive",
+			key:   "normal font",
+			value: "Monaco 14",
+			check: func(p Profile) { assert.Equal(t, "Monaco 14", p.NormalFont) },
+		},
+		{
+			name:  "hex color",
+			key:   "Background Color",
+			value: "#ff0000",
+			check: func(p Profile) { assert.Equal(t, 1.0, p.BackgroundColor.RedComponent) },
+		},
+		{
+			name: "color components",
+			key:  "Link Color",
+			value: map[string]interface{}{
+				"red component": 0.5,
+				"color space":   "sRGB",
+			},
+			check: func(p Profile) {
+				assert.Equal(t, Color{RedComponent: 0.5, ColorSpace: "sRGB"}, p.Extra["Link Color"])
+			},
+		},
+		{
+			name:  "tab color",
+			key:   "tab color",
+			value: "#ff0000",
+			check: func(p Profile) {
+				assert.Equal(t, &Color{RedComponent: 1, ColorSpace: "sRGB", AlphaComponent: 1}, p.TabColor)
+			},
+		},
+		{
+			name:  "bound hosts",
+			key:   "Bound Hosts",
+			value: []interface{}{"foo.example.com"},
+			check: func(p Profile) { assert.Equal(t, []string{"foo.example.com"}, p.BoundHosts) },
+		},
+		{
+			name: "keyboard map",
+			key:  "Keyboard Map",
+			value: map[string]interface{}{
+				"0x61-0x80000": map[string]interface{}{"action": 12, "text": "ls"},
+			},
+			check: func(p Profile) {
+				assert.Equal(t, KeyboardMap{Action: 12, Text: "ls"}, p.KeyboardMap["0x61-0x80000"])
+			},
+		},
+		{
+			name: "smart selection rules",
+			key:  "smart selection rules",
+			value: []interface{}{
+				map[string]interface{}{"regex": "JIRA-\\d+", "precision": "high"},
+			},
+			check: func(p Profile) {
+				assert.Equal(t, []SmartSelectionRule{{Regex: "JIRA-\\d+", Precision: "high"}}, p.SmartSelectionRules)
+			},
+		},
+		{
+			name:  "known unmodelled key",
+			key:   "working directory",
+			value: "/tmp",
+			check: func(p Profile) { assert.Equal(t, "/tmp", p.Extra["Working Directory"]) },
+		},
+		{
+			name:  "unknown key",
+			key:   "Some Future Key",
+			value: map[string]interface{}{"Nested": 1},
+			check: func(p Profile) {
+				assert.Equal(t, map[string]interface{}{"Nested": 1}, p.Extra["Some Future Key"])
+			},
+		},
+		{name: "wrong type", key: "Unlimited Scrollback", value: "yes", err: true},
+		{name: "wrong integer", key: "Columns", value: 1.5, err: true},
+		{name: "unknown field in rule", key: "Smart Selection Rules", value: []interface{}{map[string]interface{}{"foo": 1}}, err: true},
+		{name: "invalid color", key: "Cursor Color", value: "red", err: true},
+		{name: "read only", key: "Guid", value: "foo", err: true},
+	}
+
+	for _, test := range cases {
+		p := Profile{}
+
+		err := p.Set(test.key, test.value)
+		if test.err {
+			assert.Error(t, err, test.name)
+			continue
+		}
+
+		assert.NoError(t, err, test.name)
+		test.check(p)
+	}
+}
+
+func TestMarshalExtra(t *testing.T) {
+	p := Profile{Name: "foo"}
+	assert.NoError(t, p.Set("Working Directory", "/tmp"))
+	assert.NoError(t, p.Set("Zzz Unknown", []interface{}{1, "a"}))
+
+	data, err := json.Marshal(p)
+	assert.NoError(t, err)
+
+	var out map[string]interface{}
+	assert.NoError(t, json.Unmarshal(data, &out))
+	assert.Equal(t, "foo", out["Name"])
+	assert.Equal(t, "/tmp", out["Working Directory"])
+	assert.Equal(t, []interface{}{float64(1), "a"}, out["Zzz Unknown"])
+	assert.True(t, strings.HasPrefix(string(data), `{"Allow Title Setting"`))
+}
+
+func TestUnmarshalExtra(t *testing.T) {
+	in := heredoc.Doc(`
+		{
+			"Name": "foo",
+			"Guid": "foo",
+			"Link Color": {"Red Component": 1, "Color Space": "sRGB"},
+			"Status Bar Layout": {"components": [{"class": "iTermStatusBarClockComponent"}]},
+			"Some Future Key": 12345678901234567890,
+			"Automatically Log": true
+		}
+	`)
+
+	var p Profile
+	assert.NoError(t, json.Unmarshal([]byte(in), &p))
+	assert.Equal(t, "foo", p.Name)
+	assert.Len(t, p.Extra, 4)
+
+	link, ok := p.Color("Link Color")
+	assert.True(t, ok)
+	assert.Equal(t, 1.0, link.RedComponent)
+
+	_, ok = p.Color("Tab Color")
+	assert.False(t, ok)
+
+	log, ok := p.Bool("automatically log")
+	assert.True(t, ok)
+	assert.True(t, log)
+
+	name, ok := p.StringValue("Name")
+	assert.True(t, ok)
+	assert.Equal(t, "foo", name)
+
+	_, ok = p.Int("Columns")
+	assert.False(t, ok)
+
+	data, err := json.Marshal(p)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"Some Future Key":12345678901234567890`)
+
+	var expected, actual map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(in), &expected))
+	assert.NoError(t, json.Unmarshal(data, &actual))
+
+	for k, v := range expected {
+		assert.Equal(t, v, actual[k], k)
+	}
+}
+
+func TestKeyCode(t *testing.T) {
+	cases := []struct {
+		name     string
+		notation string
+		expected string
+		err      bool
+	}{
+		{name: "alt+a", notation: "alt+a", expected: KeyboardSortcutAltA},
+		{name: "case and aliases", notation: "Option+A", expected: KeyboardSortcutAltA},
+		{name: "split", notation: "cmd+shift+|", expected: "0x7c-0x120000"},
+		{name: "arrow", notation: "ctrl+left", expected: "0xf702-0x240000"},
+		{name: "function key", notation: "f5", expected: "0xf708-0x800000"},
+		{name: "plus key", notation: "cmd++", expected: "0x2b-0x100000"},
+		{name: "named key", notation: "cmd+period", expected: "0x2e-0x100000"},
+		{name: "unknown modifier", notation: "hyper+a", err: true},
+		{name: "duplicate modifier", notation: "alt+opt+a", err: true},
+		{name: "unknown key", notation: "cmd+foo", err: true},
+		{name: "empty", notation: "", err: true},
+	}
+
+	for _, test := range cases {
+		code, err := KeyCode(test.notation)
+		if test.err {
+			assert.Error(t, err, test.name)
+			continue
+		}
+
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, test.expected, code, test.name)
+	}
+}
+
+func TestParseBinding(t *testing.T) {
+	cases := []struct {
+		name     string
+		binding  interface{}
+		expected KeyboardMap
+		err      bool
+	}{
+		{
+			name:     "send text",
+			binding:  map[string]interface{}{"send_text": "ls\n"},
+			expected: KeyboardMap{Action: KeyboardSendText, Text: "ls\n"},
+		},
+		{
+			name:     "action without argument",
+			binding:  "split_vertical",
+			expected: KeyboardMap{Action: KeyboardSelectMenuItem, Text: "Split Vertically with Current Profile\nSplit Vertically with Current Profile"},
+		},
+		{
+			name:     "menu item",
+			binding:  map[string]interface{}{"menu": "Detach"},
+			expected: KeyboardMap{Action: KeyboardSelectMenuItem, Text: "Detach\nDetach"},
+		},
+		{
+			name:     "raw action",
+			binding:  map[interface{}]interface{}{"action": 13, "text": ""},
+			expected: KeyboardMap{Action: 13},
+		},
+		{name: "unknown action", binding: "explode", err: true},
+		{name: "missing argument", binding: "send_text", err: true},
+		{name: "unexpected argument", binding: map[string]interface{}{"next_pane": "x"}, err: true},
+		{name: "several actions", binding: map[string]interface{}{"send_text": "a", "menu": "b"}, err: true},
+		{name: "invalid raw action", binding: map[string]interface{}{"action": "foo"}, err: true},
+		{name: "invalid type", binding: 12, err: true},
+	}
+
+	for _, test := range cases {
+		km, err := ParseBinding(test.binding)
+		if test.err {
+			assert.Error(t, err, test.name)
+			continue
+		}
+
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, test.expected, km, test.name)
+	}
+}
+
+func TestBindings(t *testing.T) {
+	maps, err := Bindings{
+		"alt+a":       map[string]interface{}{"send_text": "aws sso login\n"},
+		"cmd+w":       "ignore",
+		"hyper+a":     "ignore",
+		"cmd+shift+x": "explode",
+	}.Compile()
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "hyper")
+	assert.Contains(t, err.Error(), "key cmd+shift+x")
+	assert.Equal(t, map[string]KeyboardMap{
+		KeyboardSortcutAltA: {Action: KeyboardSendText, Text: "aws sso login\n"},
+		"0x77-0x100000":     {Action: 13},
+	}, maps)
+
+	p := Profile{KeyboardMap: map[string]KeyboardMap{
+		"0x77-0x100000-0xd": {Action: KeyboardSendText, Text: "exit"},
+		KeyboardSortcutAltA: {Action: KeyboardSendText, Text: "ls"},
+	}}
+
+	p.Bind("0x77-0x100000", maps["0x77-0x100000"])
+	assert.Equal(t, map[string]KeyboardMap{
+		"0x77-0x100000":     {Action: 13},
+		KeyboardSortcutAltA: {Action: KeyboardSendText, Text: "ls"},
+	}, p.KeyboardMap)
+}
+
+func TestResolveProfiles(t *testing.T) {
+	prof := Profiles{Profiles: []Profile{
+		{
+			Name: "foo",
+			GUID: "guid-foo",
+			KeyboardMap: map[string]KeyboardMap{
+				KeyboardSortcutAltA: {Action: KeyboardSplitHorizontallyProfile, Text: "login-foo"},
+				"0x62-0x80000":      {Action: KeyboardSendText, Text: "login-foo"},
+				"0x63-0x80000":      {Action: KeyboardSplitHorizontallyProfile, Text: "missing"},
+			},
+		},
+		{Name: "login-foo", GUID: "guid-login"},
+	}}
+
+	prof.ResolveProfiles()
+
+	maps := prof.Profiles[0].KeyboardMap
+	assert.Equal(t, "guid-login", maps[KeyboardSortcutAltA].Text)
+	assert.Equal(t, "login-foo", maps["0x62-0x80000"].Text)
+	assert.Equal(t, "missing", maps["0x63-0x80000"].Text)
+}
@@ -0,0 +1,362 @@
+package iterm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// known lists the iTerm2 profile keys that germ does not model in Profile,
+// with the type of their value. They are type checked by Set and stored in
+// Profile.Extra.
+var known = map[string]reflect.Type{
+	"Answerback String":           reflect.TypeOf(""),
+	"Background Image Location":   reflect.TypeOf(""),
+	"Blend":                       reflect.TypeOf(float64(0)),
+	"Blinking Cursor":             reflect.TypeOf(false),
+	"Blur":                        reflect.TypeOf(false),
+	"Blur Radius":                 reflect.TypeOf(float64(0)),
+	"Bold Color":                  reflect.TypeOf(Color{}),
+	"Character Encoding":          reflect.TypeOf(int64(0)),
+	"Close Sessions On End":       reflect.TypeOf(false),
+	"Columns":                     reflect.TypeOf(int64(0)),
+	"Cursor Guide Color":          reflect.TypeOf(Color{}),
+	"Cursor Type":                 reflect.TypeOf(int64(0)),
+	"Disable Window Resizing":     reflect.TypeOf(false),
+	"Dynamic Profile Parent Name": reflect.TypeOf(""),
+	"Horizontal Spacing":          reflect.TypeOf(float64(0)),
+	"Idle Code":                   reflect.TypeOf(int64(0)),
+	"Jobs to Ignore":              reflect.TypeOf([]string{}),
+	"Link Color":                  reflect.TypeOf(Color{}),
+	"Mouse Reporting":             reflect.TypeOf(false),
+	"Non Ascii Font":              reflect.TypeOf(""),
+	"Option Key Sends":            reflect.TypeOf(int64(0)),
+	"Prompt Before Closing 2":     reflect.TypeOf(int64(0)),
+	"Right Option Key Sends":      reflect.TypeOf(int64(0)),
+	"Rows":                        reflect.TypeOf(int64(0)),
+	"Scrollback Lines":            reflect.TypeOf(int64(0)),
+	"Selected Text Color":         reflect.TypeOf(Color{}),
+	"Selection Color":             reflect.TypeOf(Color{}),
+	"Send Code When Idle":         reflect.TypeOf(false),
+	"Sync Title":                  reflect.TypeOf(false),
+	"Terminal Type":               reflect.TypeOf(""),
+	"Use Bold Font":               reflect.TypeOf(false),
+	"Use Cursor Guide":            reflect.TypeOf(false),
+	"Use Italic Font":             reflect.TypeOf(false),
+	"Use Non-ASCII Font":          reflect.TypeOf(false),
+	"Vertical Spacing":            reflect.TypeOf(float64(0)),
+	"Visual Bell":                 reflect.TypeOf(false),
+	"Working Directory":           reflect.TypeOf(""),
+	"Use Separate Colors for Light and Dark Mode": reflect.TypeOf(false),
+	"Show Status Bar":   reflect.TypeOf(false),
+	"Status Bar Layout": reflect.TypeOf(map[string]interface{}{}),
+	"Automatically Log": reflect.TypeOf(false),
+	"Log Directory":     reflect.TypeOf(""),
+	"Logging Style":     reflect.TypeOf(int64(0)),
+}
+
+// readOnly are the keys germ manages itself.
+var readOnly = map[string]struct{}{
+	"Guid": {},
+	"Name": {},
+}
+
+// fields maps the json names of the Profile fields to their index.
+var fields = func() map[string]int {
+	ret := map[string]int{}
+
+	t := reflect.TypeOf(Profile{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			ret[name] = i
+		}
+	}
+
+	return ret
+}()
+
+// fieldIndex returns the index of the Profile field with the given json
+// name, or -1.
+func fieldIndex(key string) int {
+	if i, ok := fields[key]; ok {
+		return i
+	}
+
+	return -1
+}
+
+// Key returns the canonical name of an iTerm2 profile key, matched case
+// insensitively since germ.yaml keys are lower cased, and whether germ knows
+// its type.
+func Key(key string) (string, bool) {
+	for name := range fields {
+		if strings.EqualFold(name, key) {
+			return name, true
+		}
+	}
+
+	for name := range known {
+		if strings.EqualFold(name, key) {
+			return name, true
+		}
+	}
+
+	return key, false
+}
+
+// Set sets the iTerm2 profile key to value. The value is checked against
+// the type of the key, colors can also be given as #rrggbb. Unknown keys are
+// stored untouched in Extra.
+func (p *Profile) Set(key string, value interface{}) error {
+	name, ok := Key(key)
+	if !ok {
+		if p.Extra == nil {
+			p.Extra = map[string]interface{}{}
+		}
+
+		p.Extra[key] = value
+
+		return nil
+	}
+
+	if _, ok := readOnly[name]; ok {
+		return fmt.Errorf("%s cannot be set", name)
+	}
+
+	idx := fieldIndex(name)
+
+	typ := known[name]
+	if idx >= 0 {
+		typ = reflect.TypeOf(*p).Field(idx).Type
+	}
+
+	v, err := convert(value, typ)
+	if err != nil {
+		return errors.Wrapf(err, "invalid value for %s", name)
+	}
+
+	if idx >= 0 {
+		reflect.ValueOf(p).Elem().Field(idx).Set(v)
+		return nil
+	}
+
+	if p.Extra == nil {
+		p.Extra = map[string]interface{}{}
+	}
+
+	p.Extra[name] = v.Interface()
+
+	return nil
+}
+
+// convert checks that value, as decoded from YAML, fits typ.
+func convert(value interface{}, typ reflect.Type) (reflect.Value, error) {
+	if typ == reflect.TypeOf(Color{}) || typ == reflect.TypeOf(&Color{}) {
+		if hex, ok := value.(string); ok {
+			c, err := ParseColor(hex)
+			if typ.Kind() == reflect.Ptr {
+				return reflect.ValueOf(&c), err
+			}
+
+			return reflect.ValueOf(c), err
+		}
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	ret := reflect.New(typ)
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	err = dec.Decode(ret.Interface())
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("expected %s, got %s", typ, data)
+	}
+
+	return ret.Elem(), nil
+}
+
+// profile avoids the recursion of MarshalJSON.
+type profile Profile
+
+// MarshalJSON adds the Extra keys to the profile.
+func (p Profile) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(profile(p))
+	if err != nil || len(p.Extra) == 0 {
+		return data, err
+	}
+
+	var keys []string
+	for k := range p.Extra {
+		if fieldIndex(k) >= 0 {
+			continue
+		}
+
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	buf.Write(data[:len(data)-1])
+
+	for _, k := range keys {
+		key, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := json.Marshal(p.Extra[k])
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot marshal %s", k)
+		}
+
+		buf.WriteByte(',')
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON keeps the keys that are not modelled by Profile in Extra, as
+// decoded from JSON, so they are written back untouched. Use the typed
+// accessors, like Color, to read them.
+func (p *Profile) UnmarshalJSON(data []byte) error {
+	var base profile
+
+	err := json.Unmarshal(data, &base)
+	if err != nil {
+		return err
+	}
+
+	var all map[string]json.RawMessage
+
+	err = json.Unmarshal(data, &all)
+	if err != nil {
+		return err
+	}
+
+	*p = Profile(base)
+	p.Extra = nil
+
+	for key, raw := range all {
+		if name, ok := Key(key); ok && fieldIndex(name) >= 0 {
+			continue
+		}
+
+		if p.Extra == nil {
+			p.Extra = map[string]interface{}{}
+		}
+
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+
+		var v interface{}
+
+		err = dec.Decode(&v)
+		if err != nil {
+			return errors.Wrapf(err, "cannot decode %s", key)
+		}
+
+		p.Extra[key] = v
+	}
+
+	return nil
+}
+
+// Get returns the value of an iTerm2 key, modelled or not.
+func (p Profile) Get(key string) (interface{}, bool) {
+	name, _ := Key(key)
+
+	if i := fieldIndex(name); i >= 0 {
+		v := reflect.ValueOf(p).Field(i)
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			return nil, false
+		}
+
+		return v.Interface(), true
+	}
+
+	v, ok := p.Extra[name]
+	if !ok {
+		v, ok = p.Extra[key]
+	}
+
+	return v, ok
+}
+
+// Value decodes the value of an iTerm2 key into out. It returns false if the
+// key is not set.
+func (p Profile) Value(key string, out interface{}) (bool, error) {
+	v, ok := p.Get(key)
+	if !ok {
+		return false, nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return true, errors.Wrapf(err, "cannot marshal %s", key)
+	}
+
+	err = json.Unmarshal(data, out)
+	if err != nil {
+		return true, errors.Wrapf(err, "invalid value for %s", key)
+	}
+
+	return true, nil
+}
+
+// StringValue returns the value of a string key, named so it is not taken
+// for fmt.Stringer.
+func (p Profile) StringValue(key string) (string, bool) {
+	var ret string
+	ok, err := p.Value(key, &ret)
+
+	return ret, ok && err == nil
+}
+
+// Bool returns the value of a boolean key.
+func (p Profile) Bool(key string) (bool, bool) {
+	var ret bool
+	ok, err := p.Value(key, &ret)
+
+	return ret, ok && err == nil
+}
+
+// Int returns the value of an integer key.
+func (p Profile) Int(key string) (int64, bool) {
+	var ret int64
+	ok, err := p.Value(key, &ret)
+
+	return ret, ok && err == nil
+}
+
+// Float returns the value of a number key.
+func (p Profile) Float(key string) (float64, bool) {
+	var ret float64
+	ok, err := p.Value(key, &ret)
+
+	return ret, ok && err == nil
+}
+
+// Color returns the value of a color key.
+func (p Profile) Color(key string) (Color, bool) {
+	var ret Color
+	ok, err := p.Value(key, &ret)
+
+	return ret, ok && err == nil
+}
@@ -6,10 +6,13 @@ import (
 	"os"
 
 	"github.com/mitchellh/go-homedir"
-	"github.com/rs/zerolog/log"
+	"github.com/pkg/errors"
 )
 
-func SmartSelectionRules(custom string) []SmartSelectionRule {
+// UserSSR is the file with the user defined smart selection rules.
+const UserSSR = "~/.germ.ssr.json"
+
+func SmartSelectionRules(custom string) ([]SmartSelectionRule, error) {
 	ssr := []SmartSelectionRule{
 		{
 			Notes:     "gitlab terraform source",
@@ -205,33 +208,32 @@ func SmartSelectionRules(custom string) []SmartSelectionRule {
 		},
 	}
 
-	return append(ssr, loadUserSSR(custom)...)
+	user, err := loadUserSSR(custom)
+
+	return append(ssr, user...), err
 }
 
-func loadUserSSR(path string) []SmartSelectionRule {
+func loadUserSSR(path string) ([]SmartSelectionRule, error) {
 	userSsr, err := homedir.Expand(path)
 	if err != nil {
-		log.Fatal().Err(err).Msg("cannot expand path")
+		return nil, errors.Wrap(err, "cannot expand path")
 	}
 
 	if _, err := os.Stat(userSsr); os.IsNotExist(err) {
-		return []SmartSelectionRule{}
+		return []SmartSelectionRule{}, nil
 	}
 
 	bytes, err := ioutil.ReadFile(userSsr)
 	if err != nil {
-		log.Fatal().Err(err).Str("userSsr", userSsr).Msg("cannot read file")
+		return nil, errors.Wrapf(err, "cannot read file %s", userSsr)
 	}
 
 	var userSSRs []SmartSelectionRule
 
 	err = json.Unmarshal(bytes, &userSSRs)
 	if err != nil {
-		log.Fatal().Err(err).
-			Str("userSsr", userSsr).
-			Str("string(bytes)", string(bytes)).
-			Msg("cannot parse json file")
+		return nil, errors.Wrapf(err, "cannot parse smart selection rules from %s", userSsr)
 	}
 
-	return userSSRs
+	return userSSRs, nil
 }
@@ -4,10 +4,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/mitchellh/go-homedir"
-	"github.com/rs/zerolog/log"
+	"github.com/pkg/errors"
 	"github.com/spf13/viper"
 )
 
@@ -15,36 +16,55 @@ func notFound(name string) string {
 	return fmt.Sprintf("^(bash|/bin/sh): %s: (command )?not found", name)
 }
 
-func profileTriggers(profile string) []Trigger {
-	file, err := homedir.Expand(fmt.Sprintf("~/.germ.trigger.%s.json", profile))
+// UserTriggers is the file with the user defined triggers of a profile.
+const UserTriggers = "~/.germ.trigger.%s.json"
+
+// profileTriggers loads the user defined triggers for a profile from
+// UserTriggers.
+func profileTriggers(profile string) ([]Trigger, error) {
+	file, err := homedir.Expand(fmt.Sprintf(UserTriggers, profile))
 	if err != nil {
-		return []Trigger{}
+		return []Trigger{}, nil
 	}
 
 	data, err := os.ReadFile(file)
 	if err != nil {
-		return []Trigger{}
+		return []Trigger{}, nil
 	}
 
 	var ret []Trigger
 
 	err = json.Unmarshal(data, &ret)
 	if err != nil {
-		panic(err)
+		return []Trigger{}, errors.Wrapf(err, "cannot parse triggers file %s", file)
 	}
 
-	return ret
+	return ret, nil
 }
 
-func Triggers(profile string) []Trigger {
+// Triggers returns the default triggers followed by the user defined triggers
+// of profile.
+func Triggers(profile string) ([]Trigger, error) {
+	ret, err := defaultTriggers()
+	if err != nil {
+		return nil, err
+	}
+
+	custom, err := profileTriggers(profile)
+
+	return append(ret, custom...), err
+}
+
+// defaultTriggers returns the triggers added to every profile.
+func defaultTriggers() ([]Trigger, error) {
 	idRsa, err := homedir.Expand("~/.ssh/id_rsa")
 	if err != nil {
-		log.Panic().Err(err).Msg("cannot expand ~/")
+		return nil, errors.Wrap(err, "cannot expand ~/")
 	}
 
 	idEd, err := homedir.Expand("~/.ssh/id_ed25519")
 	if err != nil {
-		log.Panic().Err(err).Msg("cannot expand ~/")
+		return nil, errors.Wrap(err, "cannot expand ~/")
 	}
 
 	var ret []Trigger
@@ -57,7 +77,7 @@ func Triggers(profile string) []Trigger {
 		})
 	}
 
-	return append(ret, []Trigger{
+	ret = append(ret, []Trigger{
 		{
 			Regex:     "^# timed out waiting for input: auto-logout",
 			Action:    "SendTextTrigger",
@@ -101,6 +121,8 @@ func Triggers(profile string) []Trigger {
 		// 	Regex:     "^To push the current branch and set the remote as upstream",
 		// },
 	}...)
+
+	return ret, nil
 }
 
 func yum(name string) string {
@@ -128,3 +150,30 @@ func apt(name string) string {
 
 	return strings.Join(commands, " || ")
 }
+
+// allProfileTriggers loads the user defined triggers of every profile with a
+// UserTriggers file, by profile name. Files that cannot be parsed are
+// reported in errs.
+func allProfileTriggers() (map[string][]Trigger, map[string]error) {
+	ret := map[string][]Trigger{}
+	errs := map[string]error{}
+
+	pattern, err := homedir.Expand(fmt.Sprintf(UserTriggers, "*"))
+	if err != nil {
+		return ret, errs
+	}
+
+	matches, _ := filepath.Glob(pattern)
+	prefix, suffix, _ := strings.Cut(pattern, "*")
+
+	for _, path := range matches {
+		name := strings.TrimSuffix(strings.TrimPrefix(path, prefix), suffix)
+
+		ret[name], err = profileTriggers(name)
+		if err != nil {
+			errs[name] = err
+		}
+	}
+
+	return ret, errs
+}
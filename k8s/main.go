@@ -3,6 +3,7 @@ package k8s
 import (
 	"bytes"
 	"context"
+	stderrors "errors"
 	"fmt"
 	"io/ioutil"
 	"os/exec"
@@ -13,6 +14,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/eks"
 	"github.com/mhristof/germ/iterm"
 	"github.com/mhristof/germ/profile"
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 	"gopkg.in/yaml.v2"
 )
@@ -52,10 +54,31 @@ func (k *KubeConfig) GetCluster(name string) (*KubeConfig, bool) {
 	return &config, found
 }
 
-func Profiles(config string, dry bool) []iterm.Profile {
-	clusters := Load(config)
+// Source generates a profile for every cluster in a kubeconfig file.
+type Source struct {
+	Config string
+	DryRun bool
+}
+
+func (s *Source) Name() string {
+	return "k8s"
+}
+
+func (s *Source) Inputs() []string {
+	return []string{s.Config}
+}
 
-	return clusters.Profiles(filepath.Dir(config), dry)
+func (s *Source) Generate(ctx context.Context) ([]iterm.Profile, error) {
+	return Profiles(iterm.GenerationFrom(ctx), s.Config, s.DryRun)
+}
+
+func Profiles(gen *iterm.Generation, config string, dry bool) ([]iterm.Profile, error) {
+	clusters, err := Load(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return clusters.Profiles(gen, filepath.Dir(config), dry)
 }
 
 func GenerateK8sFromAWS(profile string) {
@@ -102,44 +125,74 @@ func GenerateK8sFromAWS(profile string) {
 	}
 }
 
-func (k *KubeConfig) Profiles(dest string, dry bool) []iterm.Profile {
+func (k *KubeConfig) Profiles(gen *iterm.Generation, dest string, dry bool) ([]iterm.Profile, error) {
 	var ret []iterm.Profile
+	var errs []error
 
 	for _, cluster := range k.Clusters {
 		this, found := k.GetCluster(cluster.Name)
 		if !found {
-			log.Fatal().Str("cluster.Name", cluster.Name).Msg("cluster not found")
+			errs = append(errs, fmt.Errorf("cluster %s not found", cluster.Name))
+			continue
 		}
 
-		path := fmt.Sprintf("dry/run/path/%s", this.name())
+		path := fmt.Sprintf("dry/run/path/%s", cluster.Name)
 		if !dry {
-			path = this.Print(dest)
+			var err error
+
+			path, err = this.Print(dest)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		}
+
+		profile, err := this.Profile(gen, path)
+		if err != nil {
+			errs = append(errs, err)
+		}
+
+		if profile != nil {
+			ret = append(ret, *profile)
 		}
-		profile := this.Profile(path)
-		ret = append(ret, *profile)
 	}
 
-	return ret
+	return ret, stderrors.Join(errs...)
 }
 
-func (k *KubeConfig) name() string {
+// single returns an error unless the config holds exactly one cluster
+func (k *KubeConfig) single() error {
 	if len(k.Clusters) != 1 {
-		log.Fatal().Int("len(k.Clusters)", len(k.Clusters)).Msg("cannot handle multuiple cluster defintions")
+		return fmt.Errorf("cannot handle %d cluster definitions, expected 1", len(k.Clusters))
 	}
 
-	return k.Clusters[0].Name
+	return nil
 }
 
-func (k *KubeConfig) Profile(path string) *iterm.Profile {
-	if len(k.Clusters) != 1 {
-		log.Fatal().Int("len(k.Clusters)", len(k.Clusters)).Msg("cannot handle multiple cluster definitions")
+func (k *KubeConfig) Profile(gen *iterm.Generation, path string) (*iterm.Profile, error) {
+	if err := k.single(); err != nil {
+		return nil, err
 	}
 
 	name := filepath.Base(k.Clusters[0].Name)
 	awsProfile := k.AWSProfile()
 	
-	builder := profile.NewK8sProfileBuilder(name).
-		WithKubeConfig(path)
+	builder := profile.NewK8sProfileBuilder(name)
+	builder.WithGeneration(gen)
+	// EKS names the clusters after their ARN
+	builder.WithIdentity("k8s:" + k.Clusters[0].Name)
+	builder.WithMetadata("cluster", name)
+
+	if len(k.Contexts) > 0 {
+		builder.WithMetadata("namespace", k.Contexts[0].Context.Namespace)
+	}
+
+	if arn := strings.Split(k.Clusters[0].Name, ":"); len(arn) == 6 && arn[0] == "arn" {
+		builder.WithMetadata("region", arn[3])
+		builder.WithMetadata("account", arn[4])
+	}
+
+	builder.WithKubeConfig(path)
 	
 	if awsProfile != "" {
 		builder.WithAWSProfile(awsProfile)
@@ -148,11 +201,9 @@ func (k *KubeConfig) Profile(path string) *iterm.Profile {
 	return builder.Build()
 }
 
+// AWSProfile returns the AWS_PROFILE used by the first user of the config,
+// if any.
 func (k *KubeConfig) AWSProfile() string {
-	if len(k.Clusters) != 1 {
-		log.Fatal().Int("len(k.Clusters)", len(k.Clusters)).Msg("cannot handle multiple clusters")
-	}
-
 	if len(k.Users) < 1 {
 		return ""
 	}
@@ -165,29 +216,33 @@ func (k *KubeConfig) AWSProfile() string {
 	return ""
 }
 
-func Load(config string) *KubeConfig {
+func Load(config string) (*KubeConfig, error) {
 	var kConfig KubeConfig
 
 	yamlBytes, err := ioutil.ReadFile(config)
 	if err != nil {
 		log.Warn().Err(err).Str("config", config).Msg("cannot read file")
-		return &kConfig
+		return &kConfig, nil
 	}
 
 	err = yaml.Unmarshal(yamlBytes, &kConfig)
 	if err != nil {
-		log.Fatal().Err(err).Str("config", config).Msg("cannot unmarshal yaml bytes from config")
+		return &kConfig, errors.Wrapf(err, "cannot unmarshal yaml bytes from %s", config)
 	}
 
-	return &kConfig
+	return &kConfig, nil
 }
 
-func (k *KubeConfig) Print(dest string) string {
-	if len(k.Clusters) != 1 {
-		log.Fatal().Int("len(k.Clusters)", len(k.Clusters)).Msg("cannot handle multiple cluster defintions")
+func (k *KubeConfig) Print(dest string) (string, error) {
+	if err := k.single(); err != nil {
+		return "", err
 	}
 
 	bytes, err := yaml.Marshal(k)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot marshal cluster %s", k.Clusters[0].Name)
+	}
+
 	destFile := fmt.Sprintf("%s/%s.yml", dest,
 		strings.ReplaceAll(
 			strings.ReplaceAll(k.Clusters[0].Name, "/", "-"),
@@ -195,24 +250,30 @@ func (k *KubeConfig) Print(dest string) string {
 	)
 	err = ioutil.WriteFile(destFile, bytes, 0o600)
 	if err != nil {
-		log.Fatal().Err(err).Str("destFile", destFile).Msg("cannot write to file")
+		return "", errors.Wrapf(err, "cannot write to file %s", destFile)
 	}
 
-	return destFile
+	return destFile, nil
 }
 
-func (k *KubeConfig) SplitFiles(dest string) {
+func (k *KubeConfig) SplitFiles(dest string) error {
 	for _, cluster := range k.Clusters {
 		this, found := k.GetCluster(cluster.Name)
 		if !found {
-			log.Fatal().Str("cluster.Name", cluster.Name).Msg("cluster not found")
+			return fmt.Errorf("cluster %s not found", cluster.Name)
 		}
 
 		bytes, err := yaml.Marshal(this)
+		if err != nil {
+			return errors.Wrapf(err, "cannot marshal cluster %s", cluster.Name)
+		}
+
 		destFile := fmt.Sprintf("%s/%s.yml", dest, cluster.Name)
 		err = ioutil.WriteFile(destFile, bytes, 0o644)
 		if err != nil {
-			log.Fatal().Err(err).Str("destFile", destFile).Msg("cannot write to file")
+			return errors.Wrapf(err, "cannot write to file %s", destFile)
 		}
 	}
+
+	return nil
 }
@@ -80,7 +80,8 @@ func TestProfile(t *testing.T) {
 	}
 
 	for _, test := range cases {
-		prof := test.in.Profile("path")
+		prof, err := test.in.Profile(nil, "path")
+		assert.NoError(t, err, test.name)
 		assert.Equal(t, test.command, prof.Command, test.name)
 		// Skip the first tag (which is always "k8s") and any generated unique name tags
 		// Just check that the expected tags are present
@@ -91,6 +92,21 @@ func TestProfile(t *testing.T) {
 	}
 }
 
+func TestProfileMetadata(t *testing.T) {
+	config := &KubeConfig{
+		Clusters: []Cluster{{Name: "arn:aws:eks:eu-west-1:123456789012:cluster/payments"}},
+	}
+	config.Contexts = []Context{{Name: "payments"}}
+	config.Contexts[0].Context.Namespace = "api"
+
+	prof, err := config.Profile(nil, "path")
+	assert.NoError(t, err)
+	assert.Equal(t, "payments", prof.Metadata["cluster"])
+	assert.Equal(t, "api", prof.Metadata["namespace"])
+	assert.Equal(t, "eu-west-1", prof.Metadata["region"])
+	assert.Equal(t, "123456789012", prof.Metadata["account"])
+}
+
 func TestLoadAndSplit(t *testing.T) {
 	cases := []struct {
 		name string
@@ -197,8 +213,9 @@ func TestLoadAndSplit(t *testing.T) {
 			t.Fatal(err)
 		}
 
-		kConfig := Load(config)
-		kConfig.SplitFiles(out)
+		kConfig, err := Load(config)
+		assert.NoError(t, err)
+		assert.NoError(t, kConfig.SplitFiles(out))
 
 		for file, content := range test.out {
 			data, err := ioutil.ReadFile(filepath.Join(out, file))
@@ -211,6 +228,26 @@ func TestLoadAndSplit(t *testing.T) {
 	}
 }
 
+func TestProfilesInvalidConfig(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "config")
+
+	err := ioutil.WriteFile(config, []byte("clusters: [\n"), 0o644)
+	assert.NoError(t, err)
+
+	profiles, err := Profiles(nil, config, true)
+	assert.Error(t, err)
+	assert.Empty(t, profiles)
+}
+
+func TestProfileMultipleClusters(t *testing.T) {
+	k := &KubeConfig{
+		Clusters: []Cluster{{Name: "one"}, {Name: "two"}},
+	}
+
+	_, err := k.Profile(nil, "path")
+	assert.Error(t, err)
+}
+
 func noTabs(in string) string {
 	return strings.Replace(in, "\t", "  ", -1)
 }
@@ -1,11 +1,14 @@
 package keychain
 
 import (
+	"context"
+	stderrors "errors"
 	"fmt"
 	"os/exec"
 	"strings"
 
 	"github.com/mhristof/germ/iterm"
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 )
 
@@ -14,7 +17,7 @@ type KeyChain struct {
 	AccessGroup string
 }
 
-func (k *KeyChain) Add(name, value string) {
+func (k *KeyChain) Add(name, value string) error {
 	// Use the security command-line tool instead of deprecated APIs
 	cmd := exec.Command("security", "add-generic-password", 
 		"-s", k.Service, 
@@ -24,16 +27,18 @@ func (k *KeyChain) Add(name, value string) {
 	
 	err := cmd.Run()
 	if err != nil {
-		log.Fatal().Err(err).Str("name", name).Msg("failed to add keychain item")
+		return errors.Wrapf(err, "failed to add keychain item %s", name)
 	}
+
+	return nil
 }
 
-func (k *KeyChain) List() []string {
+func (k *KeyChain) List() ([]string, error) {
 	// Use security command to list accounts
 	cmd := exec.Command("security", "dump-keychain")
 	output, err := cmd.Output()
 	if err != nil {
-		log.Fatal().Err(err).Str("k.Service", k.Service).Msg("cannot retrieve the accounts")
+		return nil, errors.Wrapf(err, "cannot retrieve the accounts for %s", k.Service)
 	}
 	
 	var accounts []string
@@ -64,26 +69,45 @@ func (k *KeyChain) List() []string {
 		}
 	}
 	
-	return accounts
+	return accounts, nil
 }
 
-func (k *KeyChain) Delete(name string) {
+func (k *KeyChain) Delete(name string) error {
 	log.Debug().Str("name", name).Msg("deleting keychain object")
 
 	cmd := exec.Command("security", "delete-generic-password", "-s", k.Service, "-a", name)
 	err := cmd.Run()
 	if err != nil {
-		log.Fatal().Err(err).Str("name", name).Msg("cannot delete item")
+		return errors.Wrapf(err, "cannot delete item %s", name)
 	}
+
+	return nil
+}
+
+func (k *KeyChain) Name() string {
+	return "keychain"
+}
+
+func (k *KeyChain) Generate(ctx context.Context) ([]iterm.Profile, error) {
+	return k.Profiles(iterm.GenerationFrom(ctx))
 }
 
-func (k *KeyChain) Profiles() []iterm.Profile {
+func (k *KeyChain) Profiles(gen *iterm.Generation) ([]iterm.Profile, error) {
+	accounts, err := k.List()
+	if err != nil {
+		return nil, err
+	}
+
 	var ret []iterm.Profile
-	for _, account := range k.List() {
-		prof := iterm.NewProfile(fmt.Sprintf("custom/%s", account), map[string]string{})
+	var errs []error
+	for _, account := range accounts {
+		prof, err := gen.NewProfile(fmt.Sprintf("custom/%s", account), map[string]string{})
+		if err != nil {
+			errs = append(errs, err)
+		}
 
 		prof.KeyboardMap[iterm.KeyboardSortcutAltA] = iterm.KeyboardMap{
-			Action: 12,
+			Action: iterm.KeyboardSendText,
 			Text:   fmt.Sprintf("eval $(/usr/bin/security find-generic-password  -s %s -w -a %s)", k.Service, account),
 		}
 
@@ -91,5 +115,5 @@ func (k *KeyChain) Profiles() []iterm.Profile {
 
 	}
 
-	return ret
+	return ret, stderrors.Join(errs...)
 }
@@ -53,7 +53,8 @@ func TestKeyChain_Profiles(t *testing.T) {
 				accounts: test.accounts,
 			}
 
-			profiles := k.Profiles()
+			profiles, err := k.Profiles()
+			assert.NoError(t, err)
 			assert.Equal(t, test.expected, len(profiles))
 
 			for i, account := range test.accounts {
@@ -143,10 +144,13 @@ type mockKeyChain struct {
 	accounts []string
 }
 
-func (m *mockKeyChain) Profiles() []iterm.Profile {
+func (m *mockKeyChain) Profiles() ([]iterm.Profile, error) {
 	var ret []iterm.Profile
 	for _, account := range m.accounts {
-		prof := iterm.NewProfile(fmt.Sprintf("custom/%s", account), map[string]string{})
+		prof, err := iterm.NewProfile(fmt.Sprintf("custom/%s", account), map[string]string{})
+		if err != nil {
+			return nil, err
+		}
 
 		prof.KeyboardMap[iterm.KeyboardSortcutAltA] = iterm.KeyboardMap{
 			Action: 12,
@@ -156,7 +160,7 @@ func (m *mockKeyChain) Profiles() []iterm.Profile {
 		ret = append(ret, *prof)
 	}
 
-	return ret
+	return ret, nil
 }
 
 // parseKeychainDump extracts accounts from keychain dump output for testing
@@ -202,6 +206,6 @@ func parseKeychainDump(service, output string) []string {
 	return accounts
 }
 
-func (m *mockKeyChain) List() []string {
-	return m.accounts
+func (m *mockKeyChain) List() ([]string, error) {
+	return m.accounts, nil
 }
\ No newline at end of file
@@ -1,4 +1,4 @@
-package iterm
+package names
 
 var (
 	left = [...]string{
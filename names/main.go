@@ -0,0 +1,356 @@
+// Package names stores the unique docker style name of every profile, for
+// example happy-turing, so people can refer to a shell by a name that does
+// not change between generations.
+package names
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/adrg/xdg"
+	"github.com/mhristof/germ/atomic"
+	"github.com/pkg/errors"
+)
+
+// DefaultPruneAfter is how long the name of a profile that is no longer
+// generated is kept.
+const DefaultPruneAfter = 30 * 24 * time.Hour
+
+// Entry is the unique name of a profile.
+type Entry struct {
+	// Profile is the name of the profile.
+	Profile string `json:"-"`
+	// Name is the unique name.
+	Name string `json:"name"`
+	// Pinned names are never pruned.
+	Pinned bool `json:"pinned,omitempty"`
+	// Seen is the last time the profile was generated.
+	Seen time.Time `json:"seen"`
+}
+
+// Store keeps the unique names in a JSON file. It is safe for concurrent use
+// and Save merges the changes with the ones of other processes.
+type Store struct {
+	Path string
+	// PruneAfter removes the names of the profiles missing from a generation
+	// and not generated for that long, DefaultPruneAfter when zero. Negative
+	// never prunes.
+	PruneAfter time.Duration
+
+	sync.Mutex
+	entries map[string]Entry
+	// previous holds the profiles stored before this process changed them.
+	previous map[string]struct{}
+	// changed and removed are the profiles changed by this process, they
+	// are applied to the file content on Save.
+	changed map[string]struct{}
+	removed map[string]struct{}
+	// generated are the profiles of the generation, nil if unknown, see
+	// Generated.
+	generated map[string]struct{}
+}
+
+// DefaultPath returns the file the names are stored in.
+func DefaultPath() (string, error) {
+	path, err := xdg.ConfigFile("germ-profiles.json")
+
+	return path, errors.Wrap(err, "cannot find the names storage")
+}
+
+// New returns an empty store saved to path.
+func New(path string, pruneAfter time.Duration) *Store {
+	return &Store{
+		Path:       path,
+		PruneAfter: pruneAfter,
+		entries:    map[string]Entry{},
+		previous:   map[string]struct{}{},
+		changed:    map[string]struct{}{},
+		removed:    map[string]struct{}{},
+	}
+}
+
+// Open reads the store in path. A missing file is an empty store, a file
+// that cannot be read or parsed is an error so it is never overwritten.
+func Open(path string, pruneAfter time.Duration) (*Store, error) {
+	s := New(path, pruneAfter)
+
+	entries, err := read(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s.entries = entries
+	for profile := range entries {
+		s.previous[profile] = struct{}{}
+	}
+
+	return s, nil
+}
+
+// read parses the file in path. Files written before the entries were
+// introduced map the profiles directly to their names.
+func read(path string) (map[string]Entry, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return map[string]Entry{}, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read %s", path)
+	}
+
+	ret := map[string]Entry{}
+
+	err = json.Unmarshal(data, &ret)
+	if err != nil {
+		var legacy map[string]string
+		if json.Unmarshal(data, &legacy) != nil {
+			return nil, errors.Wrapf(err, "cannot parse %s", path)
+		}
+
+		now := time.Now()
+		for profile, name := range legacy {
+			ret[profile] = Entry{Name: name, Seen: now}
+		}
+	}
+
+	for profile, entry := range ret {
+		entry.Profile = profile
+		ret[profile] = entry
+	}
+
+	return ret, nil
+}
+
+// Known returns true if the profile had a name before this process changed
+// the store.
+func (s *Store) Known(profile string) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	_, ok := s.previous[profile]
+
+	return ok
+}
+
+// Unique returns the unique name of the profile, picking a new one the first
+// time the profile is seen, and marks the profile as seen.
+func (s *Store) Unique(profile string) string {
+	s.Lock()
+	defer s.Unlock()
+
+	entry, ok := s.entries[profile]
+	if !ok {
+		entry = Entry{Profile: profile, Name: s.random()}
+	}
+
+	entry.Seen = time.Now()
+	s.set(entry)
+
+	return entry.Name
+}
+
+// random returns a name not used by any profile.
+func (s *Store) random() string {
+	inUse := map[string]struct{}{}
+	for _, entry := range s.entries {
+		inUse[entry.Name] = struct{}{}
+	}
+
+	for {
+		name := fmt.Sprintf("%s-%s", left[rand.Intn(len(left))], right[rand.Intn(len(right))])
+
+		if _, ok := inUse[name]; !ok {
+			return name
+		}
+	}
+}
+
+func (s *Store) set(entry Entry) {
+	s.entries[entry.Profile] = entry
+	s.changed[entry.Profile] = struct{}{}
+	delete(s.removed, entry.Profile)
+}
+
+// Generated records all the profiles of a generation: their names are marked
+// as seen, including the profiles that did not ask for their name, like the
+// cached ones, and Save prunes the names of the other profiles.
+func (s *Store) Generated(profiles []string) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.generated = map[string]struct{}{}
+	now := time.Now()
+
+	for _, profile := range profiles {
+		s.generated[profile] = struct{}{}
+
+		if entry, ok := s.entries[profile]; ok {
+			entry.Seen = now
+			s.set(entry)
+		}
+	}
+}
+
+// List returns all the entries sorted by profile.
+func (s *Store) List() []Entry {
+	s.Lock()
+	defer s.Unlock()
+
+	var ret []Entry
+	for _, entry := range s.entries {
+		ret = append(ret, entry)
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Profile < ret[j].Profile
+	})
+
+	return ret
+}
+
+// Find returns the entry of the profile, or of the profile with the unique
+// name.
+func (s *Store) Find(profileOrName string) (Entry, bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.find(profileOrName)
+}
+
+func (s *Store) find(profileOrName string) (Entry, bool) {
+	if entry, ok := s.entries[profileOrName]; ok {
+		return entry, true
+	}
+
+	for _, entry := range s.entries {
+		if entry.Name == profileOrName {
+			return entry, true
+		}
+	}
+
+	return Entry{}, false
+}
+
+// Pin keeps the name of the profile forever, or lets it be pruned again.
+func (s *Store) Pin(profileOrName string, pinned bool) error {
+	s.Lock()
+	defer s.Unlock()
+
+	entry, ok := s.find(profileOrName)
+	if !ok {
+		return fmt.Errorf("unknown profile %s", profileOrName)
+	}
+
+	entry.Pinned = pinned
+	s.set(entry)
+
+	return nil
+}
+
+// Rename gives the profile a new unique name and pins it.
+func (s *Store) Rename(profileOrName, name string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	entry, ok := s.find(profileOrName)
+	if !ok {
+		return fmt.Errorf("unknown profile %s", profileOrName)
+	}
+
+	if other, ok := s.find(name); ok && other.Profile != entry.Profile {
+		return fmt.Errorf("name %s is already used by %s", name, other.Profile)
+	}
+
+	entry.Name = name
+	entry.Pinned = true
+	s.set(entry)
+
+	return nil
+}
+
+// Reset forgets the name of the profile, a new one is picked the next time
+// the profile is generated.
+func (s *Store) Reset(profileOrName string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	entry, ok := s.find(profileOrName)
+	if !ok {
+		return fmt.Errorf("unknown profile %s", profileOrName)
+	}
+
+	delete(s.entries, entry.Profile)
+	delete(s.changed, entry.Profile)
+	s.removed[entry.Profile] = struct{}{}
+
+	return nil
+}
+
+// Save writes the changes of this process to the file. The file is locked
+// and read again so the changes of other processes are kept. After
+// Generated, the names of the profiles missing from the generation and not
+// seen for PruneAfter are removed unless they are pinned.
+func (s *Store) Save() error {
+	s.Lock()
+	defer s.Unlock()
+
+	if len(s.changed) == 0 && len(s.removed) == 0 && s.generated == nil {
+		return nil
+	}
+
+	unlock, err := atomic.Lock(s.Path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	current, err := read(s.Path)
+	if err != nil {
+		return err
+	}
+
+	for profile := range s.changed {
+		current[profile] = s.entries[profile]
+	}
+
+	for profile := range s.removed {
+		delete(current, profile)
+	}
+
+	pruneAfter := s.PruneAfter
+	if pruneAfter == 0 {
+		pruneAfter = DefaultPruneAfter
+	}
+
+	for profile, entry := range current {
+		if s.generated == nil || pruneAfter < 0 || entry.Pinned {
+			continue
+		}
+
+		if _, ok := s.generated[profile]; !ok && time.Since(entry.Seen) > pruneAfter {
+			delete(current, profile)
+		}
+	}
+
+	data, err := json.MarshalIndent(current, "", "    ")
+	if err != nil {
+		return errors.Wrapf(err, "cannot marshal %s", s.Path)
+	}
+
+	err = atomic.WriteFile(s.Path, data, 0o644)
+	if err != nil {
+		return err
+	}
+
+	s.entries = current
+	s.changed = map[string]struct{}{}
+	s.removed = map[string]struct{}{}
+
+	return nil
+}
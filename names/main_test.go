@@ -0,0 +1,148 @@
+package names
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnique(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "names.json")
+
+	s, err := Open(path, -1)
+	assert.NoError(t, err)
+
+	foo := s.Unique("foo")
+	assert.Equal(t, foo, s.Unique("foo"))
+	assert.NotEqual(t, foo, s.Unique("bar"))
+	assert.False(t, s.Known("foo"))
+	assert.NoError(t, s.Save())
+
+	s, err = Open(path, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, foo, s.Unique("foo"))
+	assert.True(t, s.Known("foo"))
+}
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+
+	legacy := filepath.Join(dir, "legacy.json")
+	assert.NoError(t, os.WriteFile(legacy, []byte(`{"foo": "happy-turing"}`), 0o644))
+
+	s, err := Open(legacy, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, "happy-turing", s.Unique("foo"))
+
+	broken := filepath.Join(dir, "broken.json")
+	assert.NoError(t, os.WriteFile(broken, []byte(`{`), 0o644))
+
+	_, err = Open(broken, time.Hour)
+	assert.Error(t, err)
+
+	s, err = Open(filepath.Join(dir, "missing.json"), time.Hour)
+	assert.NoError(t, err)
+	assert.Empty(t, s.List())
+}
+
+func TestSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "names.json")
+	old := time.Now().Add(-48 * time.Hour)
+
+	data, err := json.Marshal(map[string]Entry{
+		"stale":  {Name: "stale-name", Seen: old},
+		"pinned": {Name: "pinned-name", Seen: old, Pinned: true},
+		"fresh":  {Name: "fresh-name", Seen: time.Now()},
+		"cached": {Name: "cached-name", Seen: old},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(path, data, 0o644))
+
+	a, err := Open(path, 24*time.Hour)
+	assert.NoError(t, err)
+
+	b, err := Open(path, 24*time.Hour)
+	assert.NoError(t, err)
+
+	// concurrent processes keep each other's names
+	var wg sync.WaitGroup
+	for profile, s := range map[string]*Store{"a": a, "b": b} {
+		wg.Add(1)
+		go func(s *Store, profile string) {
+			defer wg.Done()
+			s.Unique(profile)
+			// cached profiles exist without asking for their name
+			s.Generated([]string{profile, "cached"})
+			assert.NoError(t, s.Save())
+		}(s, profile)
+	}
+	wg.Wait()
+
+	c, err := Open(path, 24*time.Hour)
+	assert.NoError(t, err)
+
+	var profiles []string
+	for _, e := range c.List() {
+		profiles = append(profiles, e.Profile)
+	}
+
+	assert.Len(t, profiles, 5)
+	assert.Contains(t, profiles, "pinned")
+	assert.Contains(t, profiles, "fresh")
+	assert.Contains(t, profiles, "cached")
+	assert.NotContains(t, profiles, "stale")
+
+	entry, ok := c.Find("cached")
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now(), entry.Seen, time.Minute)
+
+	// names are only pruned against a generation
+	assert.NoError(t, c.Pin("fresh", true))
+	assert.NoError(t, c.Pin("fresh", false))
+	assert.NoError(t, os.WriteFile(path, []byte(`{"stale": {"name": "stale-name", "seen": "2000-01-01T00:00:00Z"}}`), 0o644))
+	assert.NoError(t, c.Save())
+
+	d, err := Open(path, 24*time.Hour)
+	assert.NoError(t, err)
+
+	_, ok = d.Find("stale")
+	assert.True(t, ok)
+}
+
+func TestManage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "names.json")
+
+	s, err := Open(path, time.Hour)
+	assert.NoError(t, err)
+
+	foo := s.Unique("foo")
+	s.Unique("bar")
+
+	assert.NoError(t, s.Pin(foo, true))
+	entry, ok := s.Find("foo")
+	assert.True(t, ok)
+	assert.True(t, entry.Pinned)
+
+	assert.NoError(t, s.Rename("bar", "my-shell"))
+	assert.Error(t, s.Rename("foo", "my-shell"), "names are unique")
+	assert.Error(t, s.Pin("nope", true))
+
+	assert.NoError(t, s.Reset("foo"))
+	assert.NoError(t, s.Save())
+
+	s, err = Open(path, time.Hour)
+	assert.NoError(t, err)
+
+	_, ok = s.Find("foo")
+	assert.False(t, ok)
+
+	entry, ok = s.Find("my-shell")
+	assert.True(t, ok)
+	assert.Equal(t, "bar", entry.Profile)
+	assert.True(t, entry.Pinned)
+}
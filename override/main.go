@@ -0,0 +1,230 @@
+// Package override patches the generated profiles with the `overrides`
+// section of germ.yaml.
+package override
+
+import (
+	stderrors "errors"
+	"fmt"
+	"path"
+	"regexp"
+
+	"github.com/mhristof/germ/iterm"
+	"github.com/mhristof/germ/scheme"
+	"github.com/pkg/errors"
+)
+
+// Selector picks the profiles an override applies to. All the set fields
+// must match, an empty selector matches every profile.
+type Selector struct {
+	// Name is a glob matched against the profile name, for example k8s-*.
+	Name string
+	// Regex is a regular expression matched against the profile name.
+	Regex string
+	// Tag is a glob matched against the profile tags, for example
+	// aws-profile=prod-*.
+	Tag string
+	// Source is the name of the source that generated the profile.
+	Source string
+}
+
+// Patch holds the changes applied to the selected profiles.
+type Patch struct {
+	Font string
+	// Scheme is applied before the colors.
+	Scheme        scheme.Ref
+	Colors        map[string]string
+	Badge         *string
+	Triggers      []iterm.Trigger
+	KeyboardMap   map[string]iterm.KeyboardMap `mapstructure:"keyboard_map"`
+	CommandPrefix string                       `mapstructure:"command_prefix"`
+	// Keys are bound after KeyboardMap, see iterm.Bindings.
+	Keys iterm.Bindings
+	// ITerm sets any iTerm2 profile key, see iterm.Profile.Set.
+	ITerm map[string]interface{} `mapstructure:"iterm"`
+}
+
+// Override is a single entry of the overrides section.
+type Override struct {
+	Match Selector
+	Patch `mapstructure:",squash"`
+}
+
+// Matches returns true if the profile, generated by source, is selected.
+func (s Selector) Matches(p iterm.Profile, source string) (bool, error) {
+	if s.Source != "" && s.Source != source {
+		return false, nil
+	}
+
+	if s.Name != "" {
+		ok, err := path.Match(s.Name, p.Name)
+		if err != nil {
+			return false, errors.Wrapf(err, "invalid name glob %q", s.Name)
+		}
+
+		if !ok {
+			return false, nil
+		}
+	}
+
+	if s.Regex != "" {
+		re, err := regexp.Compile(s.Regex)
+		if err != nil {
+			return false, errors.Wrapf(err, "invalid regex %q", s.Regex)
+		}
+
+		if !re.MatchString(p.Name) {
+			return false, nil
+		}
+	}
+
+	if s.Tag != "" {
+		found := false
+
+		for _, tag := range p.Tags {
+			ok, err := path.Match(s.Tag, tag)
+			if err != nil {
+				return false, errors.Wrapf(err, "invalid tag glob %q", s.Tag)
+			}
+
+			if ok {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// Apply patches the profile.
+func (p Patch) Apply(prof *iterm.Profile) error {
+	var errs []error
+
+	if p.Font != "" {
+		prof.NormalFont = p.Font
+	}
+
+	err := p.Scheme.Apply(prof)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	for name, hex := range p.Colors {
+		color, err := iterm.ParseColor(hex)
+		if err == nil {
+			err = prof.SetColor(name, color)
+		}
+
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if p.Badge != nil {
+		prof.BadgeText = *p.Badge
+	}
+
+	prof.Triggers = append(prof.Triggers, p.Triggers...)
+
+	if len(p.KeyboardMap) > 0 && prof.KeyboardMap == nil {
+		prof.KeyboardMap = map[string]iterm.KeyboardMap{}
+	}
+
+	for key, km := range p.KeyboardMap {
+		prof.KeyboardMap[key] = km
+	}
+
+	maps, err := p.Keys.Compile()
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	for code, km := range maps {
+		prof.Bind(code, km)
+	}
+
+	if p.CommandPrefix != "" && prof.Command != "" {
+		prof.Command = fmt.Sprintf("%s %s", p.CommandPrefix, prof.Command)
+	}
+
+	for key, value := range p.ITerm {
+		err := prof.Set(key, value)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return stderrors.Join(errs...)
+}
+
+// Validate checks the selector patterns and the colors of the override.
+func (o Override) Validate() error {
+	var errs []error
+
+	for _, glob := range []string{o.Match.Name, o.Match.Tag} {
+		if _, err := path.Match(glob, ""); err != nil {
+			errs = append(errs, errors.Wrapf(err, "invalid glob %q", glob))
+		}
+	}
+
+	if _, err := regexp.Compile(o.Match.Regex); err != nil {
+		errs = append(errs, errors.Wrapf(err, "invalid regex %q", o.Match.Regex))
+	}
+
+	if err := o.Scheme.Apply(&iterm.Profile{}); err != nil {
+		errs = append(errs, err)
+	}
+
+	for name, hex := range o.Colors {
+		color, err := iterm.ParseColor(hex)
+		if err == nil {
+			err = (&iterm.Profile{}).SetColor(name, color)
+		}
+
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if _, err := o.Keys.Compile(); err != nil {
+		errs = append(errs, err)
+	}
+
+	for key, value := range o.ITerm {
+		err := (&iterm.Profile{}).Set(key, value)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return stderrors.Join(errs...)
+}
+
+// Apply patches the profiles with the overrides, in order. origin maps the
+// profile names to the source that generated them. Invalid overrides are
+// skipped and reported.
+func Apply(overrides []Override, profiles []iterm.Profile, origin map[string]string) error {
+	var errs []error
+
+	for i, o := range overrides {
+		if err := o.Validate(); err != nil {
+			errs = append(errs, errors.Wrapf(err, "invalid override %d", i))
+			continue
+		}
+
+		for j := range profiles {
+			if ok, _ := o.Match.Matches(profiles[j], origin[profiles[j].Name]); !ok {
+				continue
+			}
+
+			// the override is valid, the patch cannot fail
+			_ = o.Patch.Apply(&profiles[j])
+		}
+	}
+
+	return stderrors.Join(errs...)
+}
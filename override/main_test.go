@@ -0,0 +1,140 @@
+package override
+
+import (
+	"testing"
+
+	"github.com/mhristof/germ/iterm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatches(t *testing.T) {
+	prof := iterm.Profile{
+		Name: "k8s-prod-eu",
+		Tags: []string{"k8s", "aws-profile=prod-admin"},
+	}
+
+	cases := []struct {
+		name     string
+		selector Selector
+		source   string
+		out      bool
+	}{
+		{name: "empty selector", out: true},
+		{name: "name glob", selector: Selector{Name: "k8s-*"}, out: true},
+		{name: "name glob mismatch", selector: Selector{Name: "ssm-*"}},
+		{name: "regex", selector: Selector{Regex: "prod-(eu|us)$"}, out: true},
+		{name: "regex mismatch", selector: Selector{Regex: "^prod"}},
+		{name: "tag", selector: Selector{Tag: "k8s"}, out: true},
+		{name: "tag glob", selector: Selector{Tag: "aws-profile=prod-*"}, out: true},
+		{name: "tag mismatch", selector: Selector{Tag: "ssm"}},
+		{name: "source", selector: Selector{Source: "k8s"}, source: "k8s", out: true},
+		{name: "source mismatch", selector: Selector{Source: "ssm"}, source: "k8s"},
+		{name: "all fields must match", selector: Selector{Name: "k8s-*", Source: "ssm"}, source: "k8s"},
+	}
+
+	for _, test := range cases {
+		ok, err := test.selector.Matches(prof, test.source)
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, test.out, ok, test.name)
+	}
+}
+
+func TestApply(t *testing.T) {
+	badge := "PROD"
+
+	profiles := []iterm.Profile{
+		{Name: "k8s-foo", Command: "kubectl", Tags: []string{"k8s"}},
+		{Name: "prod-ssm", Command: "aws ssm start-session"},
+		{Name: "vim"},
+	}
+
+	origin := map[string]string{
+		"k8s-foo":  "k8s",
+		"prod-ssm": "ssm",
+		"vim":      "vim",
+	}
+
+	overrides := []Override{
+		{
+			Match: Selector{Name: "k8s-*"},
+			Patch: Patch{
+				Font:   "Monaco 14",
+				Colors: map[string]string{"background": "#ff0000"},
+				ITerm: map[string]interface{}{
+					"Show Status Bar":   true,
+					"Some Future Key":   "value",
+					"Automatically Log": true,
+				},
+			},
+		},
+		{
+			Match: Selector{Source: "ssm", Regex: "prod"},
+			Patch: Patch{
+				Badge:         &badge,
+				Triggers:      []iterm.Trigger{{Action: "HighlightTrigger", Regex: "ERROR"}},
+				KeyboardMap:   map[string]iterm.KeyboardMap{"0x61-0x80000": {Action: 12, Text: "ls"}},
+				CommandPrefix: "env AWS_REGION=eu-west-1",
+			},
+		},
+		{
+			Match: Selector{Regex: "("},
+			Patch: Patch{Font: "broken"},
+		},
+		{
+			Patch: Patch{Colors: map[string]string{"background": "red"}},
+		},
+		{
+			Patch: Patch{ITerm: map[string]interface{}{"Columns": "wide"}},
+		},
+	}
+
+	err := Apply(overrides, profiles, origin)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid override 2")
+	assert.Contains(t, err.Error(), "invalid override 3")
+	assert.Contains(t, err.Error(), "invalid override 4")
+
+	assert.Equal(t, "Monaco 14", profiles[0].NormalFont)
+	assert.Equal(t, iterm.Color{ColorSpace: "sRGB", RedComponent: 1, AlphaComponent: 1}, profiles[0].BackgroundColor)
+	assert.Equal(t, "kubectl", profiles[0].Command)
+	assert.Equal(t, "value", profiles[0].Extra["Some Future Key"])
+
+	statusBar, ok := profiles[0].Bool("Show Status Bar")
+	assert.True(t, ok)
+	assert.True(t, statusBar)
+
+	assert.Equal(t, "PROD", profiles[1].BadgeText)
+	assert.Len(t, profiles[1].Triggers, 1)
+	assert.Equal(t, "ls", profiles[1].KeyboardMap["0x61-0x80000"].Text)
+	assert.Equal(t, "env AWS_REGION=eu-west-1 aws ssm start-session", profiles[1].Command)
+
+	assert.Equal(t, iterm.Profile{Name: "vim"}, profiles[2])
+}
+
+func TestApplyKeys(t *testing.T) {
+	profiles := []iterm.Profile{
+		{
+			Name:        "vault",
+			KeyboardMap: map[string]iterm.KeyboardMap{"0x77-0x100000-0xd": {Action: iterm.KeyboardSendText, Text: "exit"}},
+		},
+	}
+
+	overrides := []Override{
+		{
+			Match: Selector{Name: "vault"},
+			Patch: Patch{Keys: iterm.Bindings{"cmd+w": "ignore", "alt+a": map[string]interface{}{"send_text": "ls\n"}}},
+		},
+		{
+			Patch: Patch{Keys: iterm.Bindings{"hyper+w": "ignore"}},
+		},
+	}
+
+	err := Apply(overrides, profiles, map[string]string{"vault": "vault"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid override 1")
+
+	assert.Equal(t, map[string]iterm.KeyboardMap{
+		"0x77-0x100000": {Action: 13},
+		"0x61-0x80000":  {Action: iterm.KeyboardSendText, Text: "ls\n"},
+	}, profiles[0].KeyboardMap)
+}
@@ -1,12 +1,13 @@
 package profile
 
 import (
+	stderrors "errors"
 	"fmt"
 	"os/user"
 	"strings"
 
 	"github.com/mhristof/germ/iterm"
-	"github.com/rs/zerolog/log"
+	"github.com/pkg/errors"
 )
 
 // Builder provides a fluent interface for creating iTerm profiles
@@ -17,6 +18,10 @@ type Builder struct {
 	keyboardMap map[string]iterm.KeyboardMap
 	triggers    []iterm.Trigger
 	boundHosts  []string
+	gen         *iterm.Generation
+	identity    string
+	metadata    map[string]string
+	err         error
 }
 
 // NewBuilder creates a new profile builder with the given name
@@ -28,6 +33,7 @@ func NewBuilder(name string) *Builder {
 		keyboardMap: make(map[string]iterm.KeyboardMap),
 		triggers:    make([]iterm.Trigger, 0),
 		boundHosts:  make([]string, 0),
+		metadata:    make(map[string]string),
 	}
 }
 
@@ -88,6 +94,39 @@ func (b *Builder) WithBoundHosts(hosts ...string) *Builder {
 	return b
 }
 
+// WithGeneration creates the profile with the state shared by all the
+// profiles of a generation
+func (b *Builder) WithGeneration(gen *iterm.Generation) *Builder {
+	b.gen = gen
+	return b
+}
+
+// WithIdentity sets what the profile connects to, its GUID is derived from
+// it
+func (b *Builder) WithIdentity(identity string) *Builder {
+	b.identity = identity
+	return b
+}
+
+// WithMetadata describes the profile to the badge templates, empty values
+// are ignored
+func (b *Builder) WithMetadata(key, value string) *Builder {
+	b.metadata[key] = value
+	return b
+}
+
+// currentUser returns the name of the current user and records an error in
+// the builder if it cannot be found
+func (b *Builder) currentUser() string {
+	current, err := user.Current()
+	if err != nil {
+		b.err = stderrors.Join(b.err, errors.Wrap(err, "cannot find current user"))
+		return ""
+	}
+
+	return current.Username
+}
+
 // WithPrefix adds a prefix to the profile name
 func (b *Builder) WithPrefix(prefix string) *Builder {
 	if prefix != "" {
@@ -96,9 +135,16 @@ func (b *Builder) WithPrefix(prefix string) *Builder {
 	return b
 }
 
-// Build creates the final iTerm profile
-func (b *Builder) Build() *iterm.Profile {
-	profile := iterm.NewProfile(b.name, b.config)
+// Build creates the final iTerm profile. The profile is returned even when
+// some of its settings failed, together with the error describing them.
+func (b *Builder) Build() (*iterm.Profile, error) {
+	profile, err := b.gen.NewProfile(b.name, b.config)
+	err = stderrors.Join(b.err, err)
+	profile.Identity = b.identity
+
+	for key, value := range b.metadata {
+		profile.SetMetadata(key, value)
+	}
 	
 	// Add additional tags if any were specified
 	if len(b.tags) > 0 {
@@ -125,7 +171,7 @@ func (b *Builder) Build() *iterm.Profile {
 		profile.BoundHosts = b.boundHosts
 	}
 	
-	return profile
+	return profile, err
 }
 
 // AWSProfileBuilder provides AWS-specific profile building functionality
@@ -142,13 +188,11 @@ func NewAWSProfileBuilder(name string) *AWSProfileBuilder {
 
 // WithAWSProfile sets up the profile with AWS_PROFILE environment variable
 func (b *AWSProfileBuilder) WithAWSProfile(awsProfile string) *AWSProfileBuilder {
-	user, err := user.Current()
-	if err != nil {
-		log.Fatal().Err(err).Msg("cannot find current user")
-	}
+	username := b.currentUser()
 	
-	command := fmt.Sprintf("/usr/bin/env AWS_PROFILE=%s /usr/bin/login -fp %s", awsProfile, user.Username)
+	command := fmt.Sprintf("/usr/bin/env AWS_PROFILE=%s /usr/bin/login -fp %s", awsProfile, username)
 	b.WithCommand(command)
+	b.WithMetadata("profile", awsProfile)
 	return b
 }
 
@@ -175,6 +219,7 @@ func NewSSHProfileBuilder(host string) *SSHProfileBuilder {
 func (b *SSHProfileBuilder) WithSSHCommand(host string) *SSHProfileBuilder {
 	b.WithCommand(fmt.Sprintf("ssh %s", host))
 	b.WithTags("ssh")
+	b.WithMetadata("host", host)
 	return b
 }
 
@@ -182,13 +227,14 @@ func (b *SSHProfileBuilder) WithSSHCommand(host string) *SSHProfileBuilder {
 func (b *SSHProfileBuilder) WithHostIP(ip string) *SSHProfileBuilder {
 	if ip != "" {
 		b.WithTags(ip)
+		b.WithMetadata("ip", ip)
 	}
 	return b
 }
 
 // WithTmuxDetach adds tmux detach keyboard shortcut
 func (b *SSHProfileBuilder) WithTmuxDetach() *SSHProfileBuilder {
-	b.WithKeyboardShortcut("0x77-0x100000-0xd", 25, "Detach\ntmux.Detach")
+	b.WithKeyboardShortcut("0x77-0x100000-0xd", iterm.KeyboardSelectMenuItem, "Detach\ntmux.Detach")
 	return b
 }
 
@@ -206,12 +252,9 @@ func NewK8sProfileBuilder(clusterName string) *K8sProfileBuilder {
 
 // WithKubeConfig sets up the profile with KUBECONFIG environment variable
 func (b *K8sProfileBuilder) WithKubeConfig(kubeconfigPath string) *K8sProfileBuilder {
-	user, err := user.Current()
-	if err != nil {
-		log.Fatal().Err(err).Msg("cannot find current user")
-	}
+	username := b.currentUser()
 	
-	command := fmt.Sprintf("/usr/bin/env KUBECONFIG=%s /usr/bin/login -fp %s", kubeconfigPath, user.Username)
+	command := fmt.Sprintf("/usr/bin/env KUBECONFIG=%s /usr/bin/login -fp %s", kubeconfigPath, username)
 	b.WithCommand(command)
 	b.WithTags("k8s")
 	return b
@@ -220,20 +263,18 @@ func (b *K8sProfileBuilder) WithKubeConfig(kubeconfigPath string) *K8sProfileBui
 // WithAWSProfile adds AWS profile to the Kubernetes profile
 func (b *K8sProfileBuilder) WithAWSProfile(awsProfile string) *K8sProfileBuilder {
 	if awsProfile != "" {
-		user, err := user.Current()
-		if err != nil {
-			log.Fatal().Err(err).Msg("cannot find current user")
-		}
+		username := b.currentUser()
 		
 		// Get the current kubeconfig path from existing command or use a default
 		kubeconfigPath := b.extractKubeConfigFromCommand()
 		
 		// Build new command with both KUBECONFIG and AWS_PROFILE
 		command := fmt.Sprintf("/usr/bin/env KUBECONFIG=%s AWS_PROFILE=%s /usr/bin/login -fp %s", 
-			kubeconfigPath, awsProfile, user.Username)
+			kubeconfigPath, awsProfile, username)
 		
 		b.WithCommand(command)
 		b.WithTags(fmt.Sprintf("aws-profile=%s", awsProfile))
+		b.WithMetadata("profile", awsProfile)
 	}
 	return b
 }
@@ -282,22 +323,25 @@ func (b *SSMProfileBuilder) WithSSMCommand(awsProfile, instanceName string) *SSM
 }
 
 // Build creates the final iTerm profile and handles SSM-specific settings
-func (b *SSMProfileBuilder) Build() *iterm.Profile {
-	profile := b.Builder.Build()
+func (b *SSMProfileBuilder) Build() (*iterm.Profile, error) {
+	profile, err := b.Builder.Build()
 	
 	// SSM profiles should not have CustomCommand set to "Yes"
 	// They use InitialText instead
 	profile.CustomCommand = "No"
 	
-	return profile
+	return profile, err
 }
 
 // WithAWSAccountInfo adds AWS account and region information as tags
 func (b *SSMProfileBuilder) WithAWSAccountInfo(accountAlias, accountID, region string, regionTags []string) *SSMProfileBuilder {
-	tags := fmt.Sprintf("AWS, %s,account=%s", accountAlias, accountID)
+	tags := fmt.Sprintf("AWS, %s,account=%s,alias=%s", accountAlias, accountID, accountAlias)
 	if len(regionTags) > 2 {
 		tags += ",region_id=" + regionTags[2]
 	}
 	b.WithTagsString(tags)
+	b.WithMetadata("alias", accountAlias)
+	b.WithMetadata("account", accountID)
+	b.WithMetadata("region", region)
 	return b
 }
\ No newline at end of file
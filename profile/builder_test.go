@@ -19,18 +19,20 @@ func TestNewBuilder(t *testing.T) {
 }
 
 func TestBuilder_WithCommand(t *testing.T) {
-	profile := NewBuilder("test").
+	profile, err := NewBuilder("test").
 		WithCommand("echo hello").
 		Build()
+	assert.NoError(t, err)
 	
 	assert.Equal(t, "echo hello", profile.Command)
 	assert.Equal(t, "Yes", profile.CustomCommand)
 }
 
 func TestBuilder_WithTags(t *testing.T) {
-	profile := NewBuilder("test").
+	profile, err := NewBuilder("test").
 		WithTags("tag1", "tag2").
 		Build()
+	assert.NoError(t, err)
 	
 	// Profile always has at least one tag (the unique name), so check if our tags are included
 	assert.Contains(t, profile.Tags, "tag1")
@@ -38,9 +40,10 @@ func TestBuilder_WithTags(t *testing.T) {
 }
 
 func TestBuilder_WithKeyboardShortcut(t *testing.T) {
-	profile := NewBuilder("test").
+	profile, err := NewBuilder("test").
 		WithKeyboardShortcut("test-key", 12, "test text").
 		Build()
+	assert.NoError(t, err)
 	
 	assert.Contains(t, profile.KeyboardMap, "test-key")
 	assert.Equal(t, int64(12), profile.KeyboardMap["test-key"].Action)
@@ -48,9 +51,10 @@ func TestBuilder_WithKeyboardShortcut(t *testing.T) {
 }
 
 func TestBuilder_WithAltAShortcut(t *testing.T) {
-	profile := NewBuilder("test").
+	profile, err := NewBuilder("test").
 		WithAltAShortcut("alt-a text").
 		Build()
+	assert.NoError(t, err)
 	
 	assert.Contains(t, profile.KeyboardMap, iterm.KeyboardSortcutAltA)
 	assert.Equal(t, int64(12), profile.KeyboardMap[iterm.KeyboardSortcutAltA].Action)
@@ -65,9 +69,10 @@ func TestBuilder_WithTrigger(t *testing.T) {
 		Partial:   true,
 	}
 	
-	profile := NewBuilder("test").
+	profile, err := NewBuilder("test").
 		WithTrigger(trigger).
 		Build()
+	assert.NoError(t, err)
 	
 	// Profile may have default triggers, so check that our trigger is included
 	found := false
@@ -81,28 +86,31 @@ func TestBuilder_WithTrigger(t *testing.T) {
 }
 
 func TestBuilder_WithBoundHosts(t *testing.T) {
-	profile := NewBuilder("test").
+	profile, err := NewBuilder("test").
 		WithBoundHosts("host1", "host2").
 		Build()
+	assert.NoError(t, err)
 	
 	assert.Equal(t, []string{"host1", "host2"}, profile.BoundHosts)
 }
 
 func TestBuilder_WithPrefix(t *testing.T) {
-	profile := NewBuilder("test").
+	profile, err := NewBuilder("test").
 		WithPrefix("prefix").
 		Build()
+	assert.NoError(t, err)
 	
 	assert.Equal(t, "prefix-test", profile.Name)
 }
 
 func TestBuilder_FluentInterface(t *testing.T) {
-	profile := NewBuilder("test").
+	profile, err := NewBuilder("test").
 		WithCommand("echo test").
 		WithTags("tag1", "tag2").
 		WithAltAShortcut("shortcut text").
 		WithPrefix("prefix").
 		Build()
+	assert.NoError(t, err)
 	
 	assert.Equal(t, "prefix-test", profile.Name)
 	assert.Equal(t, "echo test", profile.Command)
@@ -112,19 +120,21 @@ func TestBuilder_FluentInterface(t *testing.T) {
 }
 
 func TestAWSProfileBuilder_WithAWSProfile(t *testing.T) {
-	profile := NewAWSProfileBuilder("aws-test").
+	profile, err := NewAWSProfileBuilder("aws-test").
 		WithAWSProfile("my-profile").
 		Build()
+	assert.NoError(t, err)
 	
 	assert.Contains(t, profile.Command, "AWS_PROFILE=my-profile")
 	assert.Contains(t, profile.Command, "/usr/bin/login")
 }
 
 func TestSSHProfileBuilder_WithSSHCommand(t *testing.T) {
-	profile := NewSSHProfileBuilder("server1").
+	profile, err := NewSSHProfileBuilder("server1").
 		WithSSHCommand("server1").
 		WithHostIP("192.168.1.10").
 		Build()
+	assert.NoError(t, err)
 	
 	assert.Equal(t, "server1", profile.Name)
 	assert.Equal(t, "ssh server1", profile.Command)
@@ -133,9 +143,10 @@ func TestSSHProfileBuilder_WithSSHCommand(t *testing.T) {
 }
 
 func TestSSHProfileBuilder_WithTmuxDetach(t *testing.T) {
-	profile := NewSSHProfileBuilder("server1").
+	profile, err := NewSSHProfileBuilder("server1").
 		WithTmuxDetach().
 		Build()
+	assert.NoError(t, err)
 	
 	assert.Contains(t, profile.KeyboardMap, "0x77-0x100000-0xd")
 	assert.Equal(t, int64(25), profile.KeyboardMap["0x77-0x100000-0xd"].Action)
@@ -143,9 +154,10 @@ func TestSSHProfileBuilder_WithTmuxDetach(t *testing.T) {
 }
 
 func TestK8sProfileBuilder_WithKubeConfig(t *testing.T) {
-	profile := NewK8sProfileBuilder("my-cluster").
+	profile, err := NewK8sProfileBuilder("my-cluster").
 		WithKubeConfig("/path/to/kubeconfig").
 		Build()
+	assert.NoError(t, err)
 	
 	assert.Equal(t, "k8s-my-cluster", profile.Name)
 	assert.Contains(t, profile.Command, "KUBECONFIG=/path/to/kubeconfig")
@@ -153,24 +165,28 @@ func TestK8sProfileBuilder_WithKubeConfig(t *testing.T) {
 }
 
 func TestK8sProfileBuilder_WithAWSProfile(t *testing.T) {
-	profile := NewK8sProfileBuilder("my-cluster").
+	profile, err := NewK8sProfileBuilder("my-cluster").
 		WithKubeConfig("/path/to/kubeconfig").
 		WithAWSProfile("aws-profile").
 		Build()
+	assert.NoError(t, err)
 	
 	assert.Contains(t, profile.Tags, "aws-profile=aws-profile")
 	// Note: The command modification logic needs refinement in the actual implementation
 }
 
 func TestSSMProfileBuilder_WithSSMCommand(t *testing.T) {
-	profile := NewSSMProfileBuilder("account", "us-east-1", "instance1").
+	profile, err := NewSSMProfileBuilder("account", "us-east-1", "instance1").
 		WithSSMCommand("aws-profile", "instance1").
 		WithAWSAccountInfo("account", "123456789", "us-east-1", []string{"US", "East", "use1"}).
 		Build()
+	assert.NoError(t, err)
 	
 	assert.Equal(t, "account:us-east-1:ssm-instance1", profile.Name)
 	assert.Contains(t, profile.InitialText, "AWS_PROFILE=aws-profile ssm instance1")
 	assert.Contains(t, profile.KeyboardMap, iterm.KeyboardSortcutAltA)
+	assert.Contains(t, profile.Tags, "account=123456789")
+	assert.Contains(t, profile.Tags, "alias=account")
 	
 	// The CustomCommand field should be set to "No" via the config map during NewProfile
 	assert.Equal(t, "No", profile.CustomCommand)
@@ -0,0 +1,394 @@
+// Package scheme loads color schemes, from .itermcolors files or base16 and
+// germ YAML files, and applies them to the profiles.
+package scheme
+
+import (
+	stderrors "errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adrg/xdg"
+	"github.com/mhristof/germ/iterm"
+	"github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// Extensions are the scheme file formats, in the order they are looked up.
+var Extensions = []string{".itermcolors", ".yaml", ".yml"}
+
+// Scheme holds colors by iTerm2 key, such as "Ansi 0 Color".
+type Scheme struct {
+	Colors map[string]iterm.Color
+	// Light and Dark override Colors in light and dark mode.
+	Light map[string]iterm.Color
+	Dark  map[string]iterm.Color
+}
+
+// add sets the color of key, keys with a light or dark suffix set the
+// colors of that mode.
+func (s *Scheme) add(key string, c iterm.Color) {
+	target := &s.Colors
+
+	switch {
+	case strings.HasSuffix(key, iterm.LightSuffix):
+		key, target = strings.TrimSuffix(key, iterm.LightSuffix), &s.Light
+	case strings.HasSuffix(key, iterm.DarkSuffix):
+		key, target = strings.TrimSuffix(key, iterm.DarkSuffix), &s.Dark
+	}
+
+	if *target == nil {
+		*target = map[string]iterm.Color{}
+	}
+
+	(*target)[key] = c
+}
+
+// Variants returns true if the scheme has both light and dark colors.
+func (s Scheme) Variants() bool {
+	return len(s.Light) > 0 && len(s.Dark) > 0
+}
+
+// flat returns Colors overridden by the variant colors.
+func (s Scheme) flat(variant map[string]iterm.Color) map[string]iterm.Color {
+	ret := map[string]iterm.Color{}
+	for key, c := range s.Colors {
+		ret[key] = c
+	}
+
+	for key, c := range variant {
+		ret[key] = c
+	}
+
+	return ret
+}
+
+// Apply sets the colors of the scheme. Schemes with light and dark colors
+// turn on the separate colors of the profile so it follows the macOS
+// appearance, the other keep the mode of the profile.
+func (s Scheme) Apply(p *iterm.Profile) error {
+	var errs []error
+
+	if !s.Variants() {
+		for key, c := range s.flat(s.Light) {
+			errs = append(errs, p.SetColorKey(key, c))
+		}
+
+		for key, c := range s.Dark {
+			errs = append(errs, p.SetColorKey(key, c))
+		}
+
+		return stderrors.Join(errs...)
+	}
+
+	errs = append(errs, p.Set(iterm.SeparateColors, true))
+
+	for key, c := range s.flat(s.Dark) {
+		errs = append(errs, p.Set(key, c), p.Set(key+iterm.DarkSuffix, c))
+	}
+
+	for key, c := range s.flat(s.Light) {
+		errs = append(errs, p.Set(key+iterm.LightSuffix, c))
+	}
+
+	return stderrors.Join(errs...)
+}
+
+// Parse decodes a scheme in the format given by the extension of path.
+func Parse(path string, data []byte) (Scheme, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".itermcolors":
+		return parseITermColors(data)
+	case ".yaml", ".yml":
+		return parseYAML(data)
+	}
+
+	return Scheme{}, fmt.Errorf("unknown scheme format %q, expected one of %s", filepath.Ext(path), strings.Join(Extensions, ", "))
+}
+
+// base16 maps the iTerm2 keys to the base16 colors, as base16-shell does.
+var base16 = map[string]string{
+	"Background Color":    "base00",
+	"Foreground Color":    "base05",
+	"Bold Color":          "base05",
+	"Cursor Color":        "base05",
+	"Cursor Text Color":   "base00",
+	"Selection Color":     "base02",
+	"Selected Text Color": "base05",
+	"Link Color":          "base0D",
+	"Ansi 0 Color":        "base00",
+	"Ansi 1 Color":        "base08",
+	"Ansi 2 Color":        "base0B",
+	"Ansi 3 Color":        "base0A",
+	"Ansi 4 Color":        "base0D",
+	"Ansi 5 Color":        "base0E",
+	"Ansi 6 Color":        "base0C",
+	"Ansi 7 Color":        "base05",
+	"Ansi 8 Color":        "base03",
+	"Ansi 9 Color":        "base08",
+	"Ansi 10 Color":       "base0B",
+	"Ansi 11 Color":       "base0A",
+	"Ansi 12 Color":       "base0D",
+	"Ansi 13 Color":       "base0E",
+	"Ansi 14 Color":       "base0C",
+	"Ansi 15 Color":       "base07",
+}
+
+// metadata are the keys of the YAML schemes that are not colors.
+var metadata = map[string]struct{}{
+	"scheme":      {},
+	"name":        {},
+	"author":      {},
+	"slug":        {},
+	"system":      {},
+	"variant":     {},
+	"description": {},
+}
+
+type yamlScheme struct {
+	Palette map[string]interface{} `yaml:"palette"`
+	Light   map[string]interface{} `yaml:"light"`
+	Dark    map[string]interface{} `yaml:"dark"`
+	Colors  map[string]interface{} `yaml:",inline"`
+}
+
+// parseYAML reads a base16 scheme, with the colors at the top level or in a
+// palette section, or a germ scheme with colors by name, see
+// iterm.ColorKey, and optional light and dark sections.
+func parseYAML(data []byte) (Scheme, error) {
+	var raw yamlScheme
+
+	err := yaml.Unmarshal(data, &raw)
+	if err != nil {
+		return Scheme{}, errors.Wrap(err, "invalid yaml")
+	}
+
+	var ret Scheme
+	var errs []error
+
+	sections := []struct {
+		suffix string
+		values map[string]interface{}
+	}{
+		{"", raw.Colors},
+		{"", raw.Palette},
+		{iterm.LightSuffix, raw.Light},
+		{iterm.DarkSuffix, raw.Dark},
+	}
+
+	for _, section := range sections {
+		colors, err := yamlColors(section.values)
+		if err != nil {
+			errs = append(errs, err)
+		}
+
+		for key, c := range colors {
+			ret.add(key+section.suffix, c)
+		}
+	}
+
+	return ret, stderrors.Join(errs...)
+}
+
+// yamlColors returns the colors of a YAML section by iTerm2 key.
+func yamlColors(values map[string]interface{}) (map[string]iterm.Color, error) {
+	hex := map[string]string{}
+
+	for name, value := range values {
+		if _, ok := metadata[name]; ok {
+			continue
+		}
+
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("color %s is not a string, quote it", name)
+		}
+
+		hex[name] = "#" + strings.TrimPrefix(s, "#")
+	}
+
+	if _, ok := hex["base00"]; ok {
+		mapped := map[string]string{}
+		for key, name := range base16 {
+			if value, ok := hex[name]; ok {
+				mapped[key] = value
+			}
+		}
+
+		hex = mapped
+	}
+
+	ret := map[string]iterm.Color{}
+	var errs []error
+
+	for name, value := range hex {
+		key, err := iterm.ColorKey(name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		c, err := iterm.ParseColor(value)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		ret[key] = c
+	}
+
+	return ret, stderrors.Join(errs...)
+}
+
+// Dir is where the schemes referenced by name are looked up.
+func Dir() string {
+	return filepath.Join(xdg.ConfigHome, "germ", "schemes")
+}
+
+// Find returns the path of the scheme name, either a path to a file or the
+// name of a file in Dir, with or without its extension.
+func Find(name string) (string, error) {
+	path, err := homedir.Expand(name)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot expand %s", name)
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+
+	if filepath.IsAbs(path) {
+		return "", fmt.Errorf("scheme %s not found", path)
+	}
+
+	candidates := []string{filepath.Join(Dir(), name)}
+	for _, ext := range Extensions {
+		candidates = append(candidates, filepath.Join(Dir(), name+ext))
+	}
+
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+
+	return "", fmt.Errorf("scheme %q not found in %s", name, Dir())
+}
+
+type cached struct {
+	modified time.Time
+	size     int64
+	scheme   Scheme
+}
+
+// cache holds the schemes read by path, they are read again when the file
+// changes.
+var cache = struct {
+	sync.Mutex
+	entries map[string]cached
+}{entries: map[string]cached{}}
+
+// Load finds and reads the scheme name.
+func Load(name string) (Scheme, error) {
+	path, err := Find(name)
+	if err != nil {
+		return Scheme{}, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return Scheme{}, errors.Wrapf(err, "cannot read %s", path)
+	}
+
+	cache.Lock()
+	defer cache.Unlock()
+
+	entry, ok := cache.entries[path]
+	if ok && entry.modified.Equal(info.ModTime()) && entry.size == info.Size() {
+		return entry.scheme, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Scheme{}, errors.Wrapf(err, "cannot read %s", path)
+	}
+
+	s, err := Parse(path, data)
+	if err != nil {
+		return Scheme{}, errors.Wrapf(err, "invalid scheme %s", path)
+	}
+
+	cache.entries[path] = cached{modified: info.ModTime(), size: info.Size(), scheme: s}
+
+	return s, nil
+}
+
+// Ref references a scheme by name, or two schemes used in light and dark
+// mode. In germ.yaml it is either the name or a map with light and dark.
+type Ref struct {
+	Name  string
+	Light string
+	Dark  string
+}
+
+// IsZero returns true if no scheme is referenced.
+func (r Ref) IsZero() bool {
+	return r == Ref{}
+}
+
+// Load reads the referenced schemes.
+func (r Ref) Load() (Scheme, error) {
+	if r.Name != "" {
+		return Load(r.Name)
+	}
+
+	if r.Light == "" || r.Dark == "" {
+		return Scheme{}, fmt.Errorf("scheme needs a name, or both light and dark")
+	}
+
+	light, err := Load(r.Light)
+	if err != nil {
+		return Scheme{}, err
+	}
+
+	dark, err := Load(r.Dark)
+	if err != nil {
+		return Scheme{}, err
+	}
+
+	return Scheme{
+		Colors: dark.flat(dark.Dark),
+		Light:  light.flat(light.Light),
+		Dark:   dark.flat(dark.Dark),
+	}, nil
+}
+
+// Apply applies the referenced scheme, if any, to the profile.
+func (r Ref) Apply(p *iterm.Profile) error {
+	if r.IsZero() {
+		return nil
+	}
+
+	s, err := r.Load()
+	if err != nil {
+		return err
+	}
+
+	return s.Apply(p)
+}
+
+// DecodeHook is a mapstructure decode hook that decodes a Ref from a scheme
+// name.
+func DecodeHook(from, to reflect.Type, data interface{}) (interface{}, error) {
+	if to != reflect.TypeOf(Ref{}) || from.Kind() != reflect.String {
+		return data, nil
+	}
+
+	return Ref{Name: data.(string)}, nil
+}
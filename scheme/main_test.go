@@ -0,0 +1,253 @@
+package scheme
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/adrg/xdg"
+	"github.com/mhristof/germ/iterm"
+	"github.com/stretchr/testify/assert"
+)
+
+var itermColors = heredoc.Doc(`
+	<?xml version="1.0" encoding="UTF-8"?>
+	<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+	<plist version="1.0">
+	<dict>
+		<key>Ansi 0 Color</key>
+		<dict>
+			<key>Blue Component</key>
+			<real>0.5</real>
+			<key>Green Component</key>
+			<real>0.25</real>
+			<key>Red Component</key>
+			<integer>1</integer>
+		</dict>
+		<key>Background Color (Light)</key>
+		<dict>
+			<key>Alpha Component</key>
+			<real>1</real>
+			<key>Blue Component</key>
+			<real>1</real>
+			<key>Color Space</key>
+			<string>sRGB</string>
+			<key>Green Component</key>
+			<real>1</real>
+			<key>Red Component</key>
+			<real>1</real>
+		</dict>
+		<key>Background Color (Dark)</key>
+		<dict>
+			<key>Alpha Component</key>
+			<real>1</real>
+			<key>Blue Component</key>
+			<real>0</real>
+			<key>Color Space</key>
+			<string>sRGB</string>
+			<key>Green Component</key>
+			<real>0</real>
+			<key>Red Component</key>
+			<real>0</real>
+		</dict>
+	</dict>
+	</plist>
+`)
+
+var (
+	white = iterm.Color{ColorSpace: "sRGB", RedComponent: 1, GreenComponent: 1, BlueComponent: 1, AlphaComponent: 1}
+	black = iterm.Color{ColorSpace: "sRGB", AlphaComponent: 1}
+	red   = iterm.Color{ColorSpace: "sRGB", RedComponent: 1, AlphaComponent: 1}
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name     string
+		path     string
+		data     string
+		expected Scheme
+		err      bool
+	}{
+		{
+			name: "itermcolors",
+			path: "scheme.itermcolors",
+			data: itermColors,
+			expected: Scheme{
+				Colors: map[string]iterm.Color{
+					"Ansi 0 Color": {ColorSpace: "Calibrated", RedComponent: 1, GreenComponent: 0.25, BlueComponent: 0.5, AlphaComponent: 1},
+				},
+				Light: map[string]iterm.Color{"Background Color": white},
+				Dark:  map[string]iterm.Color{"Background Color": black},
+			},
+		},
+		{
+			name: "base16",
+			path: "scheme.yaml",
+			data: heredoc.Doc(`
+				scheme: "Test"
+				author: "germ"
+				base00: "000000"
+				base05: "ffffff"
+				base08: "ff0000"
+			`),
+			expected: Scheme{
+				Colors: map[string]iterm.Color{
+					"Background Color":    black,
+					"Cursor Text Color":   black,
+					"Ansi 0 Color":        black,
+					"Foreground Color":    white,
+					"Bold Color":          white,
+					"Cursor Color":        white,
+					"Selected Text Color": white,
+					"Ansi 7 Color":        white,
+					"Ansi 1 Color":        red,
+					"Ansi 9 Color":        red,
+				},
+			},
+		},
+		{
+			name: "tinted palette",
+			path: "scheme.yml",
+			data: heredoc.Doc(`
+				system: "base16"
+				name: "Test"
+				variant: "dark"
+				palette:
+				  base00: "#000000"
+			`),
+			expected: Scheme{
+				Colors: map[string]iterm.Color{
+					"Background Color":  black,
+					"Cursor Text Color": black,
+					"Ansi 0 Color":      black,
+				},
+			},
+		},
+		{
+			name: "germ yaml",
+			path: "scheme.yaml",
+			data: heredoc.Doc(`
+				ansi1: "#ff0000"
+				Link Color: "#ffffff"
+				light:
+				  background: "#ffffff"
+				dark:
+				  background: "#000000"
+			`),
+			expected: Scheme{
+				Colors: map[string]iterm.Color{"Ansi 1 Color": red, "Link Color": white},
+				Light:  map[string]iterm.Color{"Background Color": white},
+				Dark:   map[string]iterm.Color{"Background Color": black},
+			},
+		},
+		{
+			name: "unknown color",
+			path: "scheme.yaml",
+			data: `bg: "#000000"`,
+			err:  true,
+		},
+		{
+			name: "unquoted color",
+			path: "scheme.yaml",
+			data: `base00: 000000`,
+			err:  true,
+		},
+		{
+			name: "unknown format",
+			path: "scheme.json",
+			err:  true,
+		},
+	}
+
+	for _, test := range cases {
+		s, err := Parse(test.path, []byte(test.data))
+		if test.err {
+			assert.Error(t, err, test.name)
+			continue
+		}
+
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, test.expected, s, test.name)
+	}
+}
+
+func TestApply(t *testing.T) {
+	s := Scheme{
+		Colors: map[string]iterm.Color{"Ansi 1 Color": red},
+		Light:  map[string]iterm.Color{"Background Color": white},
+		Dark:   map[string]iterm.Color{"Background Color": black},
+	}
+
+	var p iterm.Profile
+	assert.NoError(t, s.Apply(&p))
+
+	assert.Equal(t, true, p.Extra[iterm.SeparateColors])
+	assert.Equal(t, black, p.BackgroundColor)
+	assert.Equal(t, black, p.Extra["Background Color (Dark)"])
+	assert.Equal(t, white, p.Extra["Background Color (Light)"])
+	assert.Equal(t, red, p.Ansi1Color)
+	assert.Equal(t, red, p.Extra["Ansi 1 Color (Light)"])
+	assert.Equal(t, red, p.Extra["Ansi 1 Color (Dark)"])
+
+	// a single scheme keeps the separate colors of the profile
+	single := Scheme{Colors: map[string]iterm.Color{"Background Color": red}}
+	assert.NoError(t, single.Apply(&p))
+
+	assert.Equal(t, red, p.BackgroundColor)
+	assert.Equal(t, red, p.Extra["Background Color (Light)"])
+	assert.Equal(t, red, p.Extra["Background Color (Dark)"])
+
+	var plain iterm.Profile
+	assert.NoError(t, single.Apply(&plain))
+	assert.Equal(t, red, plain.BackgroundColor)
+	assert.Nil(t, plain.Extra)
+}
+
+func TestRef(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	xdg.Reload()
+	defer xdg.Reload()
+
+	assert.NoError(t, os.MkdirAll(Dir(), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(Dir(), "light.yaml"), []byte(`background: "#ffffff"`), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(Dir(), "dark.itermcolors"), []byte(itermColors), 0o644))
+
+	other := filepath.Join(t.TempDir(), "red.yml")
+	assert.NoError(t, os.WriteFile(other, []byte(`ansi1: "#ff0000"`), 0o644))
+
+	s, err := Ref{Name: other}.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, Scheme{Colors: map[string]iterm.Color{"Ansi 1 Color": red}}, s)
+
+	s, err = Ref{Name: "light"}.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, Scheme{Colors: map[string]iterm.Color{"Background Color": white}}, s)
+
+	s, err = Ref{Light: "light", Dark: "dark.itermcolors"}.Load()
+	assert.NoError(t, err)
+	assert.True(t, s.Variants())
+	assert.Equal(t, white, s.Light["Background Color"])
+	assert.Equal(t, black, s.Dark["Background Color"])
+	assert.Contains(t, s.Dark, "Ansi 0 Color")
+
+	_, err = Ref{Name: "missing"}.Load()
+	assert.Error(t, err)
+
+	_, err = Ref{Light: "light"}.Load()
+	assert.Error(t, err)
+
+	assert.NoError(t, Ref{}.Apply(&iterm.Profile{}))
+}
+
+func TestDecodeHook(t *testing.T) {
+	ref, err := DecodeHook(reflect.TypeOf(""), reflect.TypeOf(Ref{}), "Solarized")
+	assert.NoError(t, err)
+	assert.Equal(t, Ref{Name: "Solarized"}, ref)
+
+	data := map[string]interface{}{"light": "a", "dark": "b"}
+	same, err := DecodeHook(reflect.TypeOf(data), reflect.TypeOf(Ref{}), data)
+	assert.NoError(t, err)
+	assert.Equal(t, data, same)
+}
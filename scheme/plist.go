@@ -0,0 +1,159 @@
+package scheme
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/mhristof/germ/iterm"
+	"github.com/pkg/errors"
+)
+
+// parsePlist decodes the values of an XML property list, dictionaries are
+// returned as map[string]interface{} and numbers as float64.
+func parsePlist(data []byte) (interface{}, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil, errors.New("empty property list")
+		}
+
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid property list")
+		}
+
+		if start, ok := tok.(xml.StartElement); ok && start.Name.Local != "plist" {
+			return plistValue(dec, start)
+		}
+	}
+}
+
+func plistValue(dec *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	switch start.Name.Local {
+	case "dict":
+		return plistDict(dec)
+	case "array":
+		return plistArray(dec)
+	case "true", "false":
+		return start.Name.Local == "true", dec.Skip()
+	}
+
+	var text string
+
+	err := dec.DecodeElement(&text, &start)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid <%s>", start.Name.Local)
+	}
+
+	text = strings.TrimSpace(text)
+
+	switch start.Name.Local {
+	case "real", "integer":
+		return strconv.ParseFloat(text, 64)
+	case "string", "key", "date", "data":
+		return text, nil
+	}
+
+	return nil, fmt.Errorf("unknown element <%s>", start.Name.Local)
+}
+
+func plistDict(dec *xml.Decoder) (map[string]interface{}, error) {
+	ret := map[string]interface{}{}
+	key := ""
+
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid <dict>")
+		}
+
+		switch t := tok.(type) {
+		case xml.EndElement:
+			return ret, nil
+		case xml.StartElement:
+			value, err := plistValue(dec, t)
+			if err != nil {
+				return nil, err
+			}
+
+			if t.Name.Local == "key" {
+				key, _ = value.(string)
+				continue
+			}
+
+			ret[key] = value
+		}
+	}
+}
+
+func plistArray(dec *xml.Decoder) ([]interface{}, error) {
+	var ret []interface{}
+
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid <array>")
+		}
+
+		switch t := tok.(type) {
+		case xml.EndElement:
+			return ret, nil
+		case xml.StartElement:
+			value, err := plistValue(dec, t)
+			if err != nil {
+				return nil, err
+			}
+
+			ret = append(ret, value)
+		}
+	}
+}
+
+// parseITermColors reads an .itermcolors file, a property list with a
+// dictionary of color components for every iTerm2 color key.
+func parseITermColors(data []byte) (Scheme, error) {
+	root, err := parsePlist(data)
+	if err != nil {
+		return Scheme{}, err
+	}
+
+	dict, ok := root.(map[string]interface{})
+	if !ok {
+		return Scheme{}, errors.New("expected a dictionary of colors")
+	}
+
+	ret := Scheme{Colors: map[string]iterm.Color{}}
+
+	for key, value := range dict {
+		components, ok := value.(map[string]interface{})
+		if !ok {
+			return Scheme{}, fmt.Errorf("%s is not a color", key)
+		}
+
+		color := iterm.Color{ColorSpace: "Calibrated", AlphaComponent: 1}
+
+		for name, v := range components {
+			switch name {
+			case "Color Space":
+				color.ColorSpace, _ = v.(string)
+			case "Red Component":
+				color.RedComponent, _ = v.(float64)
+			case "Green Component":
+				color.GreenComponent, _ = v.(float64)
+			case "Blue Component":
+				color.BlueComponent, _ = v.(float64)
+			case "Alpha Component":
+				color.AlphaComponent, _ = v.(float64)
+			}
+		}
+
+		ret.add(key, color)
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,173 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mhristof/germ/iterm"
+	"github.com/rs/zerolog/log"
+)
+
+// ProfileSource is implemented by every package that can generate iTerm
+// profiles, for example from the AWS config or the kubeconfig.
+type ProfileSource interface {
+	Name() string
+	Generate(ctx context.Context) ([]iterm.Profile, error)
+}
+
+// Watchable is implemented by sources that generate their profiles from
+// local files. Inputs returns the paths of those files.
+type Watchable interface {
+	Inputs() []string
+}
+
+// Settings controls which sources are used and in which order. When Enabled
+// is set only the listed sources run, in the given order.
+type Settings struct {
+	Enabled        []string
+	Disabled       []string
+	DefaultTimeout time.Duration
+	Timeouts       map[string]time.Duration
+}
+
+// Status describes a registered source and whether it will run.
+type Status struct {
+	Name    string
+	Enabled bool
+	Order   int
+}
+
+// Registry holds the available profile sources in their default order.
+type Registry struct {
+	sources []ProfileSource
+}
+
+type funcSource struct {
+	name string
+	fn   func(ctx context.Context) ([]iterm.Profile, error)
+}
+
+func (f *funcSource) Name() string {
+	return f.name
+}
+
+func (f *funcSource) Generate(ctx context.Context) ([]iterm.Profile, error) {
+	return f.fn(ctx)
+}
+
+// Func wraps a plain function as a ProfileSource.
+func Func(name string, fn func(ctx context.Context) ([]iterm.Profile, error)) ProfileSource {
+	return &funcSource{name: name, fn: fn}
+}
+
+// NewRegistry returns a registry of the sources, which must have unique
+// names. Use Register for the sources that may clash.
+func NewRegistry(sources ...ProfileSource) *Registry {
+	r := &Registry{}
+
+	for _, s := range sources {
+		if err := r.Register(s); err != nil {
+			log.Panic().Err(err).Msg("cannot create the source registry")
+		}
+	}
+
+	return r
+}
+
+// Register adds a source at the end of the default order. A source with the
+// name of a registered one is not added.
+func (r *Registry) Register(s ProfileSource) error {
+	if _, found := r.Get(s.Name()); found {
+		return fmt.Errorf("source %q is already registered", s.Name())
+	}
+
+	r.sources = append(r.sources, s)
+
+	return nil
+}
+
+func (r *Registry) Get(name string) (ProfileSource, bool) {
+	for _, s := range r.sources {
+		if s.Name() == name {
+			return s, true
+		}
+	}
+
+	return nil, false
+}
+
+func (r *Registry) Names() []string {
+	ret := make([]string, len(r.sources))
+	for i, s := range r.sources {
+		ret[i] = s.Name()
+	}
+
+	return ret
+}
+
+// Enabled returns the sources that should run for the given settings, in
+// the order they should run.
+func (r *Registry) Enabled(settings Settings) ([]ProfileSource, error) {
+	disabled := map[string]struct{}{}
+	for _, name := range settings.Disabled {
+		if _, found := r.Get(name); !found {
+			return nil, fmt.Errorf("unknown disabled source %q", name)
+		}
+
+		disabled[name] = struct{}{}
+	}
+
+	order := r.Names()
+	if len(settings.Enabled) > 0 {
+		order = settings.Enabled
+	}
+
+	var ret []ProfileSource
+	seen := map[string]struct{}{}
+	for _, name := range order {
+		s, found := r.Get(name)
+		if !found {
+			return nil, fmt.Errorf("unknown enabled source %q", name)
+		}
+
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+
+		if _, ok := disabled[name]; ok {
+			continue
+		}
+
+		ret = append(ret, s)
+	}
+
+	return ret, nil
+}
+
+// Status reports every registered source with its position in the run
+// order. Disabled sources are listed last with an order of 0.
+func (r *Registry) Status(settings Settings) ([]Status, error) {
+	enabled, err := r.Enabled(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []Status
+	running := map[string]struct{}{}
+	for i, s := range enabled {
+		running[s.Name()] = struct{}{}
+		ret = append(ret, Status{Name: s.Name(), Enabled: true, Order: i + 1})
+	}
+
+	for _, name := range r.Names() {
+		if _, ok := running[name]; ok {
+			continue
+		}
+
+		ret = append(ret, Status{Name: name})
+	}
+
+	return ret, nil
+}
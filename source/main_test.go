@@ -0,0 +1,234 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/mhristof/germ/iterm"
+	"github.com/stretchr/testify/assert"
+)
+
+func fake(name string) ProfileSource {
+	return Func(name, func(ctx context.Context) ([]iterm.Profile, error) {
+		return []iterm.Profile{{Name: name}}, nil
+	})
+}
+
+func names(sources []ProfileSource) []string {
+	var ret []string
+	for _, s := range sources {
+		ret = append(ret, s.Name())
+	}
+
+	return ret
+}
+
+func TestEnabled(t *testing.T) {
+	cases := []struct {
+		name     string
+		settings Settings
+		out      []string
+		err      bool
+	}{
+		{
+			name: "default order",
+			out:  []string{"aws", "k8s", "vim", "vault"},
+		},
+		{
+			name: "disabled sources are removed",
+			settings: Settings{
+				Disabled: []string{"vim", "vault"},
+			},
+			out: []string{"aws", "k8s"},
+		},
+		{
+			name: "enabled sources define the order",
+			settings: Settings{
+				Enabled: []string{"vault", "aws"},
+			},
+			out: []string{"vault", "aws"},
+		},
+		{
+			name: "disabled wins over enabled",
+			settings: Settings{
+				Enabled:  []string{"vault", "aws"},
+				Disabled: []string{"vault"},
+			},
+			out: []string{"aws"},
+		},
+		{
+			name: "unknown enabled source",
+			settings: Settings{
+				Enabled: []string{"foo"},
+			},
+			err: true,
+		},
+		{
+			name: "unknown disabled source",
+			settings: Settings{
+				Disabled: []string{"foo"},
+			},
+			err: true,
+		},
+	}
+
+	for _, test := range cases {
+		r := NewRegistry(fake("aws"), fake("k8s"), fake("vim"), fake("vault"))
+
+		sources, err := r.Enabled(test.settings)
+		if test.err {
+			assert.Error(t, err, test.name)
+			continue
+		}
+
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, test.out, names(sources), test.name)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	r := NewRegistry(fake("aws"), fake("k8s"), fake("vim"))
+
+	status, err := r.Status(Settings{
+		Enabled:  []string{"vim", "aws"},
+		Disabled: []string{"aws"},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Status{
+		{Name: "vim", Enabled: true, Order: 1},
+		{Name: "aws"},
+		{Name: "k8s"},
+	}, status)
+}
+
+func TestRegisterDuplicate(t *testing.T) {
+	r := NewRegistry(fake("aws"))
+
+	assert.EqualError(t, r.Register(fake("aws")), `source "aws" is already registered`)
+	assert.Equal(t, []string{"aws"}, r.Names())
+	assert.NoError(t, r.Register(fake("k8s")))
+
+	assert.Panics(t, func() {
+		NewRegistry(fake("aws"), fake("aws"))
+	})
+}
+
+func TestRun(t *testing.T) {
+	slow := Func("slow", func(ctx context.Context) ([]iterm.Profile, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	hanging := Func("hanging", func(ctx context.Context) ([]iterm.Profile, error) {
+		time.Sleep(time.Second)
+		return []iterm.Profile{{Name: "late"}}, nil
+	})
+
+	warn := Func("warn", func(ctx context.Context) ([]iterm.Profile, error) {
+		Warnf(ctx, "cannot find %s", "foo")
+		return []iterm.Profile{{Name: "warn"}}, errors.New("partial")
+	})
+
+	settings := Settings{
+		DefaultTimeout: time.Minute,
+		Timeouts: map[string]time.Duration{
+			"slow":    10 * time.Millisecond,
+			"hanging": 10 * time.Millisecond,
+		},
+	}
+
+	start := time.Now()
+	results := Run(context.Background(), []ProfileSource{fake("aws"), slow, hanging, warn}, settings)
+	assert.Less(t, time.Since(start), time.Second)
+
+	assert.Equal(t, []string{"aws", "slow", "hanging", "warn"}, []string{
+		results[0].Name, results[1].Name, results[2].Name, results[3].Name,
+	})
+
+	assert.NoError(t, results[0].Err)
+	assert.Len(t, results[0].Profiles, 1)
+
+	assert.ErrorIs(t, results[1].Err, context.DeadlineExceeded)
+	assert.ErrorIs(t, results[2].Err, context.DeadlineExceeded)
+	assert.EqualError(t, results[2].Err, "timed out after 10ms: context deadline exceeded")
+	assert.Len(t, results[2].Profiles, 0)
+
+	assert.EqualError(t, results[3].Err, "partial")
+	assert.Equal(t, []string{"cannot find foo"}, results[3].Warnings)
+	assert.Len(t, results[3].Profiles, 1)
+}
+
+func TestSettingsTimeout(t *testing.T) {
+	settings := Settings{
+		Timeouts: map[string]time.Duration{
+			"ssm": time.Hour,
+		},
+	}
+
+	assert.Equal(t, time.Hour, settings.Timeout("ssm"))
+	assert.Equal(t, DefaultTimeout, settings.Timeout("aws"))
+
+	settings.DefaultTimeout = time.Second
+	assert.Equal(t, time.Second, settings.Timeout("aws"))
+}
+
+func TestWriteReport(t *testing.T) {
+	results := []Result{
+		{
+			Name:     "aws",
+			Profiles: []iterm.Profile{{Name: "foo"}, {Name: "bar"}},
+			Duration: 1500 * time.Millisecond,
+		},
+		{
+			Name:     "ssm",
+			Warnings: []string{"failed to search profiles foo"},
+			Err:      errors.New("boom"),
+		},
+		{
+			Name: "k8s",
+			Err:  errors.Join(errors.New("cannot read\nfoo"), errors.New("bar")),
+		},
+	}
+
+	var text bytes.Buffer
+	assert.NoError(t, WriteReport(&text, results, "text"))
+	assert.Equal(t, heredoc.Doc(`
+		SOURCE  PROFILES  DURATION  WARNINGS  ERRORS
+		aws     2         1.50s     0         0
+		ssm     0         0.00s     1         1
+		k8s     0         0.00s     0         2
+	`), text.String())
+
+	var js bytes.Buffer
+	assert.NoError(t, WriteReport(&js, results, "json"))
+
+	var report []map[string]interface{}
+	assert.NoError(t, json.Unmarshal(js.Bytes(), &report))
+	assert.Equal(t, "aws", report[0]["source"])
+	assert.Equal(t, float64(2), report[0]["profiles"])
+	assert.Equal(t, []interface{}{"boom"}, report[1]["errors"])
+	assert.Equal(t, []interface{}{"cannot read\nfoo", "bar"}, report[2]["errors"])
+
+	assert.Error(t, WriteReport(&js, results, "yaml"))
+}
+
+func TestErrors(t *testing.T) {
+	results := []Result{
+		{Name: "aws", Err: errors.Join(errors.New("foo"), errors.New("bar"), errors.New("foo"))},
+		{Name: "k8s"},
+		{Name: "ssm", Err: errors.New("foo")},
+	}
+
+	var msgs []string
+	for _, err := range Errors(results) {
+		msgs = append(msgs, err.Error())
+	}
+
+	assert.Equal(t, []string{"aws: foo", "aws: bar", "ssm: foo"}, msgs)
+}
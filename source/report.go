@@ -0,0 +1,68 @@
+package source
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+type reportEntry struct {
+	Source   string   `json:"source"`
+	Profiles int      `json:"profiles"`
+	Duration float64  `json:"duration_seconds"`
+	Warnings []string `json:"warnings"`
+	Errors   []string `json:"errors"`
+}
+
+func newReport(results []Result) []reportEntry {
+	ret := make([]reportEntry, len(results))
+
+	for i, res := range results {
+		ret[i] = reportEntry{
+			Source:   res.Name,
+			Profiles: len(res.Profiles),
+			Duration: res.Duration.Seconds(),
+			Warnings: res.Warnings,
+			Errors:   []string{},
+		}
+
+		if res.Warnings == nil {
+			ret[i].Warnings = []string{}
+		}
+
+		for _, err := range flatten(res.Err) {
+			ret[i].Errors = append(ret[i].Errors, err.Error())
+		}
+	}
+
+	return ret
+}
+
+// WriteReport writes a summary of the results either as a text table or,
+// with format "json", as a JSON document.
+func WriteReport(w io.Writer, results []Result, format string) error {
+	report := newReport(results)
+
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(report, "", "    ")
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	case "text":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "SOURCE\tPROFILES\tDURATION\tWARNINGS\tERRORS")
+
+		for _, r := range report {
+			fmt.Fprintf(tw, "%s\t%d\t%.2fs\t%d\t%d\n", r.Source, r.Profiles, r.Duration, len(r.Warnings), len(r.Errors))
+		}
+
+		return tw.Flush()
+	}
+
+	return fmt.Errorf("unknown report format %q", format)
+}
@@ -0,0 +1,159 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mhristof/germ/iterm"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultTimeout is used for sources without a configured timeout.
+const DefaultTimeout = 5 * time.Minute
+
+// Result holds the outcome of running a single source.
+type Result struct {
+	Name     string
+	Profiles []iterm.Profile
+	Duration time.Duration
+	Warnings []string
+	Err      error
+}
+
+type warningsKey struct{}
+
+type warnings struct {
+	sync.Mutex
+	list []string
+}
+
+// Warnf records a warning for the source running with ctx. The warnings are
+// shown in the generation report.
+func Warnf(ctx context.Context, format string, args ...interface{}) {
+	w, ok := ctx.Value(warningsKey{}).(*warnings)
+	if !ok {
+		return
+	}
+
+	w.Lock()
+	defer w.Unlock()
+
+	w.list = append(w.list, fmt.Sprintf(format, args...))
+}
+
+// Timeout returns the configured timeout for the named source.
+func (s Settings) Timeout(name string) time.Duration {
+	if t, ok := s.Timeouts[name]; ok && t > 0 {
+		return t
+	}
+
+	if s.DefaultTimeout > 0 {
+		return s.DefaultTimeout
+	}
+
+	return DefaultTimeout
+}
+
+// Run executes all sources concurrently, each one with its own timeout. The
+// results are returned in the same order as the sources.
+func Run(ctx context.Context, sources []ProfileSource, settings Settings) []Result {
+	ret := make([]Result, len(sources))
+	wg := sync.WaitGroup{}
+
+	for i, s := range sources {
+		wg.Add(1)
+		go func(i int, s ProfileSource) {
+			defer wg.Done()
+
+			ret[i] = runOne(ctx, s, settings.Timeout(s.Name()))
+		}(i, s)
+	}
+
+	wg.Wait()
+
+	return ret
+}
+
+// Errors returns the unique errors of all results, prefixed with the source
+// name. Joined errors are reported one by one.
+func Errors(results []Result) []error {
+	var ret []error
+	seen := map[string]struct{}{}
+
+	for _, res := range results {
+		for _, err := range flatten(res.Err) {
+			err = fmt.Errorf("%s: %w", res.Name, err)
+			if _, ok := seen[err.Error()]; ok {
+				continue
+			}
+
+			seen[err.Error()] = struct{}{}
+			ret = append(ret, err)
+		}
+	}
+
+	return ret
+}
+
+func flatten(err error) []error {
+	if err == nil {
+		return nil
+	}
+
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []error{err}
+	}
+
+	var ret []error
+	for _, e := range joined.Unwrap() {
+		ret = append(ret, flatten(e)...)
+	}
+
+	return ret
+}
+
+func runOne(ctx context.Context, s ProfileSource, timeout time.Duration) Result {
+	w := &warnings{}
+
+	ctx, cancel := context.WithTimeout(context.WithValue(ctx, warningsKey{}, w), timeout)
+	defer cancel()
+
+	type generated struct {
+		profiles []iterm.Profile
+		err      error
+	}
+
+	start := time.Now()
+	done := make(chan generated, 1)
+
+	go func() {
+		profiles, err := s.Generate(ctx)
+		done <- generated{profiles: profiles, err: err}
+	}()
+
+	var res Result
+	select {
+	case out := <-done:
+		res = Result{Profiles: out.profiles, Err: out.err}
+	case <-ctx.Done():
+		res = Result{Err: fmt.Errorf("timed out after %s: %w", timeout, ctx.Err())}
+	}
+
+	res.Name = s.Name()
+	res.Duration = time.Since(start)
+
+	w.Lock()
+	res.Warnings = append([]string{}, w.list...)
+	w.Unlock()
+
+	log.Debug().
+		Str("source", res.Name).
+		Int("profiles", len(res.Profiles)).
+		Dur("duration", res.Duration).
+		Msg("source finished")
+
+	return res
+}
@@ -2,6 +2,8 @@ package ssh
 
 import (
 	"bytes"
+	"context"
+	stderrors "errors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -11,27 +13,57 @@ import (
 
 	"github.com/mhristof/germ/iterm"
 	"github.com/mhristof/germ/profile"
+	"github.com/mhristof/germ/source"
 	"github.com/rs/zerolog/log"
 )
 
-func Profiles() []iterm.Profile {
-	config := filepath.Join(os.Getenv("HOME"), ".ssh/config")
+// Source generates a profile for every host in ~/.ssh/config.
+type Source struct{}
+
+func (s *Source) Name() string {
+	return "ssh"
+}
+
+func (s *Source) Generate(ctx context.Context) ([]iterm.Profile, error) {
+	return Profiles(ctx)
+}
+
+func (s *Source) Inputs() []string {
+	return []string{configPath()}
+}
+
+func configPath() string {
+	return filepath.Join(os.Getenv("HOME"), ".ssh/config")
+}
+
+func Profiles(ctx context.Context) ([]iterm.Profile, error) {
+	config := configPath()
 	data, err := ioutil.ReadFile(config)
 	if err != nil {
 		log.Error().Str("config", config).Err(err).Msg("cannot open ssh config")
+		source.Warnf(ctx, "cannot open ssh config %s", config)
 	}
 
 	var ret []iterm.Profile
+	var errs []error
 	var lastProfile *iterm.Profile
 
+	gen := iterm.GenerationFrom(ctx)
+
 	for _, line := range strings.Split(string(data), "\n") {
 		// Handle tmux configuration for the last created profile
 		if strings.Contains(line, "RemoteCommand tmux") && lastProfile != nil {
 			// Update the last profile with tmux detach shortcut
-			updatedProfile := profile.NewSSHProfileBuilder(lastProfile.Name).
+			builder := profile.NewSSHProfileBuilder(lastProfile.Name)
+			builder.WithGeneration(gen)
+
+			updatedProfile, err := builder.
 				WithSSHCommand(lastProfile.Name).
 				WithTmuxDetach().
 				Build()
+			if err != nil {
+				errs = append(errs, err)
+			}
 			
 			// Replace the last profile with the updated one
 			ret[len(ret)-1] = *updatedProfile
@@ -55,22 +87,28 @@ func Profiles() []iterm.Profile {
 		}
 
 		host := fields[1]
-		hostIPAddr := hostIP(config, host)
+		hostIPAddr := hostIP(ctx, config, host)
 		
-		sshProfile := profile.NewSSHProfileBuilder(host).
+		builder := profile.NewSSHProfileBuilder(host)
+		builder.WithGeneration(gen)
+
+		sshProfile, err := builder.
 			WithSSHCommand(host).
 			WithHostIP(hostIPAddr).
 			Build()
+		if err != nil {
+			errs = append(errs, err)
+		}
 
 		ret = append(ret, *sshProfile)
 		lastProfile = sshProfile
 	}
 
-	return ret
+	return ret, stderrors.Join(errs...)
 }
 
-func hostIP(config, host string) string {
-	cmd := exec.Command("bash", "-c", fmt.Sprintf("ssh -G %s", host))
+func hostIP(ctx context.Context, config, host string) string {
+	cmd := exec.CommandContext(ctx, "bash", "-c", fmt.Sprintf("ssh -G %s", host))
 
 	var stdout, stderr bytes.Buffer
 
@@ -83,6 +121,7 @@ func hostIP(config, host string) string {
 			Str("config", config).
 			Str("stderr.String()", stderr.String()).
 			Msg("cannot find IP for host")
+		source.Warnf(ctx, "cannot find IP for host %s", host)
 
 		return ""
 	}
@@ -1,6 +1,7 @@
 package ssh
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"strings"
@@ -93,7 +94,8 @@ Host internal-server
 			os.Setenv("HOME", tempDir)
 			defer os.Setenv("HOME", originalHome)
 
-			profiles := Profiles()
+			profiles, err := Profiles(context.Background())
+			assert.NoError(t, err)
 			
 			// Check number of profiles
 			assert.Equal(t, len(test.expectedHosts), len(profiles))
@@ -132,7 +134,7 @@ func TestHostIP(t *testing.T) {
 
 	for _, test := range cases {
 		t.Run(test.name, func(t *testing.T) {
-			ip := hostIP("", test.host)
+			ip := hostIP(context.Background(), "", test.host)
 			
 			if test.expectEmpty {
 				assert.Empty(t, ip, test.description)
@@ -2,11 +2,14 @@ package ssm
 
 import (
 	"context"
+	"encoding/json"
+	stderrors "errors"
 	"fmt"
 	"os"
 	"strings"
 	"sync"
 
+	"github.com/adrg/xdg"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/iam"
@@ -14,6 +17,8 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/mhristof/germ/iterm"
 	profilebuilder "github.com/mhristof/germ/profile"
+	"github.com/mhristof/germ/source"
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 	"github.com/zieckey/goini"
 )
@@ -26,13 +31,117 @@ func expandUser(path string) string {
 	return path
 }
 
-func Generate() []iterm.Profile {
+const cacheName = "germ.ssm.json"
+
+// Source generates a profile for every SSM managed instance. When Cached is
+// set, the profiles from the last run are used instead of querying AWS.
+type Source struct {
+	// Config is the path of the AWS config file, ~/.aws/config if empty.
+	Config string
+	Cached bool
+}
+
+func (s *Source) Name() string {
+	return "ssm"
+}
+
+func (s *Source) Generate(ctx context.Context) ([]iterm.Profile, error) {
+	if s.Cached {
+		return loadFromCache()
+	}
+
+	profiles, err := Generate(ctx, s.Config)
+
+	// keep the previous cache when nothing could be generated
+	if len(profiles) == 0 {
+		return profiles, err
+	}
+
+	return profiles, stderrors.Join(err, storeToCache(profiles))
+}
+
+// cachedProfile keeps the identity of the profile in the cache, profiles do
+// not marshal it.
+type cachedProfile struct {
+	Identity string            `json:"Identity"`
+	Metadata map[string]string `json:"Metadata,omitempty"`
+	Profile  iterm.Profile     `json:"Profile"`
+}
+
+func loadFromCache() ([]iterm.Profile, error) {
+	path, err := xdg.CacheFile(cacheName)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get cache file")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read cache file %s", path)
+	}
+
+	var cached []cachedProfile
+	err = json.Unmarshal(data, &cached)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot unmarshal ssm profiles from %s", path)
+	}
+
+	profiles := make([]iterm.Profile, len(cached))
+	for i, c := range cached {
+		profiles[i] = c.Profile
+		profiles[i].Identity = c.Identity
+		profiles[i].Metadata = c.Metadata
+	}
+
+	// caches written before the identities hold the profiles directly
+	if len(cached) > 0 && cached[0].Profile.Name == "" {
+		err = json.Unmarshal(data, &profiles)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot unmarshal ssm profiles from %s", path)
+		}
+	}
+
+	log.Info().Str("path", path).Msg("using cached ssm profiles")
+
+	return profiles, nil
+}
+
+func storeToCache(profiles []iterm.Profile) error {
+	cached := make([]cachedProfile, len(profiles))
+	for i, p := range profiles {
+		cached[i] = cachedProfile{Identity: p.Identity, Metadata: p.Metadata, Profile: p}
+	}
+
+	data, err := json.MarshalIndent(cached, "", "    ")
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal ssm profiles")
+	}
+
+	path, err := xdg.CacheFile(cacheName)
+	if err != nil {
+		return errors.Wrap(err, "cannot get cache file")
+	}
+
+	err = os.WriteFile(path, data, 0o644)
+	if err != nil {
+		return errors.Wrapf(err, "cannot write cache file %s", path)
+	}
+
+	log.Debug().Str("path", path).Msg("stored ssm profiles to cache")
+
+	return nil
+}
+
+// Generate returns the profiles of the SSM instances reachable with the
+// profiles of the AWS config file, ~/.aws/config if empty.
+func Generate(ctx context.Context, awsConfig string) ([]iterm.Profile, error) {
+	if awsConfig == "" {
+		awsConfig = expandUser("~/.aws/config")
+	}
+
 	ini := goini.New()
-	config := expandUser("~/.aws/config")
-	err := ini.ParseFile(config)
+	err := ini.ParseFile(awsConfig)
 	if err != nil {
-		log.Error().Err(err).Str("config", config).Msg("Failed to parse AWS config")
-		return nil
+		return nil, errors.Wrapf(err, "failed to parse AWS config %s", awsConfig)
 	}
 
 	ret := []iterm.Profile{}
@@ -41,6 +150,7 @@ func Generate() []iterm.Profile {
 	lock := sync.Mutex{}
 
 	failedProfiles := []string{}
+	var errs []error
 
 	// First pass: collect all profiles and prefer admin ones
 	allProfiles := ini.GetAll()
@@ -125,16 +235,20 @@ func Generate() []iterm.Profile {
 		go func(pName, reg string) {
 			defer wg.Done()
 
-			profiles, profileInstances := generateForProfile(pName, reg, instances)
+			profiles, profileInstances, err := generateForProfile(ctx, awsConfig, pName, reg, instances)
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			if err != nil {
+				errs = append(errs, err)
+			}
 
 			if len(profiles) == 0 {
 				failedProfiles = append(failedProfiles, pName)
 				return
 			}
 
-			lock.Lock()
-			defer lock.Unlock()
-
 			ret = append(ret, profiles...)
 
 			log.Debug().Str("profile", pName).Str("region", reg).Int("count", len(profiles)).Msg("Generated profiles")
@@ -149,9 +263,10 @@ func Generate() []iterm.Profile {
 
 	if len(failedProfiles) > 0 {
 		log.Warn().Str("profiles", strings.Join(failedProfiles, ",")).Msg("Failed to search profiles")
+		source.Warnf(ctx, "failed to search profiles %s", strings.Join(failedProfiles, ","))
 	}
 
-	return ret
+	return ret, stderrors.Join(errs...)
 }
 
 // create instanceID mutex
@@ -176,37 +291,40 @@ type InstanceInfo struct {
 	ID      string
 	Name    string
 	ASGName string
+	AZ      string
 	Tags    map[string]string
 }
 
-func generateForProfile(profile, region string, instanceIDs map[string]string) ([]iterm.Profile, map[string]string) {
-	clients, err := createAWSClients(profile)
+// generateForProfile returns the profiles for the instances reachable with
+// the given AWS profile. Profiles that cannot be queried, for example due to
+// expired credentials, are not considered an error.
+func generateForProfile(ctx context.Context, awsConfig, profile, region string, instanceIDs map[string]string) ([]iterm.Profile, map[string]string, error) {
+	clients, err := createAWSClients(ctx, awsConfig, profile)
 	if err != nil {
 		log.Debug().Err(err).Str("profile", profile).Msg("Failed to create AWS clients")
-		return nil, instanceIDs
+		return nil, instanceIDs, nil
 	}
 
-	accountInfo, err := getAccountInfo(clients)
+	accountInfo, err := getAccountInfo(ctx, clients)
 	if err != nil {
 		log.Debug().Err(err).Str("profile", profile).Msg("Failed to retrieve account info")
-		return nil, instanceIDs
+		return nil, instanceIDs, nil
 	}
 
-	instances, err := discoverSSMInstances(clients, instanceIDs)
+	instances, err := discoverSSMInstances(ctx, clients, instanceIDs)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to discover SSM instances")
-		return nil, instanceIDs
+		return nil, instanceIDs, nil
 	}
 
-	profiles, updatedInstanceIDs := createSSMProfiles(instances, profile, region, accountInfo, instanceIDs)
-	
-	return profiles, updatedInstanceIDs
+	return createSSMProfiles(iterm.GenerationFrom(ctx), instances, profile, region, accountInfo, instanceIDs)
 }
 
 // createAWSClients initializes all required AWS service clients
-func createAWSClients(profile string) (*AWSClients, error) {
+func createAWSClients(ctx context.Context, awsConfig, profile string) (*AWSClients, error) {
 	cfg, err := config.LoadDefaultConfig(
-		context.Background(),
+		ctx,
+		config.WithSharedConfigFiles([]string{awsConfig}),
 		config.WithSharedConfigProfile(profile),
 	)
 	if err != nil {
@@ -222,13 +340,13 @@ func createAWSClients(profile string) (*AWSClients, error) {
 }
 
 // getAccountInfo retrieves AWS account ID and alias
-func getAccountInfo(clients *AWSClients) (*AccountInfo, error) {
-	accountID, err := clients.STS.GetCallerIdentity(context.Background(), &sts.GetCallerIdentityInput{})
+func getAccountInfo(ctx context.Context, clients *AWSClients) (*AccountInfo, error) {
+	accountID, err := clients.STS.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
 	if err != nil || accountID == nil {
 		return nil, err
 	}
 
-	accountAliases, err := clients.IAM.ListAccountAliases(context.Background(), &iam.ListAccountAliasesInput{})
+	accountAliases, err := clients.IAM.ListAccountAliases(ctx, &iam.ListAccountAliasesInput{})
 	if err != nil {
 		return nil, err
 	}
@@ -245,14 +363,14 @@ func getAccountInfo(clients *AWSClients) (*AccountInfo, error) {
 }
 
 // discoverSSMInstances finds all SSM-managed instances and their details
-func discoverSSMInstances(clients *AWSClients, existingInstanceIDs map[string]string) ([]InstanceInfo, error) {
+func discoverSSMInstances(ctx context.Context, clients *AWSClients, existingInstanceIDs map[string]string) ([]InstanceInfo, error) {
 	var instances []InstanceInfo
 	asgs := make(map[string]string) // Track ASG instances to avoid duplicates
 
 	paginator := awsssm.NewDescribeInstanceInformationPaginator(clients.SSM, &awsssm.DescribeInstanceInformationInput{})
 
 	for paginator.HasMorePages() {
-		page, err := paginator.NextPage(context.Background())
+		page, err := paginator.NextPage(ctx)
 		if err != nil {
 			return nil, err
 		}
@@ -262,7 +380,7 @@ func discoverSSMInstances(clients *AWSClients, existingInstanceIDs map[string]st
 				continue
 			}
 
-			instanceInfo, err := getInstanceDetails(clients.EC2, *instance.InstanceId)
+			instanceInfo, err := getInstanceDetails(ctx, clients.EC2, *instance.InstanceId)
 			if err != nil {
 				log.Error().Err(err).Str("instanceId", *instance.InstanceId).Msg("Failed to get instance details")
 				continue
@@ -300,8 +418,8 @@ func shouldSkipInstance(instanceID string, existingInstanceIDs map[string]string
 }
 
 // getInstanceDetails retrieves detailed information about an EC2 instance
-func getInstanceDetails(ec2Client *ec2.Client, instanceID string) (*InstanceInfo, error) {
-	result, err := ec2Client.DescribeInstances(context.Background(), &ec2.DescribeInstancesInput{
+func getInstanceDetails(ctx context.Context, ec2Client *ec2.Client, instanceID string) (*InstanceInfo, error) {
+	result, err := ec2Client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
 		InstanceIds: []string{instanceID},
 	})
 	if err != nil {
@@ -318,6 +436,10 @@ func getInstanceDetails(ec2Client *ec2.Client, instanceID string) (*InstanceInfo
 		Tags: make(map[string]string),
 	}
 
+	if instance.Placement != nil && instance.Placement.AvailabilityZone != nil {
+		info.AZ = *instance.Placement.AvailabilityZone
+	}
+
 	// Process instance tags
 	for _, tag := range instance.Tags {
 		if tag.Key == nil || tag.Value == nil {
@@ -367,8 +489,9 @@ func shouldSkipASGInstance(instanceInfo *InstanceInfo, asgs map[string]string) b
 }
 
 // createSSMProfiles generates iTerm profiles for the discovered instances
-func createSSMProfiles(instances []InstanceInfo, profile, region string, accountInfo *AccountInfo, instanceIDs map[string]string) ([]iterm.Profile, map[string]string) {
+func createSSMProfiles(gen *iterm.Generation, instances []InstanceInfo, profile, region string, accountInfo *AccountInfo, instanceIDs map[string]string) ([]iterm.Profile, map[string]string, error) {
 	var profiles []iterm.Profile
+	var errs []error
 	updatedInstanceIDs := make(map[string]string)
 
 	// Copy existing instance IDs
@@ -377,7 +500,10 @@ func createSSMProfiles(instances []InstanceInfo, profile, region string, account
 	}
 
 	for _, instance := range instances {
-		ssmProfile := createSSMProfile(instance, profile, region, accountInfo)
+		ssmProfile, err := createSSMProfile(gen, instance, profile, region, accountInfo)
+		if err != nil {
+			errs = append(errs, err)
+		}
 		profiles = append(profiles, *ssmProfile)
 
 		log.Info().
@@ -394,17 +520,25 @@ func createSSMProfiles(instances []InstanceInfo, profile, region string, account
 		instanceIDMutex.Unlock()
 	}
 
-	return profiles, updatedInstanceIDs
+	return profiles, updatedInstanceIDs, stderrors.Join(errs...)
 }
 
 // createSSMProfile creates a single SSM profile for an instance
-func createSSMProfile(instance InstanceInfo, profile, region string, accountInfo *AccountInfo) *iterm.Profile {
+func createSSMProfile(gen *iterm.Generation, instance InstanceInfo, profile, region string, accountInfo *AccountInfo) (*iterm.Profile, error) {
 	var regionTags []string
 	if tags, ok := iterm.AWSRegionTags[region]; ok {
 		regionTags = tags
 	}
 
-	return profilebuilder.NewSSMProfileBuilder(accountInfo.Alias, region, instance.Name).
+	builder := profilebuilder.NewSSMProfileBuilder(accountInfo.Alias, region, instance.Name)
+	builder.WithGeneration(gen)
+	builder.WithIdentity("ssm:" + instance.ID)
+	builder.WithMetadata("instance", instance.ID)
+	builder.WithMetadata("instance_name", instance.Name)
+	builder.WithMetadata("az", instance.AZ)
+	builder.WithMetadata("asg", instance.ASGName)
+
+	return builder.
 		WithSSMCommand(profile, instance.Name).
 		WithAWSAccountInfo(accountInfo.Alias, accountInfo.ID, region, regionTags).
 		Build()
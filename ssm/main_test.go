@@ -1,9 +1,14 @@
 package ssm
 
 import (
+	"context"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/adrg/xdg"
+	"github.com/mhristof/germ/iterm"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -258,12 +263,21 @@ func TestRegionDetection(t *testing.T) {
 }
 
 func TestCreateAWSClients(t *testing.T) {
-	// This test requires AWS credentials/config, skip if not available
-	t.Run("creates clients successfully", func(t *testing.T) {
-		// AWS SDK v2 now validates profiles exist during config loading
-		// This test will fail without a valid profile, so we skip it
-		t.Skip("Skipping: requires valid AWS profile configuration")
-	})
+	t.Setenv("HOME", t.TempDir())
+
+	config := filepath.Join(t.TempDir(), "config")
+	assert.NoError(t, os.WriteFile(config, []byte("[profile custom]\nregion = eu-west-1\n"), 0o644))
+
+	// the profiles are read from the given config, not ~/.aws/config
+	clients, err := createAWSClients(context.Background(), config, "custom")
+	assert.NoError(t, err)
+	assert.NotNil(t, clients.SSM)
+
+	_, err = createAWSClients(context.Background(), config, "missing")
+	assert.Error(t, err)
+
+	_, err = Generate(context.Background(), filepath.Join(t.TempDir(), "nope"))
+	assert.Error(t, err)
 }
 
 func TestShouldSkipInstance(t *testing.T) {
@@ -355,9 +369,37 @@ func TestCreateSSMProfile(t *testing.T) {
 		Alias: "test-account",
 	}
 
-	profile := createSSMProfile(instance, "test-profile", "us-east-1", accountInfo)
+	profile, err := createSSMProfile(nil, instance, "test-profile", "us-east-1", accountInfo)
+	assert.NoError(t, err)
 
 	assert.Equal(t, "test-account:us-east-1:ssm-test-instance", profile.Name)
 	assert.Contains(t, profile.InitialText, "AWS_PROFILE=test-profile ssm test-instance")
 	assert.Contains(t, profile.KeyboardMap, "0x61-0x80000")
-}
\ No newline at end of file
+}
+func TestCache(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", dir)
+	xdg.Reload()
+	t.Cleanup(xdg.Reload)
+
+	profiles := []iterm.Profile{{
+		Name:     "prod:eu-west-1:ssm-web",
+		Identity: "ssm:i-123",
+		Metadata: map[string]string{"instance": "i-123", "az": "eu-west-1a"},
+	}}
+	assert.NoError(t, storeToCache(profiles))
+
+	cached, err := loadFromCache()
+	assert.NoError(t, err)
+	assert.Equal(t, "prod:eu-west-1:ssm-web", cached[0].Name)
+	assert.Equal(t, "ssm:i-123", cached[0].Identity)
+	assert.Equal(t, profiles[0].Metadata, cached[0].Metadata)
+
+	// caches from older versions hold the profiles directly
+	err = os.WriteFile(filepath.Join(dir, cacheName), []byte(`[{"Name": "old"}]`), 0o644)
+	assert.NoError(t, err)
+
+	cached, err = loadFromCache()
+	assert.NoError(t, err)
+	assert.Equal(t, "old", cached[0].Name)
+}
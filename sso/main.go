@@ -24,14 +24,23 @@ type Account struct {
 	AccountName string
 }
 
-func ListAccounts() (ret []Account) {
-	mySession := session.Must(session.NewSession())
+// ListAccounts returns the accounts and roles of the SSO session started at
+// startURL in region, using the cached access token of the session. An empty
+// startURL uses any cached token.
+func ListAccounts(region, startURL string) (ret []Account) {
+	config := &aws.Config{}
+	if region != "" {
+		config.Region = aws.String(region)
+	}
+
+	mySession := session.Must(session.NewSession(config))
 
 	// Create a SSO client from just a session.
 	svc := sso.New(mySession)
+	token := accessToken(startURL)
 
 	accounts, err := svc.ListAccounts(&sso.ListAccountsInput{
-		AccessToken: aws.String(accessToken()),
+		AccessToken: aws.String(token),
 	})
 	if err != nil {
 		log.WithFields(log.Fields{
@@ -42,7 +51,7 @@ func ListAccounts() (ret []Account) {
 	for _, account := range accounts.AccountList {
 		roles, err := svc.ListAccountRoles(&sso.ListAccountRolesInput{
 			AccountId:   account.AccountId,
-			AccessToken: aws.String(accessToken()),
+			AccessToken: aws.String(token),
 		})
 		if err != nil {
 			log.WithFields(log.Fields{
@@ -140,7 +149,9 @@ type Creds struct {
 	StartURL    string `json:"startUrl"`
 }
 
-func accessToken() (ret string) {
+// accessToken returns the cached access token of the session started at
+// startURL, or the last one found if startURL is empty.
+func accessToken(startURL string) (ret string) {
 	dir, err := homedir.Expand("~/.aws/sso/cache")
 	if err != nil {
 		log.WithFields(log.Fields{
@@ -179,6 +190,10 @@ func accessToken() (ret string) {
 				}).Error("cannot unmarshal file")
 			}
 
+			if startURL != "" && creds.StartURL != startURL {
+				return nil
+			}
+
 			log.WithFields(log.Fields{
 				"path": path,
 			}).Debug("found AWS access token")
@@ -1,10 +1,14 @@
 package sso
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/MakeNowJust/heredoc"
+	"github.com/mitchellh/go-homedir"
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/ini.v1"
 )
@@ -92,3 +96,23 @@ func TestUpdateConfig(t *testing.T) {
 		assert.Equal(t, expected.String(), generated.String(), test.name)
 	}
 }
+
+func TestAccessToken(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	homedir.DisableCache = true
+	defer func() { homedir.DisableCache = false }()
+
+	cache := filepath.Join(home, ".aws", "sso", "cache")
+	assert.NoError(t, os.MkdirAll(cache, 0o755))
+
+	for name, url := range map[string]string{"a.json": "https://a", "b.json": "https://b"} {
+		data := fmt.Sprintf(`{"accessToken": "token-%s", "startUrl": "%s"}`, name, url)
+		assert.NoError(t, os.WriteFile(filepath.Join(cache, name), []byte(data), 0o644))
+	}
+
+	assert.Equal(t, "token-a.json", accessToken("https://a"))
+	assert.Equal(t, "token-b.json", accessToken("https://b"))
+	assert.Equal(t, "", accessToken("https://c"))
+	assert.NotEmpty(t, accessToken(""))
+}
@@ -1,20 +1,41 @@
 package vault
 
 import (
+	"context"
 	"fmt"
 	"os/exec"
 
 	"github.com/mhristof/germ/iterm"
+	"github.com/mhristof/germ/source"
 	"github.com/pkg/errors"
 )
 
-func Profile() (iterm.Profile, error) {
+// Source generates the vault dev server profile.
+type Source struct{}
+
+func (s *Source) Name() string {
+	return "vault"
+}
+
+func (s *Source) Generate(ctx context.Context) ([]iterm.Profile, error) {
+	// vault is optional, a missing binary is not an error
+	if _, err := exec.LookPath("vault"); err != nil {
+		source.Warnf(ctx, "vault binary not found, skipping the vault profile")
+		return nil, nil
+	}
+
+	p, err := Profile(iterm.GenerationFrom(ctx))
+
+	return []iterm.Profile{p}, err
+}
+
+func Profile(gen *iterm.Generation) (iterm.Profile, error) {
 	path, err := exec.LookPath("vault")
 	if err != nil {
 		return iterm.Profile{}, errors.Wrap(err, "cannot find vault binary")
 	}
 
-	p := iterm.NewProfile("vault", map[string]string{
+	p, err := gen.NewProfile("vault", map[string]string{
 		"Command": fmt.Sprintf("%s server -dev", path),
 	})
 
@@ -34,10 +55,10 @@ func Profile() (iterm.Profile, error) {
 	p.KeyboardMap = map[string]iterm.KeyboardMap{
 		"0x77-0x100000-0xd": {
 			Version: 1,
-			Action:  12,
+			Action:  iterm.KeyboardSendText,
 			Text:    "Cmd+w is disabled, please ctrl+c to exit\n",
 		},
 	}
 
-	return *p, nil
+	return *p, err
 }
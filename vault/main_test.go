@@ -48,7 +48,7 @@ func TestProfile(t *testing.T) {
 			
 			defer os.Setenv("PATH", originalPath)
 
-			profile, err := Profile()
+			profile, err := Profile(nil)
 
 			if test.expectError {
 				assert.Error(t, err)
@@ -119,7 +119,7 @@ func TestProfileStructure(t *testing.T) {
 	os.Setenv("PATH", tempDir+":"+originalPath)
 	defer os.Setenv("PATH", originalPath)
 
-	profile, err := Profile()
+	profile, err := Profile(nil)
 	assert.NoError(t, err)
 
 	// Verify it's a valid iterm.Profile
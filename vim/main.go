@@ -1,9 +1,26 @@
 package vim
 
-import "github.com/mhristof/germ/iterm"
+import (
+	"context"
 
-func Profile() iterm.Profile {
-	p := iterm.NewProfile("vim", map[string]string{})
+	"github.com/mhristof/germ/iterm"
+)
+
+// Source generates the vim profile.
+type Source struct{}
+
+func (s *Source) Name() string {
+	return "vim"
+}
+
+func (s *Source) Generate(ctx context.Context) ([]iterm.Profile, error) {
+	p, err := Profile(iterm.GenerationFrom(ctx))
+
+	return []iterm.Profile{p}, err
+}
+
+func Profile(gen *iterm.Generation) (iterm.Profile, error) {
+	p, err := gen.NewProfile("vim", map[string]string{})
 
 	p.Triggers = []iterm.Trigger{}
 	p.BoundHosts = []string{
@@ -11,5 +28,5 @@ func Profile() iterm.Profile {
 		"&nvim",
 	}
 
-	return *p
+	return *p, err
 }
@@ -9,7 +9,8 @@ import (
 )
 
 func TestProfile(t *testing.T) {
-	profile := Profile()
+	profile, err := Profile(nil)
+	assert.NoError(t, err)
 
 	// Test basic profile properties
 	assert.Equal(t, "vim", profile.Name)
@@ -33,8 +34,10 @@ func TestProfile(t *testing.T) {
 
 func TestProfileConsistency(t *testing.T) {
 	// Test that multiple calls return consistent results
-	profile1 := Profile()
-	profile2 := Profile()
+	profile1, err := Profile(nil)
+	assert.NoError(t, err)
+	profile2, err := Profile(nil)
+	assert.NoError(t, err)
 
 	assert.Equal(t, profile1.Name, profile2.Name)
 	assert.Equal(t, profile1.BoundHosts, profile2.BoundHosts)
@@ -45,7 +48,8 @@ func TestProfileConsistency(t *testing.T) {
 }
 
 func TestProfileIntegration(t *testing.T) {
-	profile := Profile()
+	profile, err := Profile(nil)
+	assert.NoError(t, err)
 
 	// Test that the profile can be used in a slice of profiles
 	profiles := []iterm.Profile{profile}